@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/assets/database"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	aplInfile  string
+	aplRequest string
+	aplFormat  string
+)
+
+var aplCmd = &cobra.Command{
+	Use:   "apl",
+	Short: "convert and lint APL rotations",
+	Long:  "convert APL rotations between protojson and the APL text format, and check them for likely mistakes",
+}
+
+var aplToTextCmd = &cobra.Command{
+	Use:   "totext",
+	Short: "convert an APL rotation to text",
+	Long:  "convert an APL rotation in protojson format to the APL text format, naming spells and auras registered by the first player of --request or --link",
+	RunE:  aplToTextMain,
+}
+
+var aplToJSONCmd = &cobra.Command{
+	Use:   "tojson",
+	Short: "convert an APL rotation to protojson",
+	Long:  "convert an APL rotation in the APL text format to protojson, resolving names through the spells and auras registered by the first player of --request or --link",
+	RunE:  aplToJSONMain,
+}
+
+var aplLintCmd = &cobra.Command{
+	Use:          "lint",
+	Short:        "check an APL rotation for likely mistakes",
+	Long:         "check an APL rotation for rows that can never run, constant conditions, mismatched value types, waits that can block forever, sequences that are never reset and out of order prepull actions, using the first player of --request or --link. Exits with an error if anything is found",
+	RunE:         aplLintMain,
+	SilenceUsage: true,
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{aplToTextCmd, aplToJSONCmd, aplLintCmd} {
+		cmd.Flags().StringVar(&aplRequest, "request", "", "RaidSimRequest in protojson format whose first player is used to resolve names")
+		cmd.Flags().StringVar(&link, "link", "", "wowsims link to use instead of --request")
+		cmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+		cmd.MarkFlagsMutuallyExclusive("request", "link")
+	}
+	aplToTextCmd.Flags().StringVar(&aplInfile, "infile", "", "location of input file (APLRotation in protojson format), defaults to the rotation of the first player of --request or --link")
+	aplToJSONCmd.Flags().StringVar(&aplInfile, "infile", "", "location of input file (APL text format)")
+	aplToJSONCmd.MarkFlagRequired("infile")
+	aplLintCmd.Flags().StringVar(&aplInfile, "infile", "", "location of input file (APLRotation in protojson or APL text format), defaults to the rotation of the first player")
+	aplLintCmd.Flags().StringVar(&aplFormat, "format", "text", "output format: text, or json with a list of APLDiagnostic")
+	aplLintCmd.MarkFlagsOneRequired("request", "link")
+
+	aplCmd.AddCommand(aplToTextCmd)
+	aplCmd.AddCommand(aplToJSONCmd)
+	aplCmd.AddCommand(aplLintCmd)
+}
+
+func aplToTextMain(cmd *cobra.Command, args []string) error {
+	resolver, player, err := loadAPLPlayer()
+	if err != nil {
+		return err
+	}
+
+	var rotation *proto.APLRotation
+	if aplInfile != "" {
+		data, err := os.ReadFile(aplInfile)
+		if err != nil {
+			return fmt.Errorf("failed to load input file %q: %w", aplInfile, err)
+		}
+		rotation = &proto.APLRotation{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, rotation); err != nil {
+			return fmt.Errorf("failed to load input file: %w", err)
+		}
+	} else if player != nil {
+		rotation = player.Rotation
+	}
+	if rotation == nil {
+		return errors.New("no rotation, use --infile or a player with a rotation")
+	}
+
+	return writeTableOutput(outfile, []byte(core.FormatAPLText(rotation, resolver)))
+}
+
+func aplToJSONMain(cmd *cobra.Command, args []string) error {
+	resolver, _, err := loadAPLPlayer()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(aplInfile)
+	if err != nil {
+		return fmt.Errorf("failed to load input file %q: %w", aplInfile, err)
+	}
+	rotation, err := core.ParseAPLText(string(data), resolver)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", aplInfile, err)
+	}
+
+	output, err := protojson.MarshalOptions{Multiline: true}.Marshal(rotation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rotation: %w", err)
+	}
+	return writeOutput(output)
+}
+
+func aplLintMain(cmd *cobra.Command, args []string) error {
+	request, player, err := loadAPLRequest()
+	if err != nil {
+		return err
+	}
+
+	if aplInfile != "" {
+		data, err := os.ReadFile(aplInfile)
+		if err != nil {
+			return fmt.Errorf("failed to load input file %q: %w", aplInfile, err)
+		}
+		rotation := &proto.APLRotation{}
+		if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+			err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, rotation)
+		} else {
+			rotation, err = core.ParseAPLText(string(data), newAPLNameResolver(request))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", aplInfile, err)
+		}
+		player.Rotation = rotation
+	}
+	if player.Rotation == nil {
+		return errors.New("no rotation, use --infile or a player with a rotation")
+	}
+
+	// The rotation is linted when the player is constructed.
+	_, raidStats, _ := core.NewEnvironment(request.Raid, request.Encounter, false)
+	var diagnostics []*proto.APLDiagnostic
+	for partyIdx, party := range request.Raid.Parties {
+		for playerIdx, partyPlayer := range party.Players {
+			if partyPlayer == player {
+				diagnostics = raidStats.Parties[partyIdx].Players[playerIdx].GetRotationStats().GetDiagnostics()
+			}
+		}
+	}
+
+	var output []byte
+	switch aplFormat {
+	case "text":
+		var sb strings.Builder
+		for _, diagnostic := range diagnostics {
+			fmt.Fprintf(&sb, "%s: %s: %s\n", aplDiagnosticLocation(diagnostic), strings.TrimPrefix(diagnostic.Check.String(), "Check"), diagnostic.Message)
+		}
+		output = []byte(sb.String())
+	case "json":
+		var items []string
+		for _, diagnostic := range diagnostics {
+			item, err := protojson.Marshal(diagnostic)
+			if err != nil {
+				return fmt.Errorf("failed to marshal diagnostic: %w", err)
+			}
+			items = append(items, string(item))
+		}
+		output = []byte("[" + strings.Join(items, ",\n") + "]\n")
+	default:
+		return fmt.Errorf("unknown format %q", aplFormat)
+	}
+	if err := writeTableOutput(outfile, output); err != nil {
+		return err
+	}
+
+	if len(diagnostics) > 0 {
+		return fmt.Errorf("found %d issues", len(diagnostics))
+	}
+	return nil
+}
+
+// Returns where the diagnostic is in the rotation, e.g. "priority list row 3",
+// numbering rows from 1 as shown in the UI.
+func aplDiagnosticLocation(diagnostic *proto.APLDiagnostic) string {
+	row := diagnostic.Row + 1
+	switch diagnostic.Section {
+	case proto.APLDiagnostic_SectionPrepull:
+		return fmt.Sprintf("prepull row %d", row)
+	case proto.APLDiagnostic_SectionPriorityList:
+		return fmt.Sprintf("priority list row %d", row)
+	case proto.APLDiagnostic_SectionActionList:
+		return fmt.Sprintf("action list %d row %d", diagnostic.ActionList+1, row)
+	case proto.APLDiagnostic_SectionVariables:
+		return fmt.Sprintf("variable %d", row)
+	case proto.APLDiagnostic_SectionMacros:
+		return fmt.Sprintf("macro %d", row)
+	}
+	return "rotation"
+}
+
+// Loads the first player of --request or --link, and a resolver for the names of
+// its spells and auras. Returns nils if neither is set, so only IDs can be used.
+func loadAPLPlayer() (*core.APLNameResolver, *proto.Player, error) {
+	request, player, err := loadAPLRequest()
+	if request == nil || err != nil {
+		return nil, nil, err
+	}
+	return newAPLNameResolver(request), player, nil
+}
+
+// Loads the request of --request or --link, and its first player. Returns nils
+// if neither is set.
+func loadAPLRequest() (*proto.RaidSimRequest, *proto.Player, error) {
+	var request *proto.RaidSimRequest
+	switch {
+	case link != "":
+		var err error
+		if request, err = decodeRaidSimRequest(link); err != nil {
+			return nil, nil, err
+		}
+	case aplRequest != "":
+		data, err := os.ReadFile(aplRequest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load request file %q: %w", aplRequest, err)
+		}
+		request = &proto.RaidSimRequest{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, request); err != nil {
+			return nil, nil, fmt.Errorf("failed to load request file: %w", err)
+		}
+	default:
+		return nil, nil, nil
+	}
+
+	var player *proto.Player
+	for _, party := range request.GetRaid().GetParties() {
+		for _, partyPlayer := range party.Players {
+			if player == nil && partyPlayer != nil && partyPlayer.Class != proto.Class_ClassUnknown {
+				player = partyPlayer
+			}
+		}
+	}
+	if player == nil {
+		return nil, nil, errors.New("request has no player")
+	}
+	return request, player, nil
+}
+
+func newAPLNameResolver(request *proto.RaidSimRequest) *core.APLNameResolver {
+	env, _, _ := core.NewEnvironment(request.Raid, request.Encounter, false)
+	return core.NewAPLNameResolver(env.Raid.AllPlayerUnits[0], database.Load())
+}
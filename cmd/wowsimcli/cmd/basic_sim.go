@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/wowsims/sod/sim/core"
@@ -11,6 +15,11 @@ import (
 	"google.golang.org/protobuf/encoding/protojson"
 )
 
+var (
+	resultTable string
+	runID       string
+)
+
 var simCmd = &cobra.Command{
 	Use:   "sim",
 	Short: "simulate items & settings",
@@ -19,26 +28,24 @@ var simCmd = &cobra.Command{
 
 func init() {
 	simCmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (RaidSimRequest in protojson format)")
+	simCmd.Flags().StringVar(&link, "link", "", "wowsims link to sim instead of the input file")
 	simCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
 	simCmd.Flags().BoolVar(&verbose, "verbose", false, "print information during runtime")
-	simCmd.MarkFlagRequired("infile")
+	simCmd.Flags().StringVar(&format, "format", "json", "output format: json, csv or ndjson. csv and ndjson flatten the result into runs, units, actions, auras, resources, damage_by_school, damage_by_source, target_kills and kill_order tables")
+	simCmd.Flags().StringVar(&resultTable, "table", "", "only output this table in the csv and ndjson formats. csv writes every table to its own <outfile>.<table>.csv otherwise")
+	simCmd.Flags().StringVar(&runID, "run-id", "", "value of the run_id column in the csv and ndjson formats, for joining the results of several runs")
+	simCmd.MarkFlagsMutuallyExclusive("infile", "link")
 }
 
 func simMain(cmd *cobra.Command, args []string) {
-	data, err := os.ReadFile(infile)
-	if err != nil {
-		log.Fatalf("failed to load input json file %q: %v", infile, err)
-	}
-	input := &proto.RaidSimRequest{}
-
-	err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, input)
+	input, err := loadRaidSimRequest()
 	if err != nil {
-		log.Fatalf("failed to load input json file: %s", err)
+		log.Fatal(err)
 	}
 
 	var output []byte
 	reporter := make(chan *proto.ProgressMetrics, 10)
-	core.RunRaidSimAsync(input, reporter)
+	core.RunRaidSimAsync(context.Background(), input, reporter)
 
 	var finalResult *proto.RaidSimResult
 	for v := range reporter {
@@ -51,6 +58,16 @@ func simMain(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	if format != "json" {
+		if finalResult.ErrorResult != "" {
+			log.Fatalf("sim failed: %s", finalResult.ErrorResult)
+		}
+		if err := writeResultTables(finalResult); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	output, err = protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(finalResult)
 	if err != nil {
 		log.Fatalf("failed to marshal final results: %s", err)
@@ -68,3 +85,63 @@ func simMain(cmd *cobra.Command, args []string) {
 		}
 	}
 }
+
+// Writes the result as flat tables, in the csv or ndjson format.
+func writeResultTables(result *proto.RaidSimResult) error {
+	tables := core.RaidSimResultTables(result, runID)
+	if resultTable != "" {
+		var selected []*core.ResultTable
+		for _, table := range tables {
+			if table.Name == resultTable {
+				selected = append(selected, table)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("unknown table: %s", resultTable)
+		}
+		tables = selected
+	}
+
+	switch format {
+	case "ndjson":
+		var buf bytes.Buffer
+		if err := core.WriteResultTablesNDJSON(&buf, tables); err != nil {
+			return err
+		}
+		return writeTableOutput(outfile, buf.Bytes())
+	case "csv":
+		if len(tables) > 1 && outfile == "" {
+			return fmt.Errorf("csv output needs --outfile to write each table to its own file, or --table to only output one")
+		}
+		for _, table := range tables {
+			var buf bytes.Buffer
+			if err := table.WriteCSV(&buf); err != nil {
+				return err
+			}
+			path := outfile
+			if len(tables) > 1 {
+				path = strings.TrimSuffix(outfile, filepath.Ext(outfile)) + "." + table.Name + ".csv"
+			}
+			if err := writeTableOutput(path, buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+func writeTableOutput(path string, output []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(output)
+		return err
+	}
+	if err := os.WriteFile(path, output, 0666); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if verbose {
+		fmt.Printf("Wrote output file: `%s` successfully.\n", path)
+	}
+	return nil
+}
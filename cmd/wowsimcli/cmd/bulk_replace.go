@@ -12,7 +12,6 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/wowsims/sod/sim/core"
 	"github.com/wowsims/sod/sim/core/proto"
-	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var (
@@ -31,23 +30,18 @@ var bulkCmd = &cobra.Command{
 
 func init() {
 	bulkCmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (RaidSimRequest in protojson format)")
+	bulkCmd.Flags().StringVar(&link, "link", "", "wowsims link to sim instead of the input file")
 	bulkCmd.Flags().StringVar(&replacefile, "replacefile", "", "location of replacement items file. Writes a CSV result of the items replaced instead of JSON")
 	bulkCmd.Flags().StringVar(&outfile, "output", "", "location of output file, defaults to stdout")
 	bulkCmd.Flags().BoolVar(&verbose, "verbose", false, "print information during runtime")
-	bulkCmd.MarkFlagRequired("infile")
+	bulkCmd.MarkFlagsMutuallyExclusive("infile", "link")
 	bulkCmd.MarkFlagRequired("replacefile")
 }
 
 func bulkSimMain(cmd *cobra.Command, args []string) {
-	data, err := os.ReadFile(infile)
+	input, err := loadRaidSimRequest()
 	if err != nil {
-		log.Fatalf("failed to load input json file %q: %v", infile, err)
-	}
-	input := &proto.RaidSimRequest{}
-
-	err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, input)
-	if err != nil {
-		log.Fatalf("failed to load input json file: %s", err)
+		log.Fatal(err)
 	}
 
 	output := BulkSim(input, replacefile, verbose)
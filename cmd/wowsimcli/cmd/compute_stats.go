@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var computeStatsCmd = &cobra.Command{
+	Use:   "computestats",
+	Short: "compute character stats",
+	Long:  "compute character stats with gear, buffs and consumes, from a ComputeStatsRequest or an individual sim link",
+	RunE:  computeStatsMain,
+}
+
+func init() {
+	computeStatsCmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (ComputeStatsRequest in protojson format)")
+	computeStatsCmd.Flags().StringVar(&link, "link", "", "individual sim link to use instead of the input file")
+	computeStatsCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	computeStatsCmd.Flags().StringVar(&format, "format", "json", "output format: json, or csv with the final stats of each player")
+}
+
+func computeStatsMain(cmd *cobra.Command, args []string) error {
+	input := &proto.ComputeStatsRequest{}
+	if link != "" {
+		settings, err := decodeIndividualSettings(link)
+		if err != nil {
+			return err
+		}
+		input.Raid = core.SinglePlayerRaidProto(settings.Player, settings.PartyBuffs, settings.RaidBuffs, settings.Debuffs)
+		input.Encounter = settings.Encounter
+	} else {
+		data, err := os.ReadFile(infile)
+		if err != nil {
+			return fmt.Errorf("failed to load input json file %q: %w", infile, err)
+		}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, input); err != nil {
+			return fmt.Errorf("failed to load input json file: %w", err)
+		}
+	}
+
+	result := core.ComputeStats(context.Background(), input)
+	if result.ErrorResult != "" {
+		return fmt.Errorf("compute stats failed: %s", result.ErrorResult)
+	}
+
+	var output []byte
+	var err error
+	switch format {
+	case "json":
+		output, err = protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(result)
+	case "csv":
+		output, err = computeStatsCSV(result)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(output)
+}
+
+// One row per player and stat, with the final stats after all buffs.
+func computeStatsCSV(result *proto.ComputeStatsResult) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"party", "player", "stat", "value"})
+	for partyIdx, party := range result.RaidStats.GetParties() {
+		for playerIdx, player := range party.GetPlayers() {
+			for i, value := range player.GetFinalStats().GetStats() {
+				w.Write([]string{
+					strconv.Itoa(partyIdx),
+					strconv.Itoa(playerIdx),
+					stats.Stat(i).StatName(),
+					strconv.FormatFloat(value, 'f', 2, 64),
+				})
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
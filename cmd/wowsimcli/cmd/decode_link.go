@@ -6,9 +6,11 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
 	"github.com/wowsims/sod/sim/core/proto"
 	"google.golang.org/protobuf/encoding/protojson"
 	goproto "google.golang.org/protobuf/proto"
@@ -27,28 +29,39 @@ var decodeLinkCmd = &cobra.Command{
 var errInvalidLink = errors.New("invalid wowsims export link")
 
 func decodeLink(link string) error {
+	settings, err := decodeSettings(link)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(protojson.Format(settings))
+	return nil
+}
+
+// Decodes the settings of a wowsims link. Raid sim links return RaidSimSettings, all others IndividualSimSettings.
+func decodeSettings(link string) (goproto.Message, error) {
 	parts := strings.Split(link, "#")
 	switch {
 	case len(parts) != 2:
-		return errInvalidLink
+		return nil, errInvalidLink
 	case parts[1] == "":
-		return errInvalidLink
+		return nil, errInvalidLink
 	}
 
 	raw, err := base64.StdEncoding.DecodeString(parts[1])
 	if err != nil {
-		return fmt.Errorf("cannot decode proto from link: %w", err)
+		return nil, fmt.Errorf("cannot decode proto from link: %w", err)
 	}
 
 	r, err := zlib.NewReader(bytes.NewReader(raw))
 	if err != nil {
-		return fmt.Errorf("cannot create zlib reader: %w", err)
+		return nil, fmt.Errorf("cannot create zlib reader: %w", err)
 	}
 	defer r.Close()
 
 	var buf bytes.Buffer
 	if _, err := buf.ReadFrom(r); err != nil {
-		return fmt.Errorf("reading zlib data failed: %w", err)
+		return nil, fmt.Errorf("reading zlib data failed: %w", err)
 	}
 
 	var settings goproto.Message
@@ -59,9 +72,67 @@ func decodeLink(link string) error {
 	}
 
 	if err := goproto.Unmarshal(buf.Bytes(), settings); err != nil {
-		return fmt.Errorf("cannot unmarshal raw proto: %w", err)
+		return nil, fmt.Errorf("cannot unmarshal raw proto: %w", err)
 	}
+	return settings, nil
+}
 
-	fmt.Println(protojson.Format(goproto.Message(settings)))
-	return nil
+// Decodes the settings of an individual sim link.
+func decodeIndividualSettings(link string) (*proto.IndividualSimSettings, error) {
+	settings, err := decodeSettings(link)
+	if err != nil {
+		return nil, err
+	}
+	individualSettings, ok := settings.(*proto.IndividualSimSettings)
+	if !ok {
+		return nil, errors.New("expected an individual sim link, not a raid sim link")
+	}
+	return individualSettings, nil
+}
+
+// Decodes a wowsims link into a request for running its sim. Individual sim links become a single player raid.
+func decodeRaidSimRequest(link string) (*proto.RaidSimRequest, error) {
+	settings, err := decodeSettings(link)
+	if err != nil {
+		return nil, err
+	}
+
+	switch settings := settings.(type) {
+	case *proto.IndividualSimSettings:
+		if settings.Player == nil {
+			return nil, errors.New("link has no player")
+		}
+		raid := core.SinglePlayerRaidProto(settings.Player, settings.PartyBuffs, settings.RaidBuffs, settings.Debuffs)
+		raid.Tanks = settings.Tanks
+		raid.TargetDummies = settings.TargetDummies
+		return &proto.RaidSimRequest{
+			Raid:       raid,
+			Encounter:  settings.Encounter,
+			SimOptions: simOptionsFromSettings(settings.Settings),
+		}, nil
+	case *proto.RaidSimSettings:
+		return &proto.RaidSimRequest{
+			Raid:       settings.Raid,
+			Encounter:  settings.Encounter,
+			SimOptions: simOptionsFromSettings(settings.Settings),
+		}, nil
+	}
+	return nil, errInvalidLink
+}
+
+// Loads the RaidSimRequest of a command, from its link if set, or else from its input file.
+func loadRaidSimRequest() (*proto.RaidSimRequest, error) {
+	if link != "" {
+		return decodeRaidSimRequest(link)
+	}
+
+	data, err := os.ReadFile(infile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load input json file %q: %w", infile, err)
+	}
+	input := &proto.RaidSimRequest{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, input); err != nil {
+		return nil, fmt.Errorf("failed to load input json file: %w", err)
+	}
+	return input, nil
 }
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	goproto "google.golang.org/protobuf/proto"
+)
+
+var baseURL string
+
+var encodeLinkCmd = &cobra.Command{
+	Use:   "encodelink",
+	Short: "encode a RaidSimRequest as a wowsims link/url",
+	Long:  "encode a RaidSimRequest as a wowsims link/url. Requests with a single player become individual sim links, all others raid sim links",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(infile)
+		if err != nil {
+			return fmt.Errorf("failed to load input json file %q: %w", infile, err)
+		}
+		input := &proto.RaidSimRequest{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, input); err != nil {
+			return fmt.Errorf("failed to load input json file: %w", err)
+		}
+
+		link, err := encodeLink(input, baseURL)
+		if err != nil {
+			return err
+		}
+		fmt.Println(link)
+		return nil
+	},
+}
+
+func init() {
+	encodeLinkCmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (RaidSimRequest in protojson format)")
+	encodeLinkCmd.Flags().StringVar(&baseURL, "baseurl", "https://wowsims.github.io/sod/", "url of the sims, the sim's path is appended to it")
+}
+
+var specPathRegexp = regexp.MustCompile(`([a-z])([A-Z])`)
+
+// Returns the path of the individual sim for a spec, e.g. "balance_druid" for SpecBalanceDruid.
+func specPath(spec proto.Spec) string {
+	name := strings.TrimPrefix(spec.String(), "Spec")
+	return strings.ToLower(specPathRegexp.ReplaceAllString(name, "${1}_${2}"))
+}
+
+func encodeLink(request *proto.RaidSimRequest, baseURL string) (string, error) {
+	if request.Raid == nil {
+		return "", errors.New("request has no raid")
+	}
+
+	var settings goproto.Message
+	var path string
+	simSettings := &proto.SimSettings{
+		Iterations:   request.SimOptions.GetIterations(),
+		FixedRngSeed: request.SimOptions.GetRandomSeed(),
+	}
+
+	var players []*proto.Player
+	for _, party := range request.Raid.Parties {
+		for _, player := range party.GetPlayers() {
+			if player != nil && player.Class != proto.Class_ClassUnknown {
+				players = append(players, player)
+			}
+		}
+	}
+
+	if len(players) == 1 && len(request.Raid.Parties) == 1 {
+		settings = &proto.IndividualSimSettings{
+			Settings:      simSettings,
+			RaidBuffs:     request.Raid.Buffs,
+			Debuffs:       request.Raid.Debuffs,
+			Tanks:         request.Raid.Tanks,
+			PartyBuffs:    request.Raid.Parties[0].Buffs,
+			Player:        players[0],
+			Encounter:     request.Encounter,
+			TargetDummies: request.Raid.TargetDummies,
+		}
+		path = specPath(core.PlayerProtoToSpec(players[0])) + "/"
+	} else {
+		settings = &proto.RaidSimSettings{
+			Settings:  simSettings,
+			Raid:      request.Raid,
+			Encounter: request.Encounter,
+		}
+		path = "raid/"
+	}
+
+	protoBytes, err := goproto.Marshal(settings)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal settings: %w", err)
+	}
+
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(protoBytes); err != nil {
+		return "", fmt.Errorf("cannot compress settings: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("cannot compress settings: %w", err)
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + "/" + path + "#" + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+const progressBarWidth = 40
+
+// Draws a progress bar for the latest progress on stderr, replacing the previous one.
+func printProgressBar(progress *proto.ProgressMetrics) {
+	if progress.PresimRunning {
+		fmt.Fprintf(os.Stderr, "\rRunning presims...")
+		return
+	}
+	if progress.TotalIterations == 0 {
+		return
+	}
+
+	done := min(1, float64(progress.CompletedIterations)/float64(progress.TotalIterations))
+	filled := int(done * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	var simsText string
+	if progress.TotalSims > 0 {
+		simsText = fmt.Sprintf(", %d / %d sims", progress.CompletedSims, progress.TotalSims)
+	}
+	fmt.Fprintf(os.Stderr, "\r[%s] %3.0f%% (%d / %d iterations%s)", bar, done*100, progress.CompletedIterations, progress.TotalIterations, simsText)
+}
+
+// Ends the line of the progress bar, so following output starts on a new line.
+func finishProgressBar() {
+	fmt.Fprintln(os.Stderr)
+}
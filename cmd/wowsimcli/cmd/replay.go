@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	replaySeed   int64
+	replayFormat string
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "rerun a single iteration by seed",
+	Long:  "rerun the iteration of a sim with the given seed, e.g. a min_seed or max_seed from an earlier result, and output its logs or combat events",
+	RunE:  replayMain,
+}
+
+func init() {
+	replayCmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (RaidSimRequest in protojson format)")
+	replayCmd.Flags().StringVar(&link, "link", "", "wowsims link to sim instead of the input file")
+	replayCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	replayCmd.Flags().Int64Var(&replaySeed, "seed", 0, "seed of the iteration to replay")
+	replayCmd.Flags().StringVar(&replayFormat, "format", "log", "output format: log for the debug log, ndjson for every combat event including log messages, or json for the full result")
+	replayCmd.MarkFlagsMutuallyExclusive("infile", "link")
+	replayCmd.MarkFlagRequired("seed")
+}
+
+func replayMain(cmd *cobra.Command, args []string) error {
+	if replaySeed == 0 {
+		return errors.New("--seed must not be 0")
+	}
+	input, err := loadRaidSimRequest()
+	if err != nil {
+		return err
+	}
+	if input.SimOptions == nil {
+		input.SimOptions = &proto.SimOptions{}
+	}
+	input.SimOptions.ReplaySeed = replaySeed
+
+	switch replayFormat {
+	case "log", "json":
+	case "ndjson":
+		input.SimOptions.CombatEvents = &proto.CombatEventOptions{
+			Format:      proto.CombatEventFormat_CombatEventFormatNdjson,
+			IncludeLogs: true,
+		}
+	default:
+		return fmt.Errorf("unknown format: %s", replayFormat)
+	}
+
+	result := core.RunRaidSim(context.Background(), input)
+	if result.ErrorResult != "" {
+		return fmt.Errorf("sim failed: %s", result.ErrorResult)
+	}
+
+	switch replayFormat {
+	case "log":
+		return writeTableOutput(outfile, []byte(result.Logs))
+	case "ndjson":
+		return writeTableOutput(outfile, []byte(result.CombatEventsNdjson))
+	default:
+		output, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to marshal result: %w", err)
+		}
+		return writeOutput(output)
+	}
+}
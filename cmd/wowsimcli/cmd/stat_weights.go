@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Iterations used for links which don't specify them.
+const defaultLinkIterations = 3000
+
+var (
+	link          string
+	format        string
+	weightsMetric string
+	showProgress  bool
+)
+
+var statWeightsCmd = &cobra.Command{
+	Use:   "statweights",
+	Short: "calculate stat weights and EP values",
+	Long:  "calculate stat weights and EP values, from a StatWeightsRequest or an individual sim link",
+	RunE:  statWeightsMain,
+}
+
+func init() {
+	statWeightsCmd.Flags().StringVar(&infile, "infile", "input.json", "location of input file (StatWeightsRequest in protojson format)")
+	statWeightsCmd.Flags().StringVar(&link, "link", "", "individual sim link to use instead of the input file. Weighs the stats with EP values in the link")
+	statWeightsCmd.Flags().StringVar(&outfile, "outfile", "", "location of output file, defaults to stdout")
+	statWeightsCmd.Flags().StringVar(&format, "format", "json", "output format: json, csv or pawn")
+	statWeightsCmd.Flags().StringVar(&weightsMetric, "metric", "dps", "metric to output weights for in csv and pawn formats: dps, hps, tps, dtps, tmi or pdeath")
+	statWeightsCmd.Flags().BoolVar(&showProgress, "progress", true, "show a progress bar on stderr")
+}
+
+func statWeightsMain(cmd *cobra.Command, args []string) error {
+	var input *proto.StatWeightsRequest
+	if link != "" {
+		settings, err := decodeIndividualSettings(link)
+		if err != nil {
+			return err
+		}
+		input, err = statWeightsRequestFromSettings(settings)
+		if err != nil {
+			return err
+		}
+	} else {
+		data, err := os.ReadFile(infile)
+		if err != nil {
+			return fmt.Errorf("failed to load input json file %q: %w", infile, err)
+		}
+		input = &proto.StatWeightsRequest{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, input); err != nil {
+			return fmt.Errorf("failed to load input json file: %w", err)
+		}
+	}
+
+	reporter := make(chan *proto.ProgressMetrics, 100)
+	core.StatWeightsAsync(context.Background(), input, reporter)
+
+	var finalResult *proto.StatWeightsResult
+	for progress := range reporter {
+		if progress.FinalWeightResult != nil {
+			finalResult = progress.FinalWeightResult
+			break
+		}
+		if showProgress {
+			printProgressBar(progress)
+		}
+	}
+	if showProgress {
+		finishProgressBar()
+	}
+	if finalResult.ErrorResult != "" {
+		return fmt.Errorf("stat weights failed: %s", finalResult.ErrorResult)
+	}
+
+	var output []byte
+	var err error
+	switch format {
+	case "json":
+		output, err = protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(finalResult)
+	case "csv":
+		output, err = statWeightsCSV(input, finalResult)
+	case "pawn":
+		output, err = statWeightsPawn(input, finalResult)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(output)
+}
+
+// Writes the output of a command to outfile, or stdout if it isn't set.
+func writeOutput(output []byte) error {
+	if outfile == "" {
+		fmt.Println(string(output))
+		return nil
+	}
+	if err := os.WriteFile(outfile, output, 0666); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if verbose {
+		fmt.Printf("Wrote output file: `%s` successfully.\n", outfile)
+	}
+	return nil
+}
+
+// Creates a stat weights request for the player of a link, weighing all stats with EP values in the link.
+func statWeightsRequestFromSettings(settings *proto.IndividualSimSettings) (*proto.StatWeightsRequest, error) {
+	request := &proto.StatWeightsRequest{
+		Player:          settings.Player,
+		RaidBuffs:       settings.RaidBuffs,
+		PartyBuffs:      settings.PartyBuffs,
+		Debuffs:         settings.Debuffs,
+		Encounter:       settings.Encounter,
+		Tanks:           settings.Tanks,
+		SimOptions:      simOptionsFromSettings(settings.Settings),
+		EpReferenceStat: settings.DpsRefStat,
+	}
+
+	epWeights := settings.EpWeightsStats
+	for i, ep := range epWeights.GetStats() {
+		if ep != 0 {
+			request.StatsToWeigh = append(request.StatsToWeigh, proto.Stat(i))
+		}
+	}
+	for i, ep := range epWeights.GetPseudoStats() {
+		if ep != 0 {
+			request.PseudoStatsToWeigh = append(request.PseudoStatsToWeigh, proto.PseudoStat(i))
+		}
+	}
+	if len(request.StatsToWeigh) == 0 && len(request.PseudoStatsToWeigh) == 0 {
+		return nil, errors.New("link has no EP values to choose stats to weigh, use an input file instead")
+	}
+	return request, nil
+}
+
+func simOptionsFromSettings(settings *proto.SimSettings) *proto.SimOptions {
+	iterations := settings.GetIterations()
+	if iterations <= 0 {
+		iterations = defaultLinkIterations
+	}
+	return &proto.SimOptions{
+		Iterations: iterations,
+		RandomSeed: settings.GetFixedRngSeed(),
+	}
+}
+
+// Returns the stats weighed by a request, starting with the EP reference stat.
+func weighedStats(request *proto.StatWeightsRequest) []stats.UnitStat {
+	weighed := []stats.UnitStat{stats.UnitStatFromStat(stats.Stat(request.EpReferenceStat))}
+	for _, s := range request.StatsToWeigh {
+		if s != request.EpReferenceStat {
+			weighed = append(weighed, stats.UnitStatFromStat(stats.Stat(s)))
+		}
+	}
+	for _, s := range request.PseudoStatsToWeigh {
+		weighed = append(weighed, stats.UnitStatFromPseudoStat(s))
+	}
+	return weighed
+}
+
+func unitStatName(stat stats.UnitStat) string {
+	if stat.IsStat() {
+		return stats.Stat(stat.StatIdx()).StatName()
+	}
+	return strings.TrimPrefix(proto.PseudoStat(stat.PseudoStatIdx()).String(), "PseudoStat")
+}
+
+func getUnitStat(unitStats *proto.UnitStats, stat stats.UnitStat) float64 {
+	if stat.IsStat() {
+		if idx := stat.StatIdx(); idx < len(unitStats.GetStats()) {
+			return unitStats.Stats[idx]
+		}
+	} else if idx := stat.PseudoStatIdx(); idx < len(unitStats.GetPseudoStats()) {
+		return unitStats.PseudoStats[idx]
+	}
+	return 0
+}
+
+func selectedWeights(result *proto.StatWeightsResult) (*proto.StatWeightValues, error) {
+	switch weightsMetric {
+	case "dps":
+		return result.Dps, nil
+	case "hps":
+		return result.Hps, nil
+	case "tps":
+		return result.Tps, nil
+	case "dtps":
+		return result.Dtps, nil
+	case "tmi":
+		return result.Tmi, nil
+	case "pdeath":
+		return result.PDeath, nil
+	}
+	return nil, fmt.Errorf("unknown metric %q", weightsMetric)
+}
+
+func statWeightsCSV(request *proto.StatWeightsRequest, result *proto.StatWeightsResult) ([]byte, error) {
+	values, err := selectedWeights(result)
+	if err != nil {
+		return nil, err
+	}
+
+	formatFloat := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 4, 64)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"stat", "weight", "weight_stdev", "ep", "ep_stdev"})
+	for _, stat := range weighedStats(request) {
+		w.Write([]string{
+			unitStatName(stat),
+			formatFloat(getUnitStat(values.Weights, stat)),
+			formatFloat(getUnitStat(values.WeightsStdev, stat)),
+			formatFloat(getUnitStat(values.EpValues, stat)),
+			formatFloat(getUnitStat(values.EpValuesStdev, stat)),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Stat names used by Pawn, matching the Pawn EP exporter of the UI.
+var pawnStatNames = map[proto.Stat]string{
+	proto.Stat_StatStrength:          "Strength",
+	proto.Stat_StatAgility:           "Agility",
+	proto.Stat_StatStamina:           "Stamina",
+	proto.Stat_StatIntellect:         "Intellect",
+	proto.Stat_StatSpirit:            "Spirit",
+	proto.Stat_StatSpellPower:        "SpellDamage",
+	proto.Stat_StatSpellDamage:       "SpellDamage",
+	proto.Stat_StatArcanePower:       "ArcaneSpellDamage",
+	proto.Stat_StatFirePower:         "FireSpellDamage",
+	proto.Stat_StatFrostPower:        "FrostSpellDamage",
+	proto.Stat_StatHolyPower:         "HolySpellDamage",
+	proto.Stat_StatNaturePower:       "NatureSpellDamage",
+	proto.Stat_StatShadowPower:       "ShadowSpellDamage",
+	proto.Stat_StatMP5:               "Mp5",
+	proto.Stat_StatSpellHit:          "SpellHitRating",
+	proto.Stat_StatSpellCrit:         "SpellCritRating",
+	proto.Stat_StatSpellHaste:        "SpellHasteRating",
+	proto.Stat_StatSpellPenetration:  "SpellPen",
+	proto.Stat_StatAttackPower:       "Ap",
+	proto.Stat_StatMeleeHit:          "HitRating",
+	proto.Stat_StatMeleeCrit:         "CritRating",
+	proto.Stat_StatMeleeHaste:        "HasteRating",
+	proto.Stat_StatArmorPenetration:  "ArmorPenetration",
+	proto.Stat_StatExpertise:         "ExpertiseRating",
+	proto.Stat_StatMana:              "Mana",
+	proto.Stat_StatEnergy:            "Energy",
+	proto.Stat_StatRage:              "Rage",
+	proto.Stat_StatArmor:             "Armor",
+	proto.Stat_StatRangedAttackPower: "Ap",
+	proto.Stat_StatDefense:           "DefenseRating",
+	proto.Stat_StatBlock:             "BlockRating",
+	proto.Stat_StatBlockValue:        "BlockValue",
+	proto.Stat_StatDodge:             "DodgeRating",
+	proto.Stat_StatParry:             "ParryRating",
+	proto.Stat_StatResilience:        "ResilienceRating",
+	proto.Stat_StatHealth:            "Health",
+	proto.Stat_StatArcaneResistance:  "ArcaneResistance",
+	proto.Stat_StatFireResistance:    "FireResistance",
+	proto.Stat_StatFrostResistance:   "FrostResistance",
+	proto.Stat_StatNatureResistance:  "NatureResistance",
+	proto.Stat_StatShadowResistance:  "ShadowResistance",
+	proto.Stat_StatBonusArmor:        "Armor2",
+	proto.Stat_StatHealingPower:      "Healing",
+	proto.Stat_StatFeralAttackPower:  "FeralAttackPower",
+}
+
+var pawnPseudoStatNames = map[proto.PseudoStat]string{
+	proto.PseudoStat_PseudoStatMainHandDps: "MeleeDps",
+	proto.PseudoStat_PseudoStatRangedDps:   "RangedDps",
+}
+
+// Spec names used for the Pawn scale name, matching the specNames of the UI.
+var pawnSpecNames = map[proto.Spec]string{
+	proto.Spec_SpecBalanceDruid:       "Balance Druid",
+	proto.Spec_SpecFeralDruid:         "Feral DPS Druid",
+	proto.Spec_SpecFeralTankDruid:     "Feral Tank Druid",
+	proto.Spec_SpecRestorationDruid:   "Restoration Druid",
+	proto.Spec_SpecElementalShaman:    "Elemental Shaman",
+	proto.Spec_SpecEnhancementShaman:  "Enhancement Shaman",
+	proto.Spec_SpecRestorationShaman:  "Restoration Shaman",
+	proto.Spec_SpecWardenShaman:       "Warden Shaman",
+	proto.Spec_SpecHunter:             "Hunter",
+	proto.Spec_SpecMage:               "Mage",
+	proto.Spec_SpecRogue:              "Rogue",
+	proto.Spec_SpecTankRogue:          "Tank Rogue",
+	proto.Spec_SpecHolyPaladin:        "Holy Paladin",
+	proto.Spec_SpecProtectionPaladin:  "Protection Paladin",
+	proto.Spec_SpecRetributionPaladin: "Retribution Paladin",
+	proto.Spec_SpecHealingPriest:      "Priest",
+	proto.Spec_SpecShadowPriest:       "Shadow Priest",
+	proto.Spec_SpecWarlock:            "DPS Warlock",
+	proto.Spec_SpecTankWarlock:        "Tank Warlock",
+	proto.Spec_SpecWarrior:            "DPS Warrior",
+	proto.Spec_SpecTankWarrior:        "Tank Warrior",
+}
+
+func statWeightsPawn(request *proto.StatWeightsRequest, result *proto.StatWeightsResult) ([]byte, error) {
+	values, err := selectedWeights(result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stats with the same Pawn name are added together.
+	var names []string
+	namesToEPs := map[string]float64{}
+	for _, stat := range weighedStats(request) {
+		var name string
+		if stat.IsStat() {
+			name = pawnStatNames[proto.Stat(stat.StatIdx())]
+		} else {
+			name = pawnPseudoStatNames[proto.PseudoStat(stat.PseudoStatIdx())]
+		}
+		ep := getUnitStat(values.EpValues, stat)
+		if name == "" || ep == 0 {
+			continue
+		}
+		if _, ok := namesToEPs[name]; !ok {
+			names = append(names, name)
+		}
+		namesToEPs[name] += ep
+	}
+
+	className := strings.TrimPrefix(request.Player.GetClass().String(), "Class")
+	specName := className
+	if request.Player.GetSpec() != nil {
+		specName = pawnSpecNames[core.PlayerProtoToSpec(request.Player)]
+	}
+	pawnValues := make([]string, len(names))
+	for i, name := range names {
+		pawnValues[i] = fmt.Sprintf("%s=%.3f", name, namesToEPs[name])
+	}
+	return []byte(fmt.Sprintf("( Pawn: v1: \"%s WoWSims Weights\": Class=%s,%s )", specName, className, strings.Join(pawnValues, ","))), nil
+}
@@ -0,0 +1,60 @@
+package sim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// Frozen Orb is a pet summoned and dismissed by the fire rotation, and the
+// target swap and move are added in front of it.
+func TestCombatEventsForPetsTargetsAndMovement(t *testing.T) {
+	equipment := core.GetGearSet("../ui/mage/gear_sets", "p4_fire").GearSet
+	equipment.Items[proto.ItemSlot_ItemSlotBack].Rune = int32(proto.MageRune_RuneCloakFrozenOrb)
+
+	rotation := core.GetAplRotation("../ui/mage/apls", "p4_fire").Rotation
+	rotation.PriorityList = append([]*proto.APLListItem{
+		{Action: &proto.APLAction{Action: &proto.APLAction_ChangeTarget{ChangeTarget: &proto.APLActionChangeTarget{
+			NewTarget: &proto.UnitReference{Type: proto.UnitReference_Target, Index: 1},
+		}}}},
+		{Action: &proto.APLAction{Action: &proto.APLAction_Move{Move: &proto.APLActionMove{
+			RangeFromTarget: &proto.APLValue{Value: &proto.APLValue_Const{Const: &proto.APLValueConst{Val: "10"}}},
+		}}}},
+	}, rotation.PriorityList...)
+
+	rsr := fireMageRaidSimRequest(rotation, equipment)
+	rsr.Encounter.Targets = append(rsr.Encounter.Targets, rsr.Encounter.Targets[0])
+	rsr.SimOptions.Iterations = 1
+	rsr.SimOptions.CombatEvents = &proto.CombatEventOptions{}
+
+	result := core.RunRaidSim(context.Background(), rsr)
+	if result.ErrorResult != "" {
+		t.Fatalf("Sim failed: %s", result.ErrorResult)
+	}
+
+	found := make(map[proto.CombatEventType]*proto.CombatEvent)
+	for _, event := range result.CombatEvents {
+		if found[event.Type] == nil {
+			found[event.Type] = event
+		}
+	}
+	for _, eventType := range []proto.CombatEventType{
+		proto.CombatEventType_CombatEventPetSummon,
+		proto.CombatEventType_CombatEventPetDismiss,
+		proto.CombatEventType_CombatEventTargetSwap,
+		proto.CombatEventType_CombatEventMove,
+	} {
+		if found[eventType] == nil {
+			t.Fatalf("No %s event in %d events", eventType, len(result.CombatEvents))
+		}
+	}
+
+	if event := found[proto.CombatEventType_CombatEventTargetSwap]; event.Target != "Target 2" {
+		t.Fatalf("Swapped to %s, expected Target 2", event.Target)
+	}
+	if event := found[proto.CombatEventType_CombatEventMove]; event.Amount != 10 {
+		t.Fatalf("Moved to %f, expected 10", event.Amount)
+	}
+}
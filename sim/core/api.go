@@ -11,7 +11,13 @@ import (
 /**
  * Returns character stats taking into account gear / buffs / consumes / etc
  */
-func ComputeStats(csr *proto.ComputeStatsRequest) *proto.ComputeStatsResult {
+func ComputeStats(ctx context.Context, csr *proto.ComputeStatsRequest) *proto.ComputeStatsResult {
+	if err := ctx.Err(); err != nil {
+		return &proto.ComputeStatsResult{
+			ErrorResult: err.Error(),
+		}
+	}
+
 	encounter := csr.Encounter
 	if encounter == nil {
 		encounter = &proto.Encounter{}
@@ -28,14 +34,14 @@ func ComputeStats(csr *proto.ComputeStatsRequest) *proto.ComputeStatsResult {
 /**
  * Returns stat weights and EP values, with standard deviations, for all stats.
  */
-func StatWeights(request *proto.StatWeightsRequest) *proto.StatWeightsResult {
-	result := CalcStatWeight(request, stats.Stat(request.EpReferenceStat), nil)
+func StatWeights(ctx context.Context, request *proto.StatWeightsRequest) *proto.StatWeightsResult {
+	result := CalcStatWeight(ctx, request, stats.Stat(request.EpReferenceStat), nil)
 	return result.ToProto()
 }
 
-func StatWeightsAsync(request *proto.StatWeightsRequest, progress chan *proto.ProgressMetrics) {
+func StatWeightsAsync(ctx context.Context, request *proto.StatWeightsRequest, progress chan *proto.ProgressMetrics) {
 	go func() {
-		result := CalcStatWeight(request, stats.Stat(request.EpReferenceStat), progress)
+		result := CalcStatWeight(ctx, request, stats.Stat(request.EpReferenceStat), progress)
 		progress <- &proto.ProgressMetrics{
 			FinalWeightResult: result.ToProto(),
 		}
@@ -45,16 +51,16 @@ func StatWeightsAsync(request *proto.StatWeightsRequest, progress chan *proto.Pr
 /**
  * Runs multiple iterations of the sim with a full raid.
  */
-func RunRaidSim(request *proto.RaidSimRequest) *proto.RaidSimResult {
-	return RunSim(request, nil)
+func RunRaidSim(ctx context.Context, request *proto.RaidSimRequest) *proto.RaidSimResult {
+	return RunSim(ctx, request, nil)
 }
 
-func RunRaidSimAsync(request *proto.RaidSimRequest, progress chan *proto.ProgressMetrics) {
-	go RunSim(request, progress)
+func RunRaidSimAsync(ctx context.Context, request *proto.RaidSimRequest, progress chan *proto.ProgressMetrics) {
+	go RunSim(ctx, request, progress)
 }
 
-func RunBulkSim(request *proto.BulkSimRequest) *proto.BulkSimResult {
-	return BulkSim(context.Background(), request, nil)
+func RunBulkSim(ctx context.Context, request *proto.BulkSimRequest) *proto.BulkSimResult {
+	return BulkSim(ctx, request, nil)
 }
 
 func RunBulkSimAsync(ctx context.Context, request *proto.BulkSimRequest, progress chan *proto.ProgressMetrics) {
@@ -12,6 +12,9 @@ type APLRotation struct {
 	unit           *Unit
 	prepullActions []*APLAction
 	priorityList   []*APLAction
+	variables      []*aplVariable
+	macros         []*aplMacro
+	actionLists    []*aplActionList
 
 	// Action currently controlling this rotation (only used for certain actions, such as StrictSequence).
 	controllingActions []APLActionImpl
@@ -29,11 +32,32 @@ type APLRotation struct {
 	// Used to avoid recursive APL loops.
 	inLoop bool
 
+	// Config indices of the parsed priority list rows, and their metrics, which
+	// are only set for players. Used to count how often each row is used.
+	priorityListConfigIdxs []int
+	priorityListMetrics    []*aplRowMetrics
+
+	// Metrics of the row which returned the last action from getNextAction, if any.
+	nextActionMetrics *aplRowMetrics
+
+	// Set by actions which fail to cast their spell.
+	castFailed bool
+
+	// Values which have been finalized. Macro values are shared by all their
+	// references, so they would otherwise be finalized once per reference.
+	finalizedValues map[APLValue]bool
+
 	// Validation warnings that occur during proto parsing.
 	// We return these back to the user for display in the UI.
 	curWarnings          []string
 	prepullWarnings      [][]string
 	priorityListWarnings [][]string
+	variableWarnings     [][]string
+	macroWarnings        [][]string
+	actionListWarnings   []*proto.APLActionListStats
+
+	// Lint results for the parsed rotation, also returned to the user.
+	diagnostics []*proto.APLDiagnostic
 }
 
 func (rot *APLRotation) ValidationWarning(message string, vals ...interface{}) {
@@ -75,6 +99,39 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 		unit:                 unit,
 		prepullWarnings:      make([][]string, len(config.PrepullActions)),
 		priorityListWarnings: make([][]string, len(config.PriorityList)),
+		variableWarnings:     make([][]string, len(config.Variables)),
+		macroWarnings:        make([][]string, len(config.Macros)),
+		actionListWarnings:   make([]*proto.APLActionListStats, len(config.ActionLists)),
+	}
+
+	// Parsed rows in config order, for linting.
+	var lintRows []aplLintRow
+
+	// Register macros, then parse variables and macros, so actions can reference them.
+	// Macros are parsed when first referenced, so variables can reference them too.
+	for i, macroConfig := range config.Macros {
+		rotation.doAndRecordWarnings(&rotation.macroWarnings[i], false, func() {
+			if macroConfig.Name == "" {
+				rotation.ValidationWarning("Macros must have a name")
+			} else if rotation.getMacro(macroConfig.Name) != nil {
+				rotation.ValidationWarning("Duplicate macro name: '%s'", macroConfig.Name)
+			} else {
+				rotation.macros = append(rotation.macros, &aplMacro{name: macroConfig.Name, config: macroConfig.Value, configIdx: i})
+			}
+		})
+	}
+	for i, variableConfig := range config.Variables {
+		rotation.doAndRecordWarnings(&rotation.variableWarnings[i], false, func() {
+			if variable := rotation.newAPLVariable(variableConfig); variable != nil {
+				rotation.variables = append(rotation.variables, variable)
+				if variable.initialValue != nil {
+					lintRows = append(lintRows, aplLintRow{section: proto.APLDiagnostic_SectionVariables, row: i, value: variable.initialValue})
+				}
+			}
+		})
+	}
+	for _, macro := range rotation.macros {
+		rotation.parseMacro(macro)
 	}
 
 	// Parse prepull actions
@@ -91,12 +148,20 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 						action := rotation.newAPLAction(prepullItem.Action)
 						if action != nil {
 							rotation.prepullActions = append(rotation.prepullActions, action)
+							lintRows = append(lintRows, aplLintRow{section: proto.APLDiagnostic_SectionPrepull, row: prepullIdx, action: action, doAt: doAt})
 							unit.RegisterPrepullAction(doAt, func(sim *Simulation) {
 								// Warnings for prepull cast failure are detected by running a fake prepull,
 								// so this action.Execute needs to record warnings.
+								rotation.castFailed = false
 								rotation.doAndRecordWarnings(&rotation.prepullWarnings[prepullIdx], true, func() {
 									action.Execute(sim)
 								})
+								if metrics := unit.Metrics.apl; metrics != nil {
+									row := &metrics.prepullActions[prepullIdx]
+									row.evaluations++
+									row.conditionTrue++
+									row.addExecution(sim, rotation.castFailed)
+								}
 							})
 						}
 					}
@@ -106,20 +171,76 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 	}
 
 	// Parse priority list
-	var configIdxs []int
 	for i, aplItem := range config.PriorityList {
 		rotation.doAndRecordWarnings(&rotation.priorityListWarnings[i], false, func() {
 			if !aplItem.Hide {
 				action := rotation.newAPLAction(aplItem.Action)
 				if action != nil {
 					rotation.priorityList = append(rotation.priorityList, action)
-					configIdxs = append(configIdxs, i)
+					rotation.priorityListConfigIdxs = append(rotation.priorityListConfigIdxs, i)
+					lintRows = append(lintRows, aplLintRow{section: proto.APLDiagnostic_SectionPriorityList, row: i, action: action})
 				}
 			}
 		})
 	}
 
-	// Finalize
+	// Parse action lists
+	actionListConfigIdxs := make(map[*aplActionList]int)
+	actionListItemIdxs := make(map[*APLAction]int)
+	for i, listConfig := range config.ActionLists {
+		listStats := &proto.APLActionListStats{
+			Items: make([]*proto.APLActionStats, len(listConfig.Items)),
+		}
+		rotation.actionListWarnings[i] = listStats
+		for j := range listStats.Items {
+			listStats.Items[j] = &proto.APLActionStats{}
+		}
+
+		var list *aplActionList
+		rotation.doAndRecordWarnings(&listStats.Warnings, false, func() {
+			if listConfig.Name == "" {
+				rotation.ValidationWarning("Action lists must have a name")
+			} else if rotation.getActionList(listConfig.Name) != nil {
+				rotation.ValidationWarning("Duplicate action list name: '%s'", listConfig.Name)
+			} else {
+				list = &aplActionList{name: listConfig.Name}
+			}
+		})
+		if list == nil {
+			continue
+		}
+
+		for j, aplItem := range listConfig.Items {
+			rotation.doAndRecordWarnings(&listStats.Items[j].Warnings, false, func() {
+				if !aplItem.Hide {
+					if action := rotation.newAPLAction(aplItem.Action); action != nil {
+						list.actions = append(list.actions, action)
+						actionListItemIdxs[action] = j
+						lintRows = append(lintRows, aplLintRow{section: proto.APLDiagnostic_SectionActionList, actionList: i, row: j, action: action})
+					}
+				}
+			})
+		}
+		rotation.actionLists = append(rotation.actionLists, list)
+		actionListConfigIdxs[list] = i
+	}
+
+	// Finalize, starting with macros so their warnings are recorded on the macros themselves.
+	for i, macroConfig := range config.Macros {
+		rotation.doAndRecordWarnings(&rotation.macroWarnings[i], false, func() {
+			if macro := rotation.getMacro(macroConfig.Name); macro != nil && macro.config == macroConfig.Value && macro.value != nil {
+				rotation.finalizeValues(ownMacroValues(macro.value))
+				lintRows = append(lintRows, aplLintRow{section: proto.APLDiagnostic_SectionMacros, row: i, value: macro.value})
+			}
+		})
+	}
+	for i, variableConfig := range config.Variables {
+		rotation.doAndRecordWarnings(&rotation.variableWarnings[i], false, func() {
+			if variable := rotation.getVariable(variableConfig.Name); variable != nil && variable.initialValue != nil {
+				rotation.finalizeValues(allInnerAPLValues(variable.initialValue))
+			}
+		})
+	}
 	for i, action := range rotation.prepullActions {
 		rotation.doAndRecordWarnings(&rotation.prepullWarnings[i], true, func() {
 			action.Finalize(rotation)
@@ -130,6 +251,21 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 			action.Finalize(rotation)
 		})
 	}
+	for _, list := range rotation.actionLists {
+		listStats := rotation.actionListWarnings[actionListConfigIdxs[list]]
+		for _, action := range list.actions {
+			rotation.doAndRecordWarnings(&listStats.Items[actionListItemIdxs[action]].Warnings, false, func() {
+				action.Finalize(rotation)
+			})
+		}
+		rotation.doAndRecordWarnings(&listStats.Warnings, false, func() {
+			if list.reaches(list, make(map[*aplActionList]bool)) {
+				rotation.ValidationWarning("Action list '%s' calls itself, the recursive call will be skipped", list.name)
+			}
+		})
+	}
+
+	rotation.diagnostics = rotation.lint(lintRows)
 
 	// Remove MCDs that are referenced by APL actions, so that the Autocast Other Cooldowns
 	// action does not include them.
@@ -145,16 +281,38 @@ func (unit *Unit) newAPLRotation(config *proto.APLRotation) *APLRotation {
 
 	return rotation
 }
+
+// Finalizes the values which haven't been finalized yet.
+func (rot *APLRotation) finalizeValues(values []APLValue) {
+	if rot.finalizedValues == nil {
+		rot.finalizedValues = make(map[APLValue]bool)
+	}
+	for _, value := range values {
+		if !rot.finalizedValues[value] {
+			rot.finalizedValues[value] = true
+			value.Finalize(rot)
+		}
+	}
+}
+
 func (rot *APLRotation) getStats() *proto.APLStats {
 	return &proto.APLStats{
 		PrepullActions: MapSlice(rot.prepullWarnings, func(warnings []string) *proto.APLActionStats { return &proto.APLActionStats{Warnings: warnings} }),
 		PriorityList:   MapSlice(rot.priorityListWarnings, func(warnings []string) *proto.APLActionStats { return &proto.APLActionStats{Warnings: warnings} }),
+		Variables:      MapSlice(rot.variableWarnings, func(warnings []string) *proto.APLActionStats { return &proto.APLActionStats{Warnings: warnings} }),
+		Macros:         MapSlice(rot.macroWarnings, func(warnings []string) *proto.APLActionStats { return &proto.APLActionStats{Warnings: warnings} }),
+		ActionLists:    rot.actionListWarnings,
+		Diagnostics:    rot.diagnostics,
 	}
 }
 
-// Returns all action objects as an unstructured list. Used for easily finding specific actions.
+// Returns all action objects as an unstructured list, including those of action lists. Used for easily finding specific actions.
 func (rot *APLRotation) allAPLActions() []*APLAction {
-	return Flatten(MapSlice(rot.priorityList, func(action *APLAction) []*APLAction { return action.GetAllActions() }))
+	actions := Flatten(MapSlice(rot.priorityList, func(action *APLAction) []*APLAction { return action.GetAllActions() }))
+	for _, list := range rot.actionLists {
+		actions = append(actions, Flatten(MapSlice(list.actions, func(action *APLAction) []*APLAction { return action.GetAllActions() }))...)
+	}
+	return actions
 }
 
 // Returns all action objects from the prepull as an unstructured list. Used for easily finding specific actions.
@@ -170,6 +328,12 @@ func (rot *APLRotation) reset(sim *Simulation) {
 	for _, action := range rot.allAPLActions() {
 		action.impl.Reset(sim)
 	}
+	for _, variable := range rot.variables {
+		variable.reset(sim)
+	}
+	for _, list := range rot.actionLists {
+		list.inLoop = false
+	}
 }
 
 // We intentionally try to mimic the behavior of simc APL to avoid confusion
@@ -196,7 +360,12 @@ func (apl *APLRotation) DoNextAction(sim *Simulation) {
 			panic(fmt.Sprintf("[USER_ERROR] Infinite loop detected, current action:\n%s", nextAction))
 		}
 
+		rowMetrics := apl.nextActionMetrics
+		apl.castFailed = false
 		nextAction.Execute(sim)
+		if rowMetrics != nil {
+			rowMetrics.addExecution(sim, apl.castFailed)
+		}
 	}
 	apl.inLoop = false
 
@@ -211,17 +380,13 @@ func (apl *APLRotation) DoNextAction(sim *Simulation) {
 }
 
 func (apl *APLRotation) getNextAction(sim *Simulation) *APLAction {
+	apl.nextActionMetrics = nil
 	if len(apl.controllingActions) != 0 {
 		return apl.controllingActions[len(apl.controllingActions)-1].GetNextAction(sim)
 	}
 
-	for _, action := range apl.priorityList {
-		if action.IsReady(sim) {
-			return action
-		}
-	}
-
-	return nil
+	nextAction, _ := apl.getNextActionInList(sim, apl.priorityList, apl.priorityListMetrics)
+	return nextAction
 }
 
 func (apl *APLRotation) pushControllingAction(ca APLActionImpl) {
@@ -13,9 +13,7 @@ type APLAction struct {
 
 func (action *APLAction) Finalize(rot *APLRotation) {
 	action.impl.Finalize(rot)
-	for _, value := range action.GetAllAPLValues() {
-		value.Finalize(rot)
-	}
+	rot.finalizeValues(action.GetAllAPLValues())
 }
 
 func (action *APLAction) IsReady(sim *Simulation) bool {
@@ -37,19 +35,28 @@ func (action *APLAction) GetAllActions() []*APLAction {
 func (action *APLAction) GetAllAPLValues() []APLValue {
 	var values []APLValue
 	for _, a := range action.GetAllActions() {
-		unprocessed := a.impl.GetAPLValues()
+		values = append(values, a.impl.GetAPLValues()...)
 		if a.condition != nil {
-			unprocessed = append(unprocessed, a.condition)
+			values = append(values, a.condition)
 		}
+	}
+	return allInnerAPLValues(values...)
+}
 
-		for len(unprocessed) > 0 {
-			next := unprocessed[len(unprocessed)-1]
-			unprocessed = unprocessed[:len(unprocessed)-1]
-			values = append(values, next)
-			unprocessed = append(unprocessed, next.GetInnerValues()...)
+// Returns the given APLValues along with all of their inner values.
+func allInnerAPLValues(roots ...APLValue) []APLValue {
+	var values []APLValue
+	unprocessed := roots
+	for len(unprocessed) > 0 {
+		next := unprocessed[len(unprocessed)-1]
+		unprocessed = unprocessed[:len(unprocessed)-1]
+		if next == nil {
+			continue
 		}
+		values = append(values, next)
+		unprocessed = append(unprocessed, next.GetInnerValues()...)
 	}
-	return FilterSlice(values, func(val APLValue) bool { return val != nil })
+	return values
 }
 
 func (action *APLAction) GetAllSpells() []*Spell {
@@ -166,6 +173,12 @@ func (rot *APLRotation) newAPLActionImpl(config *proto.APLAction) APLActionImpl
 	case *proto.APLAction_StrictSequence:
 		return rot.newActionStrictSequence(config.GetStrictSequence())
 
+	// Action lists
+	case *proto.APLAction_CallActionList:
+		return rot.newActionCallActionList(config.GetCallActionList())
+	case *proto.APLAction_RunActionList:
+		return rot.newActionRunActionList(config.GetRunActionList())
+
 	// Misc
 	case *proto.APLAction_ChangeTarget:
 		return rot.newActionChangeTarget(config.GetChangeTarget())
@@ -185,6 +198,8 @@ func (rot *APLRotation) newAPLActionImpl(config *proto.APLAction) APLActionImpl
 		return rot.newActionCustomRotation(config.GetCustomRotation())
 	case *proto.APLAction_AddComboPoints:
 		return rot.newActionAddComboPoints(config.GetAddComboPoints())
+	case *proto.APLAction_SetVariable:
+		return rot.newActionSetVariable(config.GetSetVariable())
 	default:
 		return nil
 	}
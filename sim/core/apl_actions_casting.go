@@ -30,7 +30,7 @@ func (action *APLActionCastSpell) IsReady(sim *Simulation) bool {
 	return action.spell.CanCast(sim, action.target.Get()) && (!action.spell.Flags.Matches(SpellFlagMCD) || action.spell.Unit.GCD.IsReady(sim) || action.spell.DefaultCast.GCD == 0)
 }
 func (action *APLActionCastSpell) Execute(sim *Simulation) {
-	action.spell.Cast(sim, action.target.Get())
+	castAPLSpell(sim, action.spell, action.target.Get())
 }
 func (action *APLActionCastSpell) String() string {
 	return fmt.Sprintf("Cast Spell(%s)", action.spell.ActionID)
@@ -83,7 +83,7 @@ func (action *APLActionChannelSpell) IsReady(sim *Simulation) bool {
 	return action.spell.CanCast(sim, action.target.Get())
 }
 func (action *APLActionChannelSpell) Execute(sim *Simulation) {
-	action.spell.Cast(sim, action.target.Get())
+	castAPLSpell(sim, action.spell, action.target.Get())
 
 	if action.instantInterrupt {
 		dot := action.spell.Unit.ChanneledDot
@@ -168,7 +168,7 @@ func (action *APLActionMultidot) IsReady(sim *Simulation) bool {
 	return false
 }
 func (action *APLActionMultidot) Execute(sim *Simulation) {
-	action.spell.Cast(sim, action.nextTarget)
+	castAPLSpell(sim, action.spell, action.nextTarget)
 }
 func (action *APLActionMultidot) String() string {
 	return fmt.Sprintf("Multidot(%s)", action.spell.ActionID)
@@ -229,7 +229,7 @@ func (action *APLActionMultishield) IsReady(sim *Simulation) bool {
 	return false
 }
 func (action *APLActionMultishield) Execute(sim *Simulation) {
-	action.spell.Cast(sim, action.nextTarget)
+	castAPLSpell(sim, action.spell, action.nextTarget)
 }
 func (action *APLActionMultishield) String() string {
 	return fmt.Sprintf("Multishield(%s)", action.spell.ActionID)
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+type aplActionList struct {
+	name    string
+	actions []*APLAction
+
+	// Used to avoid lists which call each other recursively, like APLRotation.inLoop.
+	inLoop bool
+}
+
+func (rot *APLRotation) getActionList(name string) *aplActionList {
+	for _, list := range rot.actionLists {
+		if list.name == name {
+			return list
+		}
+	}
+	return nil
+}
+
+// Returns the first ready action of the list, and whether a Run Action List
+// was reached. Returns nil if the list is already being evaluated.
+func (list *aplActionList) getNextAction(sim *Simulation, rot *APLRotation) (*APLAction, bool) {
+	if list.inLoop {
+		return nil, false
+	}
+	list.inLoop = true
+	nextAction, stop := rot.getNextActionInList(sim, list.actions, nil)
+	list.inLoop = false
+	return nextAction, stop
+}
+
+// Whether the list, or any list it calls or runs, calls or runs target.
+func (list *aplActionList) reaches(target *aplActionList, visited map[*aplActionList]bool) bool {
+	if visited[list] {
+		return false
+	}
+	visited[list] = true
+	for _, action := range list.actions {
+		for _, inner := range action.GetAllActions() {
+			var innerList *aplActionList
+			switch impl := inner.impl.(type) {
+			case *APLActionCallActionList:
+				innerList = impl.list
+			case *APLActionRunActionList:
+				innerList = impl.list
+			}
+			if innerList != nil && (innerList == target || innerList.reaches(target, visited)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the first ready action of actions, descending into the lists of Call
+// and Run Action List actions. Returns true if a Run Action List was reached, in
+// which case the remaining actions of all calling lists are skipped. If metrics
+// is set, it holds the metrics of each action, and those of the row which
+// returned the action are saved as rot.nextActionMetrics.
+func (rot *APLRotation) getNextActionInList(sim *Simulation, actions []*APLAction, metrics []*aplRowMetrics) (*APLAction, bool) {
+	for i, action := range actions {
+		var row *aplRowMetrics
+		if metrics != nil {
+			row = metrics[i]
+			row.evaluations++
+		}
+
+		switch impl := action.impl.(type) {
+		case *APLActionCallActionList:
+			if impl.list != nil && action.conditionMet(sim, row) {
+				if nextAction, stop := impl.list.getNextAction(sim, rot); nextAction != nil || stop {
+					rot.nextActionMetrics = row
+					return nextAction, stop
+				}
+			}
+		case *APLActionRunActionList:
+			if impl.list != nil && action.conditionMet(sim, row) {
+				nextAction, _ := impl.list.getNextAction(sim, rot)
+				rot.nextActionMetrics = row
+				return nextAction, true
+			}
+		default:
+			if action.conditionMet(sim, row) && action.impl.IsReady(sim) {
+				rot.nextActionMetrics = row
+				return action, false
+			}
+		}
+	}
+	return nil, false
+}
+
+type APLActionCallActionList struct {
+	defaultAPLActionImpl
+	rot  *APLRotation
+	name string
+	list *aplActionList
+}
+
+func (rot *APLRotation) newActionCallActionList(config *proto.APLActionCallActionList) APLActionImpl {
+	if config.Name == "" {
+		rot.ValidationWarning("Call Action List must provide a list name")
+		return nil
+	}
+	return &APLActionCallActionList{
+		rot:  rot,
+		name: config.Name,
+	}
+}
+func (action *APLActionCallActionList) Finalize(rot *APLRotation) {
+	if action.list = rot.getActionList(action.name); action.list == nil {
+		rot.ValidationWarning("No action list with name: '%s'", action.name)
+	}
+}
+
+// Only used when nested in other actions, e.g. sequences. In a priority list
+// or action list, the rotation descends into the list directly.
+func (action *APLActionCallActionList) IsReady(sim *Simulation) bool {
+	if action.list == nil {
+		return false
+	}
+	nextAction, _ := action.list.getNextAction(sim, action.rot)
+	return nextAction != nil
+}
+func (action *APLActionCallActionList) Execute(sim *Simulation) {
+	if nextAction, _ := action.list.getNextAction(sim, action.rot); nextAction != nil {
+		nextAction.Execute(sim)
+	}
+}
+func (action *APLActionCallActionList) String() string {
+	return fmt.Sprintf("Call Action List(%s)", action.name)
+}
+
+type APLActionRunActionList struct {
+	defaultAPLActionImpl
+	rot  *APLRotation
+	name string
+	list *aplActionList
+}
+
+func (rot *APLRotation) newActionRunActionList(config *proto.APLActionRunActionList) APLActionImpl {
+	if config.Name == "" {
+		rot.ValidationWarning("Run Action List must provide a list name")
+		return nil
+	}
+	return &APLActionRunActionList{
+		rot:  rot,
+		name: config.Name,
+	}
+}
+func (action *APLActionRunActionList) Finalize(rot *APLRotation) {
+	if action.list = rot.getActionList(action.name); action.list == nil {
+		rot.ValidationWarning("No action list with name: '%s'", action.name)
+	}
+}
+
+// Only used when nested in other actions, e.g. sequences, where this behaves
+// like Call Action List since the actions after it can't be skipped.
+func (action *APLActionRunActionList) IsReady(sim *Simulation) bool {
+	if action.list == nil {
+		return false
+	}
+	nextAction, _ := action.list.getNextAction(sim, action.rot)
+	return nextAction != nil
+}
+func (action *APLActionRunActionList) Execute(sim *Simulation) {
+	if nextAction, _ := action.list.getNextAction(sim, action.rot); nextAction != nil {
+		nextAction.Execute(sim)
+	}
+}
+func (action *APLActionRunActionList) String() string {
+	return fmt.Sprintf("Run Action List(%s)", action.name)
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"testing"
+)
+
+type testAPLAction struct {
+	defaultAPLActionImpl
+	ready bool
+}
+
+func (action *testAPLAction) IsReady(*Simulation) bool { return action.ready }
+func (action *testAPLAction) Execute(*Simulation)      {}
+func (action *testAPLAction) String() string           { return "Test Action" }
+
+func TestAPLActionLists(t *testing.T) {
+	sim := &Simulation{}
+	rot := &APLRotation{}
+
+	notReady := &APLAction{impl: &testAPLAction{ready: false}}
+	ready := &APLAction{impl: &testAPLAction{ready: true}}
+	fallback := &APLAction{impl: &testAPLAction{ready: true}}
+
+	emptyList := &aplActionList{name: "empty", actions: []*APLAction{notReady}}
+	readyList := &aplActionList{name: "ready", actions: []*APLAction{notReady, ready}}
+	recursiveList := &aplActionList{name: "recursive"}
+	recursiveList.actions = []*APLAction{{impl: &APLActionCallActionList{rot: rot, name: "recursive", list: recursiveList}}}
+	rot.actionLists = []*aplActionList{emptyList, readyList, recursiveList}
+
+	callList := func(list *aplActionList) *APLAction {
+		return &APLAction{impl: &APLActionCallActionList{rot: rot, name: list.name, list: list}}
+	}
+	runList := func(list *aplActionList) *APLAction {
+		return &APLAction{impl: &APLActionRunActionList{rot: rot, name: list.name, list: list}}
+	}
+
+	if next, _ := rot.getNextActionInList(sim, []*APLAction{callList(emptyList), fallback}, nil); next != fallback {
+		t.Fatalf("Call Action List should fall through when no action of the list is ready")
+	}
+	if next, _ := rot.getNextActionInList(sim, []*APLAction{callList(readyList), fallback}, nil); next != ready {
+		t.Fatalf("Call Action List should return the first ready action of the list")
+	}
+	if next, stop := rot.getNextActionInList(sim, []*APLAction{runList(emptyList), fallback}, nil); next != nil || !stop {
+		t.Fatalf("Run Action List should skip the remaining actions when no action of the list is ready")
+	}
+	if next, stop := rot.getNextActionInList(sim, []*APLAction{callList(readyList), runList(emptyList), fallback}, nil); next != ready || stop {
+		t.Fatalf("Run Action List should only be reached when earlier actions aren't ready")
+	}
+	if next, _ := rot.getNextActionInList(sim, []*APLAction{callList(recursiveList), fallback}, nil); next != fallback {
+		t.Fatalf("Recursive calls should be skipped")
+	}
+	if !recursiveList.reaches(recursiveList, make(map[*aplActionList]bool)) || readyList.reaches(readyList, make(map[*aplActionList]bool)) {
+		t.Fatalf("Only the recursive list should reach itself")
+	}
+}
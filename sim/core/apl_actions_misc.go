@@ -33,6 +33,7 @@ func (action *APLActionChangeTarget) IsReady(sim *Simulation) bool {
 func (action *APLActionChangeTarget) Execute(sim *Simulation) {
 	if sim.Log != nil {
 		action.unit.Log(sim, "Changing target to %s", action.newTarget.Get().Label)
+		action.unit.emitUnitEvent(sim, proto.CombatEventType_CombatEventTargetSwap, action.newTarget.Get(), 0)
 	}
 	action.unit.CurrentTarget = action.newTarget.Get()
 }
@@ -272,6 +273,7 @@ func (action *APLActionMove) Execute(sim *Simulation) {
 	moveRange := action.moveRange.GetFloat(sim)
 	if sim.Log != nil {
 		action.unit.Log(sim, "Moving to %s", moveRange)
+		action.unit.emitUnitEvent(sim, proto.CombatEventType_CombatEventMove, action.unit.CurrentTarget, moveRange)
 	}
 
 	action.unit.MoveTo(moveRange, sim)
@@ -0,0 +1,305 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// A parsed row of the rotation, along with its position in the config.
+type aplLintRow struct {
+	section    proto.APLDiagnostic_Section
+	actionList int
+	row        int
+
+	action *APLAction // Set for prepull, priority list and action list rows.
+	value  APLValue   // Set for variable and macro rows.
+	doAt   time.Duration
+}
+
+// Looks for rows which are valid but probably don't do what was intended, e.g.
+// rows that can never be reached. Must be called after the rotation is finalized.
+func (rot *APLRotation) lint(rows []aplLintRow) []*proto.APLDiagnostic {
+	var diagnostics []*proto.APLDiagnostic
+	report := func(row aplLintRow, check proto.APLDiagnostic_Check, message string, vals ...interface{}) {
+		diagnostics = append(diagnostics, &proto.APLDiagnostic{
+			Check:      check,
+			Section:    row.section,
+			Row:        int32(row.row),
+			ActionList: int32(row.actionList),
+			Message:    fmt.Sprintf(message, vals...),
+		})
+	}
+
+	resetSequences := make(map[*APLActionSequence]bool)
+	for _, row := range rows {
+		if row.action == nil {
+			continue
+		}
+		for _, action := range row.action.GetAllActions() {
+			if reset, ok := action.impl.(*APLActionResetSequence); ok && reset.sequence != nil {
+				resetSequences[reset.sequence] = true
+			}
+		}
+	}
+
+	// Values of macros are checked on the macro's row, instead of every row referencing it.
+	macroValues := make(map[APLValue]bool)
+	for _, macro := range rot.macros {
+		for _, value := range allInnerAPLValues(macro.value) {
+			macroValues[value] = true
+		}
+	}
+
+	var prevPrepull *aplLintRow
+	var prevRows []*APLAction // Earlier rows of the current list which always run when ready.
+	for i, row := range rows {
+		if i > 0 && (row.section != rows[i-1].section || row.actionList != rows[i-1].actionList) {
+			prevRows = nil
+		}
+
+		var values []APLValue
+		if row.action != nil {
+			values = row.action.GetAllAPLValues()
+		} else {
+			values = allInnerAPLValues(row.value)
+		}
+		for _, value := range values {
+			if row.action != nil && macroValues[value] {
+				continue
+			}
+			if message := rot.lintTypeMismatch(value); message != "" {
+				report(row, proto.APLDiagnostic_CheckTypeMismatch, "%s", message)
+			}
+		}
+
+		if row.action == nil {
+			continue
+		}
+
+		for _, action := range row.action.GetAllActions() {
+			if constVal, ok := aplLintConstantBool(action.condition); ok {
+				if constVal {
+					report(row, proto.APLDiagnostic_CheckConstantCondition, "Condition '%s' is always true", action.condition)
+				} else {
+					report(row, proto.APLDiagnostic_CheckConstantCondition, "Condition '%s' is always false, so %s never runs", action.condition, action.impl)
+				}
+			}
+
+			switch impl := action.impl.(type) {
+			case *APLActionWait:
+				if aplLintIsConstant(impl.duration, false) && aplLintEval(func() bool { return impl.duration.GetDuration(nil) <= 0 }) {
+					report(row, proto.APLDiagnostic_CheckConstantCondition, "Wait duration '%s' is never positive, so %s never runs", impl.duration, impl)
+				}
+			case *APLActionWaitUntil:
+				if constVal, ok := aplLintConstantBool(impl.condition); ok && constVal {
+					report(row, proto.APLDiagnostic_CheckConstantCondition, "Wait condition '%s' is always true, so %s never runs", impl.condition, impl)
+				} else if aplLintIsConstant(impl.condition, true) {
+					report(row, proto.APLDiagnostic_CheckWaitDeadlock, "Wait condition '%s' can't change while waiting, so %s can wait forever", impl.condition, impl)
+				}
+			case *APLActionSequence:
+				if impl.name != "" && !resetSequences[impl] {
+					report(row, proto.APLDiagnostic_CheckSequenceNeverReset, "Sequence '%s' is never reset, so it only runs once per iteration", impl.name)
+				}
+			}
+		}
+
+		switch row.section {
+		case proto.APLDiagnostic_SectionPrepull:
+			if prevPrepull != nil && row.doAt < prevPrepull.doAt {
+				report(row, proto.APLDiagnostic_CheckPrepullOrder, "Runs at %s, before the previous prepull action at %s. Prepull actions run in time order, not list order", row.doAt, prevPrepull.doAt)
+			}
+			prevPrepull = &rows[i]
+		case proto.APLDiagnostic_SectionPriorityList, proto.APLDiagnostic_SectionActionList:
+			for _, prevRow := range prevRows {
+				if aplLintShadows(prevRow, row.action) {
+					report(row, proto.APLDiagnostic_CheckUnreachable, "Never runs, because the earlier row %s always runs first", prevRow.impl)
+					break
+				}
+			}
+			if constVal, ok := aplLintConstantBool(row.action.condition); row.action.condition == nil || (ok && constVal) {
+				prevRows = append(prevRows, row.action)
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+// Whether later is never ready when earlier, an action without a condition, is
+// not ready, or stops the list whenever it is reached.
+func aplLintShadows(earlier *APLAction, later *APLAction) bool {
+	switch earlierImpl := earlier.impl.(type) {
+	case *APLActionRunActionList:
+		return earlierImpl.list != nil
+	case *APLActionWait:
+		return aplLintIsConstant(earlierImpl.duration, false) && aplLintEval(func() bool { return earlierImpl.duration.GetDuration(nil) > 0 })
+	case *APLActionCastSpell:
+		laterImpl, ok := later.impl.(*APLActionCastSpell)
+		if !ok {
+			return false
+		}
+		if earlierImpl.spell == laterImpl.spell && earlierImpl.target == laterImpl.target {
+			return true
+		}
+
+		// A spell without costs, cooldowns or cast conditions is only held back
+		// by the GCD, casting, and moving for spells with a cast time.
+		spell, laterSpell := earlierImpl.spell, laterImpl.spell
+		if spell.ExtraCastCondition != nil || spell.Cost != nil || spell.CD.Timer != nil || spell.SharedCD.Timer != nil {
+			return false
+		}
+		return !laterSpell.Flags.Matches(SpellFlagCastWhileCasting) &&
+			(spell.DefaultCast.GCD == 0 || laterSpell.DefaultCast.GCD > 0) &&
+			(spell.DefaultCast.CastTime == 0 || laterSpell.DefaultCast.CastTime > 0)
+	}
+	return false
+}
+
+// Whether value always evaluates to the same result. If allowVariables is true,
+// variables are treated as constants, since they only change when set by an action.
+func aplLintIsConstant(value APLValue, allowVariables bool) bool {
+	switch value := value.(type) {
+	case *APLValueConst:
+		return true
+	case *APLValueVariable:
+		return allowVariables
+	case *APLValueMacro:
+		return aplLintIsConstant(value.inner, allowVariables)
+	case *APLValueCoerced, *APLValueCompare, *APLValueMath, *APLValueMax, *APLValueMin, *APLValueAnd, *APLValueOr, *APLValueNot:
+		for _, inner := range value.GetInnerValues() {
+			if !aplLintIsConstant(inner, allowVariables) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Returns the result of a Bool value if it is the same on every evaluation, and
+// whether it is. And/Or values are folded if any operand decides the result.
+func aplLintConstantBool(value APLValue) (bool, bool) {
+	switch value := value.(type) {
+	case nil:
+		return false, false
+	case *APLValueAnd, *APLValueOr:
+		_, isOr := value.(*APLValueOr)
+		allConstant := true
+		for _, inner := range value.GetInnerValues() {
+			innerVal, ok := aplLintConstantBool(inner)
+			if ok && innerVal == isOr {
+				return isOr, true
+			}
+			allConstant = allConstant && ok
+		}
+		return !isOr, allConstant
+	case *APLValueNot:
+		innerVal, ok := aplLintConstantBool(value.val)
+		return !innerVal, ok
+	case *APLValueMacro:
+		return aplLintConstantBool(value.inner)
+	case *APLValueCoerced:
+		if value.inner.Type() == proto.APLValueType_ValueTypeBool {
+			return aplLintConstantBool(value.inner)
+		}
+	}
+
+	if !aplLintIsConstant(value, false) {
+		return false, false
+	}
+	var result bool
+	ok := aplLintEval(func() bool {
+		result = value.GetBool(nil)
+		return true
+	})
+	return result, ok
+}
+
+// Evaluates fn, which evaluates constant values without a Simulation. Returns
+// false if the evaluation panics, e.g. for an integer division by zero.
+func aplLintEval(fn func() bool) (result bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = false
+		}
+	}()
+	return fn()
+}
+
+// Returns a description of value's operands having types which don't make sense
+// together, or "" if they do. Operands are coerced to the same type when parsed,
+// so this looks at their types from before the coercion.
+func (rot *APLRotation) lintTypeMismatch(value APLValue) string {
+	var operands []APLValue
+	switch value := value.(type) {
+	case *APLValueCompare:
+		operands = []APLValue{value.lhs, value.rhs}
+	case *APLValueMath:
+		if value.op == proto.APLValueMath_OpAdd || value.op == proto.APLValueMath_OpSub {
+			operands = []APLValue{value.lhs, value.rhs}
+		}
+	case *APLValueMax:
+		operands = value.vals
+	case *APLValueMin:
+		operands = value.vals
+	}
+
+	for i := 1; i < len(operands); i++ {
+		lhsType, lhsConst := rot.lintOriginalType(operands[0])
+		rhsType, rhsConst := rot.lintOriginalType(operands[i])
+		if !aplLintTypesMatch(lhsType, lhsConst, rhsType, rhsConst) && !aplLintTypesMatch(rhsType, rhsConst, lhsType, lhsConst) {
+			return fmt.Sprintf("Mixes a %s with a %s in '%s'", aplLintTypeName(lhsType, lhsConst), aplLintTypeName(rhsType, rhsConst), value)
+		}
+	}
+	return ""
+}
+
+// Returns the type of value before it was coerced, and the text of the constant
+// it came from, if any.
+func (rot *APLRotation) lintOriginalType(value APLValue) (proto.APLValueType, string) {
+	if coerced, ok := value.(*APLValueCoerced); ok {
+		value = coerced.inner
+	}
+	if constVal, ok := value.(*APLValueConst); ok {
+		return rot.newValueConst(&proto.APLValueConst{Val: constVal.stringVal}).Type(), constVal.stringVal
+	}
+	return value.Type(), ""
+}
+
+func aplLintTypesMatch(lhsType proto.APLValueType, lhsConst string, rhsType proto.APLValueType, rhsConst string) bool {
+	isNumber := func(valType proto.APLValueType) bool {
+		return valType == proto.APLValueType_ValueTypeInt || valType == proto.APLValueType_ValueTypeFloat
+	}
+	isPercent := strings.HasSuffix(lhsConst, "%") || strings.HasSuffix(rhsConst, "%")
+
+	switch {
+	case lhsType == rhsType:
+		return true
+	case isNumber(lhsType) && isNumber(rhsType):
+		return true
+	case lhsType == proto.APLValueType_ValueTypeDuration:
+		// Plain numbers are durations in seconds, but only when written as constants.
+		return isNumber(rhsType) && rhsConst != "" && !isPercent
+	}
+	return false
+}
+
+func aplLintTypeName(valType proto.APLValueType, constVal string) string {
+	switch valType {
+	case proto.APLValueType_ValueTypeInt, proto.APLValueType_ValueTypeFloat:
+		if strings.HasSuffix(constVal, "%") {
+			return "percentage"
+		}
+		return "number"
+	case proto.APLValueType_ValueTypeDuration:
+		return "duration"
+	case proto.APLValueType_ValueTypeString:
+		return "string"
+	case proto.APLValueType_ValueTypeBool:
+		return "bool"
+	}
+	return "value"
+}
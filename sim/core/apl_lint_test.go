@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+func TestAPLLint(t *testing.T) {
+	config, err := ParseAPLText(`prepull -1s: wait(1s)
+prepull -2s: wait(1s)
+
+wait(1s) if current_time < 20%
+wait(1s) if current_time < 1 && gcd_ready
+wait(1s) if gcd_ready && false
+wait_until(1s > 2s)
+sequence(opener, [wait(1s)]) if gcd_ready
+sequence(reset, [wait(1s)]) if gcd_ready
+reset_sequence(reset) if gcd_ready
+wait(1s) if !gcd_ready || !false
+wait(2s) if gcd_ready
+`, nil)
+	if err != nil {
+		t.Fatalf("Failed to parse rotation: %s", err)
+	}
+
+	// Actions are parsed for a unit with an agent, so use a target.
+	target := &Target{}
+	env := &Environment{Raid: &Raid{}, Encounter: Encounter{Targets: []*Target{target}}}
+	target.Env = env
+	rot := &APLRotation{unit: &target.Unit}
+	var rows []aplLintRow
+	for i, item := range config.PrepullActions {
+		action := rot.newAPLAction(item.Action)
+		rot.prepullActions = append(rot.prepullActions, action)
+		rows = append(rows, aplLintRow{section: proto.APLDiagnostic_SectionPrepull, row: i, action: action, doAt: rot.newAPLValue(item.DoAtValue).GetDuration(nil)})
+	}
+	for i, item := range config.PriorityList {
+		action := rot.newAPLAction(item.Action)
+		rot.priorityList = append(rot.priorityList, action)
+		rows = append(rows, aplLintRow{section: proto.APLDiagnostic_SectionPriorityList, row: i, action: action})
+	}
+	for _, action := range rot.priorityList {
+		action.Finalize(rot)
+	}
+
+	type diagnosticKey struct {
+		section proto.APLDiagnostic_Section
+		row     int32
+		check   proto.APLDiagnostic_Check
+	}
+	expected := []diagnosticKey{
+		{proto.APLDiagnostic_SectionPrepull, 1, proto.APLDiagnostic_CheckPrepullOrder},
+		{proto.APLDiagnostic_SectionPriorityList, 0, proto.APLDiagnostic_CheckTypeMismatch},
+		{proto.APLDiagnostic_SectionPriorityList, 2, proto.APLDiagnostic_CheckConstantCondition},
+		{proto.APLDiagnostic_SectionPriorityList, 3, proto.APLDiagnostic_CheckWaitDeadlock},
+		{proto.APLDiagnostic_SectionPriorityList, 4, proto.APLDiagnostic_CheckSequenceNeverReset},
+		{proto.APLDiagnostic_SectionPriorityList, 7, proto.APLDiagnostic_CheckConstantCondition},
+		{proto.APLDiagnostic_SectionPriorityList, 8, proto.APLDiagnostic_CheckUnreachable},
+	}
+
+	diagnostics := rot.lint(rows)
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, diagnostic := range diagnostics {
+		if key := (diagnosticKey{diagnostic.Section, diagnostic.Row, diagnostic.Check}); key != expected[i] {
+			t.Fatalf("Expected diagnostic %v, got %v", expected[i], diagnostic)
+		}
+	}
+}
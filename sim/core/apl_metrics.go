@@ -0,0 +1,127 @@
+package core
+
+import (
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// aplMetrics counts how often the rows of a player's rotation were checked and
+// performed. Rows are indexed like the config, including hidden rows, and the
+// values are sums across iterations.
+type aplMetrics struct {
+	prepullActions []aplRowMetrics
+	priorityList   []aplRowMetrics
+	iterations     int32
+}
+
+type aplRowMetrics struct {
+	evaluations   int64
+	conditionTrue int64
+	executions    int64
+	castFailures  int64
+
+	// Whether the row was performed in the current iteration.
+	executed bool
+
+	iterationsExecuted int32
+	firstExecutionSum  float64 // Seconds, over the iterations in which the row was performed.
+}
+
+// Creates the metrics for the rows of rot, and points its parsed rows at them.
+func (rot *APLRotation) newAPLMetrics() *aplMetrics {
+	metrics := &aplMetrics{
+		prepullActions: make([]aplRowMetrics, len(rot.prepullWarnings)),
+		priorityList:   make([]aplRowMetrics, len(rot.priorityListWarnings)),
+	}
+	rot.priorityListMetrics = MapSlice(rot.priorityListConfigIdxs, func(configIdx int) *aplRowMetrics {
+		return &metrics.priorityList[configIdx]
+	})
+	return metrics
+}
+
+// Records that the row's action was performed.
+func (row *aplRowMetrics) addExecution(sim *Simulation, castFailed bool) {
+	row.executions++
+	if castFailed {
+		row.castFailures++
+	}
+	if !row.executed {
+		row.executed = true
+		row.iterationsExecuted++
+		row.firstExecutionSum += sim.CurrentTime.Seconds()
+	}
+}
+
+func (metrics *aplMetrics) reset() {
+	for i := range metrics.prepullActions {
+		metrics.prepullActions[i].executed = false
+	}
+	for i := range metrics.priorityList {
+		metrics.priorityList[i].executed = false
+	}
+}
+
+func (metrics *aplMetrics) doneIteration() {
+	metrics.iterations++
+}
+
+// Adds the values of the same unit's metrics in another Environment.
+func (metrics *aplMetrics) merge(other *aplMetrics) {
+	metrics.iterations += other.iterations
+	for i := range metrics.prepullActions {
+		metrics.prepullActions[i].merge(&other.prepullActions[i])
+	}
+	for i := range metrics.priorityList {
+		metrics.priorityList[i].merge(&other.priorityList[i])
+	}
+}
+
+func (row *aplRowMetrics) merge(other *aplRowMetrics) {
+	row.evaluations += other.evaluations
+	row.conditionTrue += other.conditionTrue
+	row.executions += other.executions
+	row.castFailures += other.castFailures
+	row.iterationsExecuted += other.iterationsExecuted
+	row.firstExecutionSum += other.firstExecutionSum
+}
+
+func (metrics *aplMetrics) ToProto() *proto.APLMetrics {
+	return &proto.APLMetrics{
+		PrepullActions: MapSlice(metrics.prepullActions, func(row aplRowMetrics) *proto.APLRowMetrics { return row.ToProto(metrics.iterations) }),
+		PriorityList:   MapSlice(metrics.priorityList, func(row aplRowMetrics) *proto.APLRowMetrics { return row.ToProto(metrics.iterations) }),
+	}
+}
+
+func (row *aplRowMetrics) ToProto(iterations int32) *proto.APLRowMetrics {
+	rowProto := &proto.APLRowMetrics{}
+	if iterations > 0 {
+		n := float64(iterations)
+		rowProto.Evaluations = float64(row.evaluations) / n
+		rowProto.ConditionTrue = float64(row.conditionTrue) / n
+		rowProto.Executions = float64(row.executions) / n
+		rowProto.CastFailures = float64(row.castFailures) / n
+		rowProto.ExecutionRate = float64(row.iterationsExecuted) / n
+	}
+	if row.iterationsExecuted > 0 {
+		rowProto.FirstExecutionAvg = row.firstExecutionSum / float64(row.iterationsExecuted)
+	}
+	return rowProto
+}
+
+// Returns whether the condition of action is met, counting it in row if set.
+func (action *APLAction) conditionMet(sim *Simulation, row *aplRowMetrics) bool {
+	if action.condition != nil && !action.condition.GetBool(sim) {
+		return false
+	}
+	if row != nil {
+		row.conditionTrue++
+	}
+	return true
+}
+
+// Casts spell for an APL action, so that failed casts are counted in the metrics
+// of the row being performed.
+func castAPLSpell(sim *Simulation, spell *Spell, target *Unit) {
+	if !spell.Cast(sim, target) && spell.Unit.Rotation != nil {
+		spell.Unit.Rotation.castFailed = true
+	}
+}
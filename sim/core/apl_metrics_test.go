@@ -0,0 +1,49 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+func TestAPLRowMetrics(t *testing.T) {
+	sim := &Simulation{}
+	rot := &APLRotation{
+		prepullWarnings:        make([][]string, 1),
+		priorityListWarnings:   make([][]string, 4),
+		priorityListConfigIdxs: []int{0, 2, 3}, // Row 1 is hidden.
+	}
+	metrics := rot.newAPLMetrics()
+
+	falseCondition := rot.newValueConst(&proto.APLValueConst{Val: "false"})
+	rot.priorityList = []*APLAction{
+		{condition: falseCondition, impl: &testAPLAction{ready: true}},
+		{impl: &testAPLAction{ready: false}},
+		{impl: &testAPLAction{ready: true}},
+	}
+
+	for i := 0; i < 2; i++ {
+		metrics.reset()
+		sim.CurrentTime = time.Duration(i+1) * time.Second
+		if next := rot.getNextAction(sim); next != rot.priorityList[2] {
+			t.Fatalf("Expected the last row to be ready")
+		}
+		rot.nextActionMetrics.addExecution(sim, i == 1)
+		rot.nextActionMetrics.addExecution(sim, false)
+		metrics.doneIteration()
+	}
+
+	expected := []*proto.APLRowMetrics{
+		{Evaluations: 1},
+		{},
+		{Evaluations: 1, ConditionTrue: 1},
+		{Evaluations: 1, ConditionTrue: 1, Executions: 2, CastFailures: 0.5, ExecutionRate: 1, FirstExecutionAvg: 1.5},
+	}
+	for i, row := range metrics.ToProto().PriorityList {
+		if !googleProto.Equal(row, expected[i]) {
+			t.Fatalf("Unexpected metrics for row %d, expected %s, got %s", i, expected[i], row)
+		}
+	}
+}
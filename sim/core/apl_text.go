@@ -0,0 +1,1148 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The APL text format is a compact, line based syntax for APL rotations:
+//
+//	variable burst: bool = false
+//	macro low_mana = current_mana_percent < 20%
+//	prepull -1.5s: cast(Frostbolt)
+//	cast(Fireball) if aura_remaining(Hot Streak) < 1.5s && gcd_ready // notes
+//	hide call_action_list(aoe) if number_targets > 3
+//	list aoe:
+//	  cast(Flamestrike)
+//
+// Actions and values are written as calls, named after their field in the
+// APLAction/APLValue oneofs or one of the shorter aliases below. Arguments fill
+// the fields of the call in field number order, or can be named, e.g.
+// channel(Mind Flay, interrupt_if: gcd_ready). Calls without arguments can omit
+// the parentheses. Comparisons, math and boolean operators are written infix.
+//
+// Spells, items and auras are written by name, or as spell:<id>, item:<id> and
+// other:<OtherAction>, optionally followed by #<tag> and @<rank>. Constants are written as-is when they look like numbers or
+// durations (1.5s, 20%), and quoted otherwise. Lines starting with # are comments.
+
+var aplTextActionAliases = map[string]string{
+	"cast":    "cast_spell",
+	"channel": "channel_spell",
+}
+
+var aplTextValueAliases = map[string]string{
+	"aura_active":    "aura_is_active",
+	"aura_remaining": "aura_remaining_time",
+	"aura_stacks":    "aura_num_stacks",
+	"dot_active":     "dot_is_active",
+	"dot_remaining":  "dot_remaining_time",
+	"gcd_ready":      "gcd_is_ready",
+	"spell_ready":    "spell_is_ready",
+}
+
+var (
+	aplTextActionOneof = (&proto.APLAction{}).ProtoReflect().Descriptor().Oneofs().ByName("action")
+	aplTextValueOneof  = (&proto.APLValue{}).ProtoReflect().Descriptor().Oneofs().ByName("value")
+
+	aplTextActionNames = aplTextNames(aplTextActionAliases)
+	aplTextValueNames  = aplTextNames(aplTextValueAliases)
+)
+
+// Maps oneof field names to the aliases used when printing them.
+func aplTextNames(aliases map[string]string) map[protoreflect.Name]string {
+	names := make(map[protoreflect.Name]string, len(aliases))
+	for alias, name := range aliases {
+		names[protoreflect.Name(name)] = alias
+	}
+	return names
+}
+
+func aplTextKind(oneof protoreflect.OneofDescriptor, aliases map[string]string, name string) protoreflect.FieldDescriptor {
+	if fullName, ok := aliases[name]; ok {
+		name = fullName
+	}
+	return oneof.Fields().ByName(protoreflect.Name(name))
+}
+
+// Precedence of the infix operators, from loosest to tightest.
+const (
+	aplTextPrecOr = iota
+	aplTextPrecAnd
+	aplTextPrecCompare
+	aplTextPrecSum
+	aplTextPrecProduct
+	aplTextPrecUnary
+	aplTextPrecPrimary
+)
+
+var aplTextComparisons = []struct {
+	token string
+	op    proto.APLValueCompare_ComparisonOperator
+}{
+	// Two character operators first, so they aren't parsed as < or >.
+	{"==", proto.APLValueCompare_OpEq},
+	{"!=", proto.APLValueCompare_OpNe},
+	{"<=", proto.APLValueCompare_OpLe},
+	{">=", proto.APLValueCompare_OpGe},
+	{"<", proto.APLValueCompare_OpLt},
+	{">", proto.APLValueCompare_OpGt},
+}
+
+var aplTextMathOperators = map[proto.APLValueMath_MathOperator]string{
+	proto.APLValueMath_OpAdd: "+",
+	proto.APLValueMath_OpSub: "-",
+	proto.APLValueMath_OpMul: "*",
+	proto.APLValueMath_OpDiv: "/",
+}
+
+// FormatAPLText formats rotation in the APL text format. Spells, items and auras
+// are written by name when resolver resolves the name back to the same ActionID.
+// resolver may be nil, in which case they are written by ID.
+func FormatAPLText(rotation *proto.APLRotation, resolver *APLNameResolver) string {
+	pr := &aplTextPrinter{resolver: resolver}
+	var sections []string
+
+	var lines []string
+	for _, variable := range rotation.Variables {
+		line := "variable " + formatAPLTextName(variable.Name) + ": " + formatAPLTextType(variable.Type)
+		if variable.InitialValue != nil {
+			line += " = " + pr.formatValue(variable.InitialValue, aplTextPrecOr)
+		}
+		lines = append(lines, line)
+	}
+	for _, macro := range rotation.Macros {
+		line := "macro " + formatAPLTextName(macro.Name)
+		if macro.Value != nil {
+			line += " = " + pr.formatValue(macro.Value, aplTextPrecOr)
+		}
+		lines = append(lines, line)
+	}
+	sections = appendAPLTextSection(sections, lines)
+
+	lines = nil
+	for _, prepull := range rotation.PrepullActions {
+		line := "prepull"
+		if prepull.Hide {
+			line = "hide " + line
+		}
+		if prepull.DoAtValue != nil {
+			line += " " + pr.formatValue(prepull.DoAtValue, aplTextPrecOr)
+		}
+		lines = append(lines, line+": "+pr.formatItemAction(prepull.Action))
+	}
+	sections = appendAPLTextSection(sections, lines)
+
+	lines = nil
+	for _, item := range rotation.PriorityList {
+		lines = append(lines, pr.formatListItem(item))
+	}
+	sections = appendAPLTextSection(sections, lines)
+
+	for _, list := range rotation.ActionLists {
+		lines = []string{"list " + formatAPLTextName(list.Name) + ":"}
+		for _, item := range list.Items {
+			lines = append(lines, "  "+pr.formatListItem(item))
+		}
+		sections = appendAPLTextSection(sections, lines)
+	}
+
+	return strings.Join(sections, "\n")
+}
+
+func appendAPLTextSection(sections []string, lines []string) []string {
+	if len(lines) == 0 {
+		return sections
+	}
+	return append(sections, strings.Join(lines, "\n")+"\n")
+}
+
+type aplTextPrinter struct {
+	resolver *APLNameResolver
+}
+
+func (pr *aplTextPrinter) formatListItem(item *proto.APLListItem) string {
+	line := pr.formatItemAction(item.Action)
+	if item.Hide {
+		line = "hide " + line
+	}
+	if item.Notes != "" {
+		notes := item.Notes
+		// Notes which wouldn't survive being read back as the rest of the line are quoted.
+		if strings.ContainsAny(notes, "\r\n") || strings.HasPrefix(notes, `"`) || notes != strings.TrimRight(notes, " \t") {
+			notes = strconv.Quote(notes)
+		}
+		line += " // " + notes
+	}
+	return line
+}
+
+func (pr *aplTextPrinter) formatItemAction(action *proto.APLAction) string {
+	if action == nil {
+		return "none"
+	}
+	return pr.formatAction(action)
+}
+
+func (pr *aplTextPrinter) formatAction(action *proto.APLAction) string {
+	text := "none"
+	m := action.ProtoReflect()
+	if fd := m.WhichOneof(aplTextActionOneof); fd != nil {
+		text = pr.formatCall(aplTextCallName(fd, aplTextActionNames), m.Get(fd).Message())
+	}
+	if action.Condition != nil {
+		text += " if " + pr.formatValue(action.Condition, aplTextPrecOr)
+	}
+	return text
+}
+
+// Formats value, in parentheses if its operator binds looser than minPrec.
+func (pr *aplTextPrinter) formatValue(value *proto.APLValue, minPrec int) string {
+	text, prec := pr.formatValueWithPrec(value)
+	if prec < minPrec {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func (pr *aplTextPrinter) formatValueWithPrec(value *proto.APLValue) (string, int) {
+	switch v := value.GetValue().(type) {
+	case nil:
+		return "none", aplTextPrecPrimary
+	case *proto.APLValue_Const:
+		return formatAPLTextConst(v.Const.GetVal()), aplTextPrecPrimary
+	case *proto.APLValue_Or:
+		if len(v.Or.GetVals()) >= 2 {
+			return pr.joinValues(v.Or.Vals, " || ", aplTextPrecAnd), aplTextPrecOr
+		}
+	case *proto.APLValue_And:
+		if len(v.And.GetVals()) >= 2 {
+			return pr.joinValues(v.And.Vals, " && ", aplTextPrecCompare), aplTextPrecAnd
+		}
+	case *proto.APLValue_Not:
+		if v.Not.GetVal() != nil {
+			return "!" + pr.formatValue(v.Not.Val, aplTextPrecUnary), aplTextPrecUnary
+		}
+	case *proto.APLValue_Cmp:
+		if v.Cmp.GetLhs() != nil && v.Cmp.GetRhs() != nil {
+			for _, comparison := range aplTextComparisons {
+				if comparison.op == v.Cmp.Op {
+					return pr.formatValue(v.Cmp.Lhs, aplTextPrecSum) + " " + comparison.token + " " + pr.formatValue(v.Cmp.Rhs, aplTextPrecSum), aplTextPrecCompare
+				}
+			}
+		}
+	case *proto.APLValue_Math:
+		if token, ok := aplTextMathOperators[v.Math.GetOp()]; ok && v.Math.GetLhs() != nil && v.Math.GetRhs() != nil {
+			// Operators are left associative, so only the right hand side needs
+			// parentheses for operators of the same precedence.
+			prec := aplTextPrecSum
+			if v.Math.Op == proto.APLValueMath_OpMul || v.Math.Op == proto.APLValueMath_OpDiv {
+				prec = aplTextPrecProduct
+			}
+			return pr.formatValue(v.Math.Lhs, prec) + " " + token + " " + pr.formatValue(v.Math.Rhs, prec+1), prec
+		}
+	}
+
+	// Everything else, including operators which can't be written infix, is a call.
+	m := value.ProtoReflect()
+	fd := m.WhichOneof(aplTextValueOneof)
+	return pr.formatCall(aplTextCallName(fd, aplTextValueNames), m.Get(fd).Message()), aplTextPrecPrimary
+}
+
+func (pr *aplTextPrinter) joinValues(values []*proto.APLValue, separator string, minPrec int) string {
+	texts := make([]string, len(values))
+	for i, value := range values {
+		texts[i] = pr.formatValue(value, minPrec)
+	}
+	return strings.Join(texts, separator)
+}
+
+func aplTextCallName(fd protoreflect.FieldDescriptor, aliases map[protoreflect.Name]string) string {
+	if alias, ok := aliases[fd.Name()]; ok {
+		return alias
+	}
+	return string(fd.Name())
+}
+
+// Formats the fields of m as call arguments. Leading fields are positional, and
+// fields after the first unset field are named.
+func (pr *aplTextPrinter) formatCall(name string, m protoreflect.Message) string {
+	fields := m.Descriptor().Fields()
+	var args []string
+	if isAPLTextVariadic(m.Descriptor()) {
+		fd := fields.Get(0)
+		list := m.Get(fd).List()
+		for i := 0; i < list.Len(); i++ {
+			args = append(args, pr.formatElement(fd, list.Get(i)))
+		}
+	} else {
+		positional := true
+		for _, fd := range sortedAPLTextFields(m.Descriptor()) {
+			if !m.Has(fd) {
+				positional = false
+				continue
+			}
+			arg := pr.formatField(fd, m.Get(fd))
+			if !positional {
+				arg = string(fd.Name()) + ": " + arg
+			}
+			args = append(args, arg)
+		}
+	}
+
+	if len(args) == 0 {
+		return name
+	}
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (pr *aplTextPrinter) formatField(fd protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	if !fd.IsList() {
+		return pr.formatElement(fd, value)
+	}
+	list := value.List()
+	elements := make([]string, list.Len())
+	for i := range elements {
+		elements[i] = pr.formatElement(fd, list.Get(i))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Formats a single value of fd, i.e. an element if fd is a list.
+func (pr *aplTextPrinter) formatElement(fd protoreflect.FieldDescriptor, value protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(value.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(value.Int(), 10)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(value.Uint(), 10)
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 32)
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64)
+	case protoreflect.StringKind:
+		return formatAPLTextWord(value.String())
+	case protoreflect.EnumKind:
+		if enumValue := fd.Enum().Values().ByNumber(value.Enum()); enumValue != nil {
+			return string(enumValue.Name())
+		}
+		return strconv.Itoa(int(value.Enum()))
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return pr.formatMessage(fd, value.Message())
+	}
+	panic(fmt.Sprintf("Unsupported APL field kind: %s", fd.Kind()))
+}
+
+func (pr *aplTextPrinter) formatMessage(fd protoreflect.FieldDescriptor, m protoreflect.Message) string {
+	switch msg := m.Interface().(type) {
+	case *proto.APLValue:
+		return pr.formatValue(msg, aplTextPrecOr)
+	case *proto.APLAction:
+		return pr.formatAction(msg)
+	case *proto.ActionID:
+		if text := pr.formatActionID(msg, isAPLTextAuraField(fd)); text != "" {
+			return text
+		}
+	case *proto.UnitReference:
+		if msg.Type != proto.UnitReference_Unknown && msg.Index == 0 && msg.Owner == nil {
+			return msg.Type.String()
+		}
+	}
+
+	var fields []string
+	for _, field := range sortedAPLTextFields(m.Descriptor()) {
+		if m.Has(field) {
+			fields = append(fields, string(field.Name())+": "+pr.formatField(field, m.Get(field)))
+		}
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// Formats the name or ID of actionID, followed by its tag and rank if set.
+// Returns "" if actionID has no ID.
+func (pr *aplTextPrinter) formatActionID(actionID *proto.ActionID, aura bool) string {
+	var text string
+	if name := pr.resolver.Name(ProtoToActionID(actionID).WithTag(0), aura); name != "" {
+		text = formatAPLTextWord(name)
+	} else {
+		switch id := actionID.RawId.(type) {
+		case *proto.ActionID_SpellId:
+			text = "spell:" + strconv.Itoa(int(id.SpellId))
+		case *proto.ActionID_ItemId:
+			text = "item:" + strconv.Itoa(int(id.ItemId))
+		case *proto.ActionID_OtherId:
+			text = "other:" + id.OtherId.String()
+		default:
+			return ""
+		}
+	}
+	if actionID.Tag != 0 {
+		text += "#" + strconv.Itoa(int(actionID.Tag))
+	}
+	if actionID.Rank != 0 {
+		text += "@" + strconv.Itoa(int(actionID.Rank))
+	}
+	return text
+}
+
+func formatAPLTextConst(val string) string {
+	if val == "true" || val == "false" || isAPLTextLiteral(val) {
+		return val
+	}
+	return strconv.Quote(val)
+}
+
+func formatAPLTextType(valueType proto.APLValueType) string {
+	return strings.ToLower(strings.TrimPrefix(valueType.String(), "ValueType"))
+}
+
+// Formats the name of a variable, macro or list.
+func formatAPLTextName(name string) string {
+	if isAPLTextIdent(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// Formats a string argument, quoted unless it can be read back as a bare word.
+func formatAPLTextWord(word string) string {
+	if word == "" || !isAPLTextIdentStart(word[0]) && !isAPLTextDigit(word[0]) || word[len(word)-1] == ' ' {
+		return strconv.Quote(word)
+	}
+	for i := 0; i < len(word); i++ {
+		if c := word[i]; !isAPLTextIdentChar(c) && !strings.ContainsRune(" '-.", rune(c)) {
+			return strconv.Quote(word)
+		}
+	}
+	return word
+}
+
+func isAPLTextIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isAPLTextDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isAPLTextIdentChar(c byte) bool {
+	return isAPLTextIdentStart(c) || isAPLTextDigit(c)
+}
+
+func isAPLTextLiteralChar(c byte) bool {
+	return isAPLTextIdentChar(c) || c == '.' || c == '%'
+}
+
+func isAPLTextIdent(s string) bool {
+	if s == "" || !isAPLTextIdentStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isAPLTextIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether s is a number-like literal, e.g. 3, -1.5s or 20%, which can be written unquoted.
+func isAPLTextLiteral(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" || !isAPLTextDigit(s[0]) && s[0] != '.' {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isAPLTextLiteralChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Messages with a single repeated field, e.g. max or strict_sequence, take the
+// elements of that field as their arguments.
+func isAPLTextVariadic(desc protoreflect.MessageDescriptor) bool {
+	return desc.Fields().Len() == 1 && desc.Fields().Get(0).IsList()
+}
+
+func isAPLTextAuraField(fd protoreflect.FieldDescriptor) bool {
+	return fd != nil && strings.HasPrefix(string(fd.Name()), "aura")
+}
+
+func sortedAPLTextFields(desc protoreflect.MessageDescriptor) []protoreflect.FieldDescriptor {
+	fields := make([]protoreflect.FieldDescriptor, desc.Fields().Len())
+	for i := range fields {
+		fields[i] = desc.Fields().Get(i)
+	}
+	slices.SortFunc(fields, func(a, b protoreflect.FieldDescriptor) int {
+		return int(a.Number() - b.Number())
+	})
+	return fields
+}
+
+// ParseAPLText parses a rotation in the APL text format. Names of spells, items and
+// auras are resolved with resolver, which may be nil if the text only uses IDs.
+func ParseAPLText(text string, resolver *APLNameResolver) (*proto.APLRotation, error) {
+	rotation := &proto.APLRotation{
+		Type: proto.APLRotation_TypeAPL,
+	}
+	p := &aplTextParser{resolver: resolver}
+
+	var list *proto.APLActionList
+	for lineIdx, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if trimmed := strings.TrimLeft(line, " \t"); trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+
+		err := p.parseLine(line, func() {
+			if line[0] == ' ' || line[0] == '\t' {
+				if list == nil {
+					p.fail("indented line outside of an action list")
+				}
+				list.Items = append(list.Items, p.parseListItem())
+				return
+			}
+
+			list = nil
+			switch {
+			case p.consumeKeyword("variable"):
+				rotation.Variables = append(rotation.Variables, p.parseVariable())
+			case p.consumeKeyword("macro"):
+				rotation.Macros = append(rotation.Macros, p.parseMacro())
+			case p.consumeKeyword("list"):
+				list = &proto.APLActionList{Name: p.name()}
+				p.expect(":")
+				rotation.ActionLists = append(rotation.ActionLists, list)
+			default:
+				if prepull := p.parsePrepull(); prepull != nil {
+					rotation.PrepullActions = append(rotation.PrepullActions, prepull)
+				} else {
+					rotation.PriorityList = append(rotation.PriorityList, p.parseListItem())
+				}
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineIdx+1, err)
+		}
+	}
+
+	return rotation, nil
+}
+
+type aplTextParser struct {
+	resolver *APLNameResolver
+
+	line string
+	pos  int
+}
+
+// Parse errors are raised as panics of this type, and recovered by parseLine.
+type aplTextError string
+
+func (p *aplTextParser) fail(format string, args ...any) {
+	panic(aplTextError(fmt.Sprintf(format, args...)))
+}
+
+func (p *aplTextParser) parseLine(line string, parse func()) (err error) {
+	p.line, p.pos = line, 0
+	defer func() {
+		if r := recover(); r != nil {
+			msg, ok := r.(aplTextError)
+			if !ok {
+				panic(r)
+			}
+			err = errors.New(string(msg))
+		}
+	}()
+
+	parse()
+	if !p.atEnd() {
+		p.fail("unexpected '%s'", p.line[p.pos:])
+	}
+	return nil
+}
+
+func (p *aplTextParser) skipSpace() {
+	for p.pos < len(p.line) && (p.line[p.pos] == ' ' || p.line[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// Returns the next non-space character, or 0 at the end of the line.
+func (p *aplTextParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.line) {
+		return 0
+	}
+	return p.line[p.pos]
+}
+
+func (p *aplTextParser) atEnd() bool {
+	return p.peek() == 0
+}
+
+func (p *aplTextParser) rest() string {
+	if p.atEnd() {
+		return "end of line"
+	}
+	return "'" + p.line[p.pos:] + "'"
+}
+
+func (p *aplTextParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.line[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *aplTextParser) expect(token string) {
+	if !p.consume(token) {
+		p.fail("expected '%s' at %s", token, p.rest())
+	}
+}
+
+func (p *aplTextParser) peekIdent() string {
+	p.skipSpace()
+	end := p.pos
+	if end < len(p.line) && isAPLTextIdentStart(p.line[end]) {
+		for end < len(p.line) && isAPLTextIdentChar(p.line[end]) {
+			end++
+		}
+	}
+	return p.line[p.pos:end]
+}
+
+func (p *aplTextParser) ident() string {
+	ident := p.peekIdent()
+	if ident == "" {
+		p.fail("expected a name at %s", p.rest())
+	}
+	p.pos += len(ident)
+	return ident
+}
+
+func (p *aplTextParser) consumeKeyword(keyword string) bool {
+	if p.peekIdent() == keyword {
+		p.pos += len(keyword)
+		return true
+	}
+	return false
+}
+
+func (p *aplTextParser) literal() string {
+	p.skipSpace()
+	start := p.pos
+	if p.pos < len(p.line) && p.line[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.line) && isAPLTextLiteralChar(p.line[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail("expected a number at %s", p.rest())
+	}
+	return p.line[start:p.pos]
+}
+
+func (p *aplTextParser) quoted() string {
+	p.skipSpace()
+	end := p.pos + 1
+	for end < len(p.line) && p.line[end] != '"' {
+		if p.line[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(p.line) {
+		p.fail("unterminated string at %s", p.rest())
+	}
+	s, err := strconv.Unquote(p.line[p.pos : end+1])
+	if err != nil {
+		p.fail("invalid string %s", p.line[p.pos:end+1])
+	}
+	p.pos = end + 1
+	return s
+}
+
+// Parses a quoted string, or a bare word which ends at the next delimiter.
+func (p *aplTextParser) word() string {
+	if p.peek() == '"' {
+		return p.quoted()
+	}
+	start := p.pos
+	for p.pos < len(p.line) && !strings.ContainsRune(",:)]}#@", rune(p.line[p.pos])) && !strings.HasPrefix(p.line[p.pos:], "//") {
+		p.pos++
+	}
+	word := strings.TrimSpace(p.line[start:p.pos])
+	if word == "" {
+		p.fail("expected a name at %s", p.rest())
+	}
+	return word
+}
+
+// Parses the name of a variable, macro or list.
+func (p *aplTextParser) name() string {
+	if p.peek() == '"' {
+		return p.quoted()
+	}
+	return p.ident()
+}
+
+func (p *aplTextParser) parseInt(text string, bitSize int) int64 {
+	n, err := strconv.ParseInt(text, 10, bitSize)
+	if err != nil {
+		p.fail("invalid integer: %s", text)
+	}
+	return n
+}
+
+// Parses a comma separated list, ending with end.
+func (p *aplTextParser) parseList(end string, parseItem func()) {
+	if p.consume(end) {
+		return
+	}
+	for {
+		parseItem()
+		if p.consume(end) {
+			return
+		}
+		if !p.consume(",") {
+			p.fail("expected ',' or '%s' at %s", end, p.rest())
+		}
+	}
+}
+
+func (p *aplTextParser) parseVariable() *proto.APLVariable {
+	variable := &proto.APLVariable{Name: p.name()}
+	p.expect(":")
+	typeName := p.ident()
+	found := false
+	for number := range proto.APLValueType_name {
+		if valueType := proto.APLValueType(number); typeName == formatAPLTextType(valueType) || typeName == valueType.String() {
+			variable.Type, found = valueType, true
+		}
+	}
+	if !found {
+		p.fail("unknown variable type: %s", typeName)
+	}
+	if p.consume("=") {
+		variable.InitialValue = p.parseValue()
+	}
+	return variable
+}
+
+func (p *aplTextParser) parseMacro() *proto.APLMacro {
+	macro := &proto.APLMacro{Name: p.name()}
+	if p.consume("=") {
+		macro.Value = p.parseValue()
+	}
+	return macro
+}
+
+// Parses a prepull action, or returns nil if the line isn't one.
+func (p *aplTextParser) parsePrepull() *proto.APLPrepullAction {
+	start := p.pos
+	hide := p.consumeKeyword("hide")
+	if !p.consumeKeyword("prepull") {
+		p.pos = start
+		return nil
+	}
+
+	prepull := &proto.APLPrepullAction{Hide: hide}
+	if !p.consume(":") {
+		prepull.DoAtValue = p.parseValue()
+		p.expect(":")
+	}
+	prepull.Action = p.parseItemAction()
+	return prepull
+}
+
+func (p *aplTextParser) parseListItem() *proto.APLListItem {
+	item := &proto.APLListItem{
+		Hide:   p.consumeKeyword("hide"),
+		Action: p.parseItemAction(),
+	}
+	if p.consume("//") {
+		notes := strings.TrimPrefix(p.line[p.pos:], " ")
+		if strings.HasPrefix(notes, `"`) {
+			unquoted, err := strconv.Unquote(notes)
+			if err != nil {
+				p.fail("invalid notes: %s", notes)
+			}
+			notes = unquoted
+		}
+		item.Notes = notes
+		p.pos = len(p.line)
+	}
+	return item
+}
+
+// Parses the action of a list item or prepull action, where none is a missing action.
+func (p *aplTextParser) parseItemAction() *proto.APLAction {
+	if p.atEnd() || strings.HasPrefix(p.line[p.pos:], "//") {
+		return nil
+	}
+	action := p.parseAction()
+	if action.Action == nil && action.Condition == nil {
+		return nil
+	}
+	return action
+}
+
+func (p *aplTextParser) parseAction() *proto.APLAction {
+	action := &proto.APLAction{}
+	if name := p.ident(); name != "none" {
+		fd := aplTextKind(aplTextActionOneof, aplTextActionAliases, name)
+		if fd == nil {
+			p.fail("unknown action: %s", name)
+		}
+		p.parseCall(action.ProtoReflect(), fd)
+	}
+	if p.consumeKeyword("if") {
+		action.Condition = p.parseValue()
+	}
+	return action
+}
+
+func (p *aplTextParser) parseValue() *proto.APLValue {
+	return p.parseOr()
+}
+
+func (p *aplTextParser) parseOr() *proto.APLValue {
+	vals := []*proto.APLValue{p.parseAnd()}
+	for p.consume("||") {
+		vals = append(vals, p.parseAnd())
+	}
+	if len(vals) == 1 {
+		return vals[0]
+	}
+	return &proto.APLValue{Value: &proto.APLValue_Or{Or: &proto.APLValueOr{Vals: vals}}}
+}
+
+func (p *aplTextParser) parseAnd() *proto.APLValue {
+	vals := []*proto.APLValue{p.parseCompare()}
+	for p.consume("&&") {
+		vals = append(vals, p.parseCompare())
+	}
+	if len(vals) == 1 {
+		return vals[0]
+	}
+	return &proto.APLValue{Value: &proto.APLValue_And{And: &proto.APLValueAnd{Vals: vals}}}
+}
+
+func (p *aplTextParser) parseCompare() *proto.APLValue {
+	lhs := p.parseSum()
+	for _, comparison := range aplTextComparisons {
+		if p.consume(comparison.token) {
+			return &proto.APLValue{Value: &proto.APLValue_Cmp{Cmp: &proto.APLValueCompare{
+				Op:  comparison.op,
+				Lhs: lhs,
+				Rhs: p.parseSum(),
+			}}}
+		}
+	}
+	return lhs
+}
+
+func (p *aplTextParser) parseSum() *proto.APLValue {
+	lhs := p.parseProduct()
+	for {
+		var op proto.APLValueMath_MathOperator
+		switch {
+		case p.consume("+"):
+			op = proto.APLValueMath_OpAdd
+		case p.consume("-"):
+			op = proto.APLValueMath_OpSub
+		default:
+			return lhs
+		}
+		lhs = newAPLTextMath(op, lhs, p.parseProduct())
+	}
+}
+
+func (p *aplTextParser) parseProduct() *proto.APLValue {
+	lhs := p.parseUnary()
+	for {
+		var op proto.APLValueMath_MathOperator
+		switch {
+		case p.consume("*"):
+			op = proto.APLValueMath_OpMul
+		case p.peek() == '/' && !strings.HasPrefix(p.line[p.pos:], "//"):
+			p.pos++
+			op = proto.APLValueMath_OpDiv
+		default:
+			return lhs
+		}
+		lhs = newAPLTextMath(op, lhs, p.parseUnary())
+	}
+}
+
+func newAPLTextMath(op proto.APLValueMath_MathOperator, lhs *proto.APLValue, rhs *proto.APLValue) *proto.APLValue {
+	return &proto.APLValue{Value: &proto.APLValue_Math{Math: &proto.APLValueMath{
+		Op:  op,
+		Lhs: lhs,
+		Rhs: rhs,
+	}}}
+}
+
+func (p *aplTextParser) parseUnary() *proto.APLValue {
+	if p.consume("!") {
+		return &proto.APLValue{Value: &proto.APLValue_Not{Not: &proto.APLValueNot{Val: p.parseUnary()}}}
+	}
+	return p.parsePrimary()
+}
+
+func (p *aplTextParser) parsePrimary() *proto.APLValue {
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		value := p.parseValue()
+		p.expect(")")
+		return value
+	case c == '"':
+		return newAPLTextConst(p.quoted())
+	case c == '-' || c == '.' || isAPLTextDigit(c):
+		literal := p.literal()
+		if !isAPLTextLiteral(literal) {
+			p.fail("invalid number: %s", literal)
+		}
+		return newAPLTextConst(literal)
+	case isAPLTextIdentStart(c):
+		name := p.ident()
+		switch name {
+		case "true", "false":
+			return newAPLTextConst(name)
+		case "none":
+			return &proto.APLValue{}
+		}
+		fd := aplTextKind(aplTextValueOneof, aplTextValueAliases, name)
+		if fd == nil {
+			p.fail("unknown value: %s", name)
+		}
+		value := &proto.APLValue{}
+		p.parseCall(value.ProtoReflect(), fd)
+		return value
+	}
+	p.fail("expected a value at %s", p.rest())
+	return nil
+}
+
+func newAPLTextConst(val string) *proto.APLValue {
+	return &proto.APLValue{Value: &proto.APLValue_Const{Const: &proto.APLValueConst{Val: val}}}
+}
+
+// Parses the optional arguments of a call, and sets the oneof field fd of parent to the result.
+func (p *aplTextParser) parseCall(parent protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	value := parent.NewField(fd)
+	m := value.Message()
+	if p.consume("(") {
+		desc := m.Descriptor()
+		if isAPLTextVariadic(desc) {
+			listField := desc.Fields().Get(0)
+			list := m.Mutable(listField).List()
+			p.parseList(")", func() {
+				list.Append(p.parseElement(listField, list.NewElement))
+			})
+		} else {
+			fields := sortedAPLTextFields(desc)
+			next := 0
+			p.parseList(")", func() {
+				field := p.parseArgName(desc)
+				if field == nil {
+					if next >= len(fields) {
+						p.fail("too many arguments for %s", fd.Name())
+					}
+					field = fields[next]
+				}
+				for i, f := range fields {
+					if f == field {
+						next = i + 1
+					}
+				}
+				p.parseField(m, field)
+			})
+		}
+	}
+	parent.Set(fd, value)
+}
+
+// Parses the name of a named argument of desc, or returns nil if the next argument is positional.
+func (p *aplTextParser) parseArgName(desc protoreflect.MessageDescriptor) protoreflect.FieldDescriptor {
+	start := p.pos
+	if fd := desc.Fields().ByName(protoreflect.Name(p.peekIdent())); fd != nil {
+		p.pos += len(fd.Name())
+		if p.consume(":") {
+			return fd
+		}
+	}
+	p.pos = start
+	return nil
+}
+
+func (p *aplTextParser) parseField(m protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	if m.Has(fd) {
+		p.fail("duplicate argument: %s", fd.Name())
+	}
+	if !fd.IsList() {
+		m.Set(fd, p.parseElement(fd, func() protoreflect.Value { return m.NewField(fd) }))
+		return
+	}
+	list := m.Mutable(fd).List()
+	p.expect("[")
+	p.parseList("]", func() {
+		list.Append(p.parseElement(fd, list.NewElement))
+	})
+}
+
+// Parses a single value of fd, i.e. an element if fd is a list. newMessage
+// creates the value for message fields.
+func (p *aplTextParser) parseElement(fd protoreflect.FieldDescriptor, newMessage func() protoreflect.Value) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		switch ident := p.ident(); ident {
+		case "true", "false":
+			return protoreflect.ValueOfBool(ident == "true")
+		default:
+			p.fail("expected true or false for %s, got %s", fd.Name(), ident)
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(p.parseInt(p.literal(), 32)))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(p.parseInt(p.literal(), 64))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		literal := p.literal()
+		n, err := strconv.ParseUint(literal, 10, 64)
+		if err != nil {
+			p.fail("invalid integer: %s", literal)
+		}
+		if fd.Kind() == protoreflect.Uint32Kind || fd.Kind() == protoreflect.Fixed32Kind {
+			return protoreflect.ValueOfUint32(uint32(n))
+		}
+		return protoreflect.ValueOfUint64(n)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		literal := p.literal()
+		f, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			p.fail("invalid number: %s", literal)
+		}
+		if fd.Kind() == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(f))
+		}
+		return protoreflect.ValueOfFloat64(f)
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(p.word())
+	case protoreflect.EnumKind:
+		if c := p.peek(); c == '-' || isAPLTextDigit(c) {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(p.parseInt(p.literal(), 32)))
+		}
+		name := p.ident()
+		enumValue := fd.Enum().Values().ByName(protoreflect.Name(name))
+		if enumValue == nil {
+			p.fail("unknown %s: %s", fd.Enum().Name(), name)
+		}
+		return protoreflect.ValueOfEnum(enumValue.Number())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		value := newMessage()
+		p.parseMessage(fd, value.Message())
+		return value
+	}
+	p.fail("unsupported field: %s", fd.Name())
+	return protoreflect.Value{}
+}
+
+func (p *aplTextParser) parseMessage(fd protoreflect.FieldDescriptor, m protoreflect.Message) {
+	switch msg := m.Interface().(type) {
+	case *proto.APLValue:
+		googleProto.Merge(msg, p.parseValue())
+		return
+	case *proto.APLAction:
+		googleProto.Merge(msg, p.parseAction())
+		return
+	case *proto.ActionID:
+		if p.peek() != '{' {
+			googleProto.Merge(msg, p.parseActionID(isAPLTextAuraField(fd)))
+			return
+		}
+	case *proto.UnitReference:
+		if name := p.peekIdent(); name != "" {
+			p.pos += len(name)
+			unitType, ok := proto.UnitReference_Type_value[name]
+			if !ok {
+				p.fail("unknown unit: %s", name)
+			}
+			msg.Type = proto.UnitReference_Type(unitType)
+			return
+		}
+	}
+
+	p.expect("{")
+	p.parseList("}", func() {
+		name := p.ident()
+		field := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			p.fail("unknown field of %s: %s", m.Descriptor().Name(), name)
+		}
+		p.expect(":")
+		p.parseField(m, field)
+	})
+}
+
+func (p *aplTextParser) parseActionID(aura bool) *proto.ActionID {
+	actionID := p.parseRawActionID(aura)
+	if p.consume("#") {
+		actionID.Tag = int32(p.parseInt(p.literal(), 32))
+	}
+	if p.consume("@") {
+		actionID.Rank = int32(p.parseInt(p.literal(), 32))
+	}
+	return actionID
+}
+
+func (p *aplTextParser) parseRawActionID(aura bool) *proto.ActionID {
+	if p.peek() == '"' {
+		return p.resolveName(p.quoted(), aura)
+	}
+
+	start := p.pos
+	if prefix := p.peekIdent(); prefix == "spell" || prefix == "item" || prefix == "other" {
+		p.pos += len(prefix)
+		if p.consume(":") {
+			literal := p.literal()
+			switch prefix {
+			case "spell":
+				return ActionID{SpellID: int32(p.parseInt(literal, 32))}.ToProto()
+			case "item":
+				return ActionID{ItemID: int32(p.parseInt(literal, 32))}.ToProto()
+			default:
+				if otherID, ok := proto.OtherAction_value[literal]; ok {
+					return &proto.ActionID{RawId: &proto.ActionID_OtherId{OtherId: proto.OtherAction(otherID)}}
+				}
+				return &proto.ActionID{RawId: &proto.ActionID_OtherId{OtherId: proto.OtherAction(p.parseInt(literal, 32))}}
+			}
+		}
+		p.pos = start
+	}
+	return p.resolveName(p.word(), aura)
+}
+
+func (p *aplTextParser) resolveName(name string, aura bool) *proto.ActionID {
+	if p.resolver == nil {
+		p.fail("cannot resolve '%s' without a character, use spell:<id> or item:<id> instead", name)
+	}
+	actionID, err := p.resolver.Resolve(name, aura)
+	if err != nil {
+		p.fail("%v", err)
+	}
+	return actionID.ToProto()
+}
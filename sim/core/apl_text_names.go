@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// APLNameResolver converts between the names used in the APL text format and the
+// ActionIDs of the spells and auras registered by a unit.
+type APLNameResolver struct {
+	spells aplNameTable
+	auras  aplNameTable
+
+	names map[ActionID]string
+}
+
+type aplNameTable struct {
+	ids       map[string]ActionID // Keyed by lower case name.
+	ranks     map[string]int32
+	ambiguous map[string]bool
+}
+
+func newAPLNameTable() aplNameTable {
+	return aplNameTable{
+		ids:       make(map[string]ActionID),
+		ranks:     make(map[string]int32),
+		ambiguous: make(map[string]bool),
+	}
+}
+
+// Adds name for actionID. When ranks of a spell share a name, the name refers
+// to the highest rank. Names shared by different spells of the same rank are ambiguous.
+func (table aplNameTable) add(name string, actionID ActionID, rank int32) {
+	key := strings.ToLower(name)
+	if prevID, ok := table.ids[key]; ok {
+		if prevID == actionID || rank < table.ranks[key] {
+			return
+		}
+		if rank == table.ranks[key] {
+			table.ambiguous[key] = true
+			return
+		}
+	}
+	table.ids[key] = actionID
+	table.ranks[key] = rank
+	delete(table.ambiguous, key)
+}
+
+// NewAPLNameResolver creates a resolver for the spells and auras registered by
+// unit. Names come from the spells and items in db, falling back to aura labels.
+func NewAPLNameResolver(unit *Unit, db *proto.UIDatabase) *APLNameResolver {
+	dbNames := make(map[ActionID]string)
+	dbRanks := make(map[ActionID]int32)
+	for _, icon := range db.SpellIcons {
+		dbNames[ActionID{SpellID: icon.Id}] = icon.Name
+		dbRanks[ActionID{SpellID: icon.Id}] = icon.Rank
+	}
+	for _, item := range db.Items {
+		dbNames[ActionID{ItemID: item.Id}] = item.Name
+	}
+	for _, icon := range db.ItemIcons {
+		dbNames[ActionID{ItemID: icon.Id}] = icon.Name
+	}
+
+	resolver := &APLNameResolver{
+		spells: newAPLNameTable(),
+		auras:  newAPLNameTable(),
+		names:  make(map[ActionID]string),
+	}
+	add := func(table aplNameTable, name string, actionID ActionID, rank int32) {
+		if name == "" || actionID.IsEmptyAction() {
+			return
+		}
+		table.add(name, actionID, rank)
+		if _, ok := resolver.names[actionID]; !ok {
+			resolver.names[actionID] = name
+		}
+	}
+
+	for _, spell := range unit.Spellbook {
+		actionID := spell.ActionID.WithTag(0)
+		add(resolver.spells, dbNames[actionID], actionID, max(int32(spell.Rank), dbRanks[actionID]))
+	}
+	for _, aura := range unit.auras {
+		actionID := aura.ActionID.WithTag(0)
+		name := dbNames[actionID]
+		if name == "" {
+			name = aura.Label
+		}
+		add(resolver.auras, name, actionID, dbRanks[actionID])
+	}
+
+	return resolver
+}
+
+// Resolve returns the ActionID with the given name, ignoring case. Names are
+// looked up in auras first when aura is true, and in spells first otherwise.
+func (resolver *APLNameResolver) Resolve(name string, aura bool) (ActionID, error) {
+	tables := []aplNameTable{resolver.spells, resolver.auras}
+	if aura {
+		tables[0], tables[1] = tables[1], tables[0]
+	}
+
+	key := strings.ToLower(name)
+	for _, table := range tables {
+		if table.ambiguous[key] {
+			return ActionID{}, fmt.Errorf("ambiguous name '%s', use spell:<id> instead", name)
+		}
+		if actionID, ok := table.ids[key]; ok {
+			return actionID, nil
+		}
+	}
+	return ActionID{}, fmt.Errorf("no spell, item or aura named '%s'", name)
+}
+
+// Name returns the name of actionID, or "" if it has no name which resolves back
+// to it. Safe to call on a nil resolver.
+func (resolver *APLNameResolver) Name(actionID ActionID, aura bool) string {
+	if resolver == nil {
+		return ""
+	}
+	name, ok := resolver.names[actionID]
+	if !ok {
+		return ""
+	}
+	if resolved, err := resolver.Resolve(name, aura); err != nil || resolved != actionID {
+		return ""
+	}
+	return name
+}
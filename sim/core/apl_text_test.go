@@ -0,0 +1,214 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Sets every field of m to a sample value, and lists to two sample elements.
+func fillAPLTextSample(m protoreflect.Message, depth int) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if oneof := fd.ContainingOneof(); oneof != nil && oneof.Fields().Get(0) != fd {
+			continue
+		}
+		if fd.IsList() {
+			list := m.Mutable(fd).List()
+			list.Append(sampleAPLTextValue(fd, list.NewElement, depth))
+			list.Append(sampleAPLTextValue(fd, list.NewElement, depth))
+			continue
+		}
+		m.Set(fd, sampleAPLTextValue(fd, func() protoreflect.Value { return m.NewField(fd) }, depth))
+	}
+}
+
+func sampleAPLTextValue(fd protoreflect.FieldDescriptor, newMessage func() protoreflect.Value, depth int) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(-3)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(-3)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(3)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(3)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(1.5)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(0.25)
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString("Sample Name")
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(values.Len() - 1).Number())
+	}
+
+	value := newMessage()
+	switch msg := value.Message().Interface().(type) {
+	case *proto.APLValue:
+		googleProto.Merge(msg, newAPLTextConst("1.5s"))
+	case *proto.APLAction:
+		googleProto.Merge(msg, &proto.APLAction{Action: &proto.APLAction_CastSpell{CastSpell: &proto.APLActionCastSpell{
+			SpellId: ActionID{SpellID: 133}.ToProto(),
+		}}})
+	case *proto.ActionID:
+		googleProto.Merge(msg, ActionID{SpellID: 133}.ToProto())
+	case *proto.UnitReference:
+		msg.Type = proto.UnitReference_Target
+		msg.Index = 1
+	default:
+		if depth < 3 {
+			fillAPLTextSample(value.Message(), depth+1)
+		}
+	}
+	return value
+}
+
+func testAPLTextRoundTrip(t *testing.T, rotation *proto.APLRotation, resolver *APLNameResolver) {
+	text := FormatAPLText(rotation, resolver)
+	parsed, err := ParseAPLText(text, resolver)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %s", text, err)
+	}
+	if !googleProto.Equal(rotation, parsed) {
+		t.Fatalf("Round trip of %q changed the rotation, got %q", text, FormatAPLText(parsed, resolver))
+	}
+}
+
+func TestAPLTextRoundTripAllKinds(t *testing.T) {
+	actionFields := aplTextActionOneof.Fields()
+	for i := 0; i < actionFields.Len(); i++ {
+		action := &proto.APLAction{}
+		m := action.ProtoReflect()
+		value := m.NewField(actionFields.Get(i))
+		fillAPLTextSample(value.Message(), 0)
+		m.Set(actionFields.Get(i), value)
+
+		testAPLTextRoundTrip(t, &proto.APLRotation{
+			Type:         proto.APLRotation_TypeAPL,
+			PriorityList: []*proto.APLListItem{{Action: action}},
+		}, nil)
+	}
+
+	valueFields := aplTextValueOneof.Fields()
+	for i := 0; i < valueFields.Len(); i++ {
+		condition := &proto.APLValue{}
+		m := condition.ProtoReflect()
+		value := m.NewField(valueFields.Get(i))
+		fillAPLTextSample(value.Message(), 0)
+		m.Set(valueFields.Get(i), value)
+
+		testAPLTextRoundTrip(t, &proto.APLRotation{
+			Type: proto.APLRotation_TypeAPL,
+			PriorityList: []*proto.APLListItem{{Action: &proto.APLAction{
+				Condition: condition,
+				Action:    &proto.APLAction_CastSpell{CastSpell: &proto.APLActionCastSpell{SpellId: ActionID{SpellID: 133}.ToProto()}},
+			}}},
+		}, nil)
+	}
+}
+
+func TestAPLTextRoundTripRotation(t *testing.T) {
+	text := `variable burst: bool = false
+variable "pool amount": float
+macro low_mana = current_mana_percent < 20%
+
+prepull -1.5s: cast(spell:116)
+hide prepull: none
+
+set_variable(burst, true) if !variable(burst) && (current_time > 10s || is_execute_phase)
+cast(spell:133, Target) if (current_mana - 100) * 2 >= max(1, spell_current_cost(spell:133)) // opener "notes"
+channel(spell:15407, interrupt_if: gcd_ready) if macro(low_mana) == "some text" || current_time - -1s < 0.5 / (2 - 1)
+strict_sequence(cast(item:1) if aura_active(spell:2#1), cast(other:OtherActionWait), none)
+cast(spell:25304@11) if aura_stacks({tag: 2}) > 0
+hide run_action_list(aoe) // "multi\nline"
+
+list aoe:
+  cast(spell:10, {type: Target, index: 2}) if and(gcd_ready) || !!cmp(OpLt, rhs: 1)
+  none // empty
+`
+	rotation, err := ParseAPLText(text, nil)
+	if err != nil {
+		t.Fatalf("Failed to parse rotation: %s", err)
+	}
+	if formatted := FormatAPLText(rotation, nil); formatted != text {
+		t.Fatalf("Expected formatted rotation to match the original text, got:\n%s", formatted)
+	}
+	testAPLTextRoundTrip(t, rotation, nil)
+}
+
+func TestAPLTextNames(t *testing.T) {
+	unit := &Unit{}
+	unit.Spellbook = []*Spell{
+		{ActionID: ActionID{SpellID: 133}, Rank: 1},
+		{ActionID: ActionID{SpellID: 10151}, Rank: 12},
+		{ActionID: ActionID{SpellID: 116}},
+		{ActionID: ActionID{SpellID: 205}},
+	}
+	unit.auras = []*Aura{
+		{Label: "Hot Streak", ActionID: ActionID{SpellID: 48108}},
+		{Label: "Frostbolt", ActionID: ActionID{SpellID: 116}},
+	}
+	resolver := NewAPLNameResolver(unit, &proto.UIDatabase{SpellIcons: []*proto.IconData{
+		{Id: 133, Name: "Fireball", Rank: 1},
+		{Id: 10151, Name: "Fireball", Rank: 12},
+		{Id: 116, Name: "Frostbolt"},
+		{Id: 205, Name: "Frostbolt"},
+	}})
+
+	text := "cast(Fireball) if aura_remaining(Hot Streak) < 1.5s && gcd_ready\n"
+	rotation, err := ParseAPLText(text, resolver)
+	if err != nil {
+		t.Fatalf("Failed to parse rotation: %s", err)
+	}
+	expected := &proto.APLRotation{
+		Type: proto.APLRotation_TypeAPL,
+		PriorityList: []*proto.APLListItem{{Action: &proto.APLAction{
+			Condition: &proto.APLValue{Value: &proto.APLValue_And{And: &proto.APLValueAnd{Vals: []*proto.APLValue{
+				{Value: &proto.APLValue_Cmp{Cmp: &proto.APLValueCompare{
+					Op:  proto.APLValueCompare_OpLt,
+					Lhs: &proto.APLValue{Value: &proto.APLValue_AuraRemainingTime{AuraRemainingTime: &proto.APLValueAuraRemainingTime{AuraId: ActionID{SpellID: 48108}.ToProto()}}},
+					Rhs: newAPLTextConst("1.5s"),
+				}}},
+				{Value: &proto.APLValue_GcdIsReady{GcdIsReady: &proto.APLValueGCDIsReady{}}},
+			}}}},
+			Action: &proto.APLAction_CastSpell{CastSpell: &proto.APLActionCastSpell{SpellId: ActionID{SpellID: 10151}.ToProto()}},
+		}}},
+	}
+	if !googleProto.Equal(rotation, expected) {
+		t.Fatalf("Unexpected rotation: %s", rotation)
+	}
+	if formatted := FormatAPLText(rotation, resolver); formatted != text {
+		t.Fatalf("Expected %q, got %q", text, formatted)
+	}
+
+	text = "cast(Fireball#1@12)\n"
+	if rotation, err = ParseAPLText(text, resolver); err != nil {
+		t.Fatalf("Failed to parse rotation: %s", err)
+	}
+	if spellID := rotation.PriorityList[0].Action.GetCastSpell().SpellId; spellID.GetSpellId() != 10151 || spellID.Tag != 1 || spellID.Rank != 12 {
+		t.Fatalf("Unexpected spell: %s", spellID)
+	}
+	if formatted := FormatAPLText(rotation, resolver); formatted != text {
+		t.Fatalf("Expected %q, got %q", text, formatted)
+	}
+
+	if _, err := ParseAPLText("cast(Frostbolt)", resolver); err == nil {
+		t.Fatalf("Expected an error for an ambiguous name")
+	}
+	if _, err := ParseAPLText("cast(Fireball)", nil); err == nil {
+		t.Fatalf("Expected an error for a name without a resolver")
+	}
+	if name := resolver.Name(ActionID{SpellID: 133}, false); name != "" {
+		t.Fatalf("Lower ranks shouldn't be named, got %s", name)
+	}
+	if name := resolver.Name(ActionID{SpellID: 48108}, true); name != "Hot Streak" {
+		t.Fatalf("Auras should fall back to their label, got %s", name)
+	}
+}
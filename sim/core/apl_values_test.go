@@ -42,3 +42,121 @@ func TestValueConst(t *testing.T) {
 		t.Fatalf("Unexpected coerced duration value %s", coercedDurVal.GetDuration(sim))
 	}
 }
+
+func TestValueVariable(t *testing.T) {
+	sim := &Simulation{}
+	unit := &Unit{}
+	rot := &APLRotation{
+		unit: unit,
+	}
+	rot.variables = []*aplVariable{{
+		name:         "count",
+		valueType:    proto.APLValueType_ValueTypeInt,
+		initialValue: rot.newValueConst(&proto.APLValueConst{Val: "3"}),
+	}}
+	rot.reset(sim)
+
+	countVal := rot.newValueVariable(&proto.APLValueVariable{Name: "count"})
+	if countVal.Type() != proto.APLValueType_ValueTypeInt || countVal.GetInt(sim) != 3 {
+		t.Fatalf("Unexpected initial variable value %d", countVal.GetInt(sim))
+	}
+
+	setCount := &APLActionSetVariable{
+		unit:     unit,
+		variable: rot.getVariable("count"),
+		value:    rot.newValueConst(&proto.APLValueConst{Val: "5"}),
+	}
+	if !setCount.IsReady(sim) {
+		t.Fatalf("Set Variable should be ready while it would change the variable")
+	}
+	setCount.Execute(sim)
+	if countVal.GetInt(sim) != 5 {
+		t.Fatalf("Unexpected variable value %d after setting it", countVal.GetInt(sim))
+	}
+	if setCount.IsReady(sim) {
+		t.Fatalf("Set Variable should not be ready once the variable has its value")
+	}
+
+	rot.reset(sim)
+	if countVal.GetInt(sim) != 3 {
+		t.Fatalf("Variable should be reset to its initial value, got %d", countVal.GetInt(sim))
+	}
+
+	if rot.newValueVariable(&proto.APLValueVariable{Name: "missing"}) != nil || len(rot.curWarnings) != 1 {
+		t.Fatalf("Undefined variables should be rejected with a warning, got %v", rot.curWarnings)
+	}
+	if canCoerceAPLValueType(proto.APLValueType_ValueTypeString, proto.APLValueType_ValueTypeInt) {
+		t.Fatalf("Strings should not be coercible to ints")
+	}
+}
+
+func TestValueMacroInnerValues(t *testing.T) {
+	config, err := ParseAPLText(`macro pooled = current_energy >= 60 && sequence_is_ready(missing)
+macro early = current_time < 20%
+wait(1s) if macro(pooled)
+wait(2s) if macro(pooled) && macro(early)
+`, nil)
+	if err != nil {
+		t.Fatalf("Failed to parse rotation: %s", err)
+	}
+
+	target := &Target{}
+	env := &Environment{Raid: &Raid{}, Encounter: Encounter{Targets: []*Target{target}}}
+	target.Env = env
+	unit := &target.Unit
+	unit.energyBar = energyBar{unit: unit, maxEnergy: 100}
+	unit.Rotation = unit.newAPLRotation(config)
+
+	// The macro is finalized once, so its warning is only reported on the macro.
+	stats := unit.Rotation.getStats()
+	if warnings := stats.Macros[0].Warnings; len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning for the missing sequence, got %v", warnings)
+	}
+	for i, row := range stats.PriorityList {
+		if len(row.Warnings) != 0 {
+			t.Fatalf("Expected no warnings on row %d, got %v", i, row.Warnings)
+		}
+	}
+
+	// So is the type mismatch inside the other macro.
+	if diagnostics := stats.Diagnostics; len(diagnostics) != 1 || diagnostics[0].Section != proto.APLDiagnostic_SectionMacros || diagnostics[0].Row != 1 {
+		t.Fatalf("Expected a single diagnostic for the second macro, got %v", diagnostics)
+	}
+
+	// Energy comparisons inside macros are decision thresholds, like any other comparison.
+	unit.energyBar.setupEnergyThresholds()
+	if thresholds := unit.energyBar.energyDecisionThresholds; len(thresholds) != 1 || thresholds[0] != 60 {
+		t.Fatalf("Expected an energy threshold of 60, got %v", thresholds)
+	}
+}
+
+func TestValueMacroReferencedBeforeItsRow(t *testing.T) {
+	config, err := ParseAPLText(`variable pooling: bool = macro(pooled)
+macro burst = macro(pooled) && current_time < 20s
+macro pooled = current_energy >= 60 && sequence_is_ready(missing)
+wait(1s) if macro(burst) || variable(pooling)
+`, nil)
+	if err != nil {
+		t.Fatalf("Failed to parse rotation: %s", err)
+	}
+
+	target := &Target{}
+	env := &Environment{Raid: &Raid{}, Encounter: Encounter{Targets: []*Target{target}}}
+	target.Env = env
+	unit := &target.Unit
+	unit.energyBar = energyBar{unit: unit, maxEnergy: 100}
+	unit.Rotation = unit.newAPLRotation(config)
+
+	// Variables can reference macros, and a macro's warnings stay on its own row
+	// when another row references it first.
+	stats := unit.Rotation.getStats()
+	if warnings := stats.Variables[0].Warnings; len(warnings) != 0 {
+		t.Fatalf("Expected no warnings for the variable, got %v", warnings)
+	}
+	if warnings := stats.Macros[0].Warnings; len(warnings) != 0 {
+		t.Fatalf("Expected no warnings for the referencing macro, got %v", warnings)
+	}
+	if warnings := stats.Macros[1].Warnings; len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning for the missing sequence, got %v", warnings)
+	}
+}
@@ -0,0 +1,322 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+type aplVariable struct {
+	name         string
+	valueType    proto.APLValueType
+	initialValue APLValue // Already coerced to valueType. Nil for the zero value.
+
+	boolVal     bool
+	intVal      int32
+	floatVal    float64
+	durationVal time.Duration
+	stringVal   string
+}
+
+func (rot *APLRotation) newAPLVariable(config *proto.APLVariable) *aplVariable {
+	if config.Name == "" {
+		rot.ValidationWarning("Variables must have a name")
+		return nil
+	}
+	if rot.getVariable(config.Name) != nil {
+		rot.ValidationWarning("Duplicate variable name: '%s'", config.Name)
+		return nil
+	}
+	if config.Type == proto.APLValueType_ValueTypeUnknown {
+		rot.ValidationWarning("Variable '%s' must have a type", config.Name)
+		return nil
+	}
+
+	variable := &aplVariable{
+		name:      config.Name,
+		valueType: config.Type,
+	}
+	if initialValue := rot.newAPLValue(config.InitialValue); initialValue != nil {
+		if !canCoerceAPLValueType(initialValue.Type(), config.Type) {
+			rot.ValidationWarning("Initial value of variable '%s' has type %s, expected %s", config.Name, initialValue.Type(), config.Type)
+		} else {
+			variable.initialValue = rot.coerceTo(initialValue, config.Type)
+		}
+	}
+	return variable
+}
+
+func (rot *APLRotation) getVariable(name string) *aplVariable {
+	for _, variable := range rot.variables {
+		if variable.name == name {
+			return variable
+		}
+	}
+	return nil
+}
+
+func (variable *aplVariable) reset(sim *Simulation) {
+	variable.boolVal = false
+	variable.intVal = 0
+	variable.floatVal = 0
+	variable.durationVal = 0
+	variable.stringVal = ""
+	if variable.initialValue != nil {
+		variable.set(sim, variable.initialValue)
+	}
+}
+
+// Sets the variable to the current result of value, which must have the variable's type.
+func (variable *aplVariable) set(sim *Simulation, value APLValue) {
+	switch variable.valueType {
+	case proto.APLValueType_ValueTypeBool:
+		variable.boolVal = value.GetBool(sim)
+	case proto.APLValueType_ValueTypeInt:
+		variable.intVal = value.GetInt(sim)
+	case proto.APLValueType_ValueTypeFloat:
+		variable.floatVal = value.GetFloat(sim)
+	case proto.APLValueType_ValueTypeDuration:
+		variable.durationVal = value.GetDuration(sim)
+	case proto.APLValueType_ValueTypeString:
+		variable.stringVal = value.GetString(sim)
+	}
+}
+
+// Whether the variable already holds the current result of value, which must have the variable's type.
+func (variable *aplVariable) equals(sim *Simulation, value APLValue) bool {
+	switch variable.valueType {
+	case proto.APLValueType_ValueTypeBool:
+		return variable.boolVal == value.GetBool(sim)
+	case proto.APLValueType_ValueTypeInt:
+		return variable.intVal == value.GetInt(sim)
+	case proto.APLValueType_ValueTypeFloat:
+		return variable.floatVal == value.GetFloat(sim)
+	case proto.APLValueType_ValueTypeDuration:
+		return variable.durationVal == value.GetDuration(sim)
+	case proto.APLValueType_ValueTypeString:
+		return variable.stringVal == value.GetString(sim)
+	}
+	return true
+}
+
+func (variable *aplVariable) valueString() string {
+	switch variable.valueType {
+	case proto.APLValueType_ValueTypeBool:
+		return strconv.FormatBool(variable.boolVal)
+	case proto.APLValueType_ValueTypeInt:
+		return strconv.Itoa(int(variable.intVal))
+	case proto.APLValueType_ValueTypeFloat:
+		return fmt.Sprintf("%.3f", variable.floatVal)
+	case proto.APLValueType_ValueTypeDuration:
+		return variable.durationVal.String()
+	case proto.APLValueType_ValueTypeString:
+		return variable.stringVal
+	}
+	return ""
+}
+
+// Whether APLValueCoerced can convert values of type from into type to, without panicking.
+func canCoerceAPLValueType(from proto.APLValueType, to proto.APLValueType) bool {
+	switch {
+	case from == to:
+		return true
+	case from == proto.APLValueType_ValueTypeString:
+		return to == proto.APLValueType_ValueTypeBool
+	case from == proto.APLValueType_ValueTypeBool:
+		return to == proto.APLValueType_ValueTypeInt || to == proto.APLValueType_ValueTypeFloat
+	default:
+		return true
+	}
+}
+
+type aplMacro struct {
+	name      string
+	config    *proto.APLValue
+	configIdx int // Index in the rotation's macros, where warnings are recorded.
+	value     APLValue
+	parsed    bool
+	parsing   bool // Used to detect macros which reference themselves.
+}
+
+func (rot *APLRotation) getMacro(name string) *aplMacro {
+	for _, macro := range rot.macros {
+		if macro.name == name {
+			return macro
+		}
+	}
+	return nil
+}
+
+// Parses the value of a macro the first time it is needed, so macros can
+// reference each other regardless of their order. Warnings from parsing are
+// recorded on the macro, not on whatever referenced it first.
+func (rot *APLRotation) parseMacro(macro *aplMacro) APLValue {
+	if macro.parsing {
+		rot.ValidationWarning("Macro '%s' references itself", macro.name)
+		return nil
+	}
+	if !macro.parsed {
+		referencingWarnings := rot.curWarnings
+		rot.curWarnings = nil
+		macro.parsing = true
+		macro.value = rot.newAPLValue(macro.config)
+		macro.parsing = false
+		macro.parsed = true
+		rot.macroWarnings[macro.configIdx] = append(rot.macroWarnings[macro.configIdx], rot.curWarnings...)
+		rot.curWarnings = referencingWarnings
+	}
+	return macro.value
+}
+
+type APLActionSetVariable struct {
+	defaultAPLActionImpl
+	unit     *Unit
+	variable *aplVariable
+	value    APLValue
+}
+
+func (rot *APLRotation) newActionSetVariable(config *proto.APLActionSetVariable) APLActionImpl {
+	variable := rot.getVariable(config.Name)
+	if variable == nil {
+		rot.ValidationWarning("No variable with name: '%s'", config.Name)
+		return nil
+	}
+	value := rot.newAPLValue(config.Value)
+	if value == nil {
+		rot.ValidationWarning("Set Variable(%s) must provide a value", config.Name)
+		return nil
+	}
+	if !canCoerceAPLValueType(value.Type(), variable.valueType) {
+		rot.ValidationWarning("Cannot set variable '%s' of type %s to a value of type %s", config.Name, variable.valueType, value.Type())
+		return nil
+	}
+	return &APLActionSetVariable{
+		unit:     rot.unit,
+		variable: variable,
+		value:    rot.coerceTo(value, variable.valueType),
+	}
+}
+func (action *APLActionSetVariable) GetAPLValues() []APLValue {
+	return []APLValue{action.value}
+}
+
+// Only ready when the variable would change, so that setting it doesn't loop forever.
+func (action *APLActionSetVariable) IsReady(sim *Simulation) bool {
+	return !action.variable.equals(sim, action.value)
+}
+func (action *APLActionSetVariable) Execute(sim *Simulation) {
+	action.variable.set(sim, action.value)
+	if sim.Log != nil {
+		action.unit.Log(sim, "Set variable %s = %s", action.variable.name, action.variable.valueString())
+	}
+}
+func (action *APLActionSetVariable) String() string {
+	return fmt.Sprintf("Set Variable(%s = %s)", action.variable.name, action.value)
+}
+
+type APLValueVariable struct {
+	DefaultAPLValueImpl
+	variable *aplVariable
+}
+
+func (rot *APLRotation) newValueVariable(config *proto.APLValueVariable) APLValue {
+	variable := rot.getVariable(config.Name)
+	if variable == nil {
+		rot.ValidationWarning("No variable with name: '%s'", config.Name)
+		return nil
+	}
+	return &APLValueVariable{
+		variable: variable,
+	}
+}
+func (value *APLValueVariable) Type() proto.APLValueType {
+	return value.variable.valueType
+}
+func (value *APLValueVariable) GetBool(_ *Simulation) bool {
+	return value.variable.boolVal
+}
+func (value *APLValueVariable) GetInt(_ *Simulation) int32 {
+	return value.variable.intVal
+}
+func (value *APLValueVariable) GetFloat(_ *Simulation) float64 {
+	return value.variable.floatVal
+}
+func (value *APLValueVariable) GetDuration(_ *Simulation) time.Duration {
+	return value.variable.durationVal
+}
+func (value *APLValueVariable) GetString(_ *Simulation) string {
+	return value.variable.stringVal
+}
+func (value *APLValueVariable) String() string {
+	return fmt.Sprintf("Variable(%s)", value.variable.name)
+}
+
+// References the value of a macro. The macro's value is shared by all references,
+// and the rotation only finalizes it once.
+type APLValueMacro struct {
+	DefaultAPLValueImpl
+	name  string
+	inner APLValue
+}
+
+func (rot *APLRotation) newValueMacro(config *proto.APLValueMacro) APLValue {
+	macro := rot.getMacro(config.Name)
+	if macro == nil {
+		rot.ValidationWarning("No macro with name: '%s'", config.Name)
+		return nil
+	}
+	inner := rot.parseMacro(macro)
+	if inner == nil {
+		rot.ValidationWarning("Macro '%s' has no valid value", config.Name)
+		return nil
+	}
+	return &APLValueMacro{
+		name:  config.Name,
+		inner: inner,
+	}
+}
+func (value *APLValueMacro) GetInnerValues() []APLValue {
+	return []APLValue{value.inner}
+}
+
+// Like allInnerAPLValues, but without the values of other macros referenced by
+// this one, which are finalized on the rows of those macros.
+func ownMacroValues(root APLValue) []APLValue {
+	var values []APLValue
+	unprocessed := []APLValue{root}
+	for len(unprocessed) > 0 {
+		next := unprocessed[len(unprocessed)-1]
+		unprocessed = unprocessed[:len(unprocessed)-1]
+		if next == nil {
+			continue
+		}
+		values = append(values, next)
+		if _, ok := next.(*APLValueMacro); !ok {
+			unprocessed = append(unprocessed, next.GetInnerValues()...)
+		}
+	}
+	return values
+}
+func (value *APLValueMacro) Type() proto.APLValueType {
+	return value.inner.Type()
+}
+func (value *APLValueMacro) GetBool(sim *Simulation) bool {
+	return value.inner.GetBool(sim)
+}
+func (value *APLValueMacro) GetInt(sim *Simulation) int32 {
+	return value.inner.GetInt(sim)
+}
+func (value *APLValueMacro) GetFloat(sim *Simulation) float64 {
+	return value.inner.GetFloat(sim)
+}
+func (value *APLValueMacro) GetDuration(sim *Simulation) time.Duration {
+	return value.inner.GetDuration(sim)
+}
+func (value *APLValueMacro) GetString(sim *Simulation) string {
+	return value.inner.GetString(sim)
+}
+func (value *APLValueMacro) String() string {
+	return fmt.Sprintf("Macro(%s)", value.name)
+}
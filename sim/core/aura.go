@@ -61,6 +61,8 @@ type Aura struct {
 	expires   time.Duration // Time at which aura will be removed.
 	fadeTime  time.Duration // Time at which the aura was actually removed.
 
+	stacksChangedAt time.Duration // Time at which the current number of stacks was set.
+
 	// The unit this aura is attached to.
 	Unit *Unit
 
@@ -151,6 +153,7 @@ func (aura *Aura) doneIteration(sim *Simulation) {
 	}
 
 	aura.startTime = 0
+	aura.stacksChangedAt = 0
 	aura.expires = 0
 
 	if aura.OnDoneIteration != nil {
@@ -176,8 +179,13 @@ func (aura *Aura) Refresh(sim *Simulation) {
 		}
 	}
 
+	if aura.IsActive() {
+		aura.metrics.Refreshes++
+	}
+
 	if sim.Log != nil && aura.IsActive() && !aura.ActionID.IsEmptyAction() {
 		aura.Unit.Log(sim, "Aura refreshed: %s", aura.ActionID)
+		aura.emitAuraEvent(sim, proto.CombatEventType_CombatEventAuraRefresh)
 	}
 }
 
@@ -208,7 +216,14 @@ func (aura *Aura) SetStacks(sim *Simulation, newStacks int32) {
 	if sim.Log != nil {
 		aura.Unit.Log(sim, "%s stacks: %d --> %d", aura.ActionID, oldStacks, newStacks)
 	}
+	if aura.active && !aura.ActionID.IsEmptyAction() {
+		aura.addStackUptime(sim.CurrentTime)
+	}
 	aura.stacks = newStacks
+	aura.stacksChangedAt = sim.CurrentTime
+	if sim.Log != nil {
+		aura.emitAuraEvent(sim, proto.CombatEventType_CombatEventAuraStacks)
+	}
 	if aura.OnStacksChange != nil {
 		aura.OnStacksChange(aura, sim, oldStacks, newStacks)
 	}
@@ -234,6 +249,18 @@ func (aura *Aura) UpdateExpires(sim *Simulation, newExpires time.Duration) {
 	}
 }
 
+// Adds the uptime of the current stacks, from when they were set until end, to the metrics.
+func (aura *Aura) addStackUptime(end time.Duration) {
+	start := max(aura.stacksChangedAt, 0)
+	if aura.stacks == 0 || end <= start {
+		return
+	}
+	aura.metrics.StackUptime += time.Duration(aura.stacks) * (end - start)
+	if timeline := aura.Unit.Metrics.timeline; timeline != nil {
+		timeline.addAuraStacks(aura.ActionID, aura.stacks, start, end)
+	}
+}
+
 // The amount of time this aura has been active.
 func (aura *Aura) TimeActive(sim *Simulation) time.Duration {
 	if aura.IsActive() {
@@ -381,8 +408,13 @@ func (at *auraTracker) registerAura(unit *Unit, aura Aura) *Aura {
 	if at.GetAura(aura.Label) != nil {
 		panic(fmt.Sprintf("Aura %s already registered!", aura.Label))
 	}
-	if len(at.auras) > 200 {
-		panic(fmt.Sprintf("Over 200 registered auras when registering %s! There is probably an aura being registered every iteration.", aura.Label))
+	maxAuras := 200
+	if unit.Type == EnemyUnit && unit.Env != nil && unit.Env.Raid != nil {
+		// Every player in the raid registers its debuffs and DoTs on the targets.
+		maxAuras *= max(1, len(unit.Env.Raid.AllPlayerUnits))
+	}
+	if len(at.auras) > maxAuras {
+		panic(fmt.Sprintf("Over %d registered auras when registering %s! There is probably an aura being registered every iteration.", maxAuras, aura.Label))
 	}
 
 	newAura := &Aura{}
@@ -582,7 +614,9 @@ func (aura *Aura) Activate(sim *Simulation) {
 		}
 	}
 
+	aura.metrics.Applications++
 	aura.startTime = sim.CurrentTime
+	aura.stacksChangedAt = sim.CurrentTime
 	aura.Refresh(sim)
 	aura.active = true
 
@@ -643,6 +677,7 @@ func (aura *Aura) Activate(sim *Simulation) {
 
 	if sim.Log != nil && !aura.ActionID.IsEmptyAction() {
 		aura.Unit.Log(sim, "Aura gained: %s", aura.ActionID)
+		aura.emitAuraEvent(sim, proto.CombatEventType_CombatEventAuraGain)
 	}
 
 	// don't invoke possible callbacks until the internal state is consistent
@@ -663,8 +698,18 @@ func (aura *Aura) Deactivate(sim *Simulation) {
 		oldTime := sim.CurrentTime
 		sim.CurrentTime = min(sim.CurrentTime, aura.expires)
 		aura.metrics.Uptime += sim.CurrentTime - max(aura.startTime, 0)
+		// The stacks are only removed after the aura has faded, so add their uptime up to the fade time here.
+		aura.addStackUptime(sim.CurrentTime)
+		aura.stacksChangedAt = sim.CurrentTime
+		if timeline := aura.Unit.Metrics.timeline; timeline != nil {
+			timeline.addAuraUptime(aura.ActionID, max(aura.startTime, 0), sim.CurrentTime)
+		}
+		if overlaps := aura.Unit.Metrics.auraOverlaps; overlaps != nil {
+			overlaps.addAuraUptime(aura, max(aura.startTime, 0), sim.CurrentTime)
+		}
 		if sim.Log != nil {
 			aura.Unit.Log(sim, "Aura faded: %s", aura.ActionID)
+			aura.emitAuraEvent(sim, proto.CombatEventType_CombatEventAuraFade)
 		}
 		sim.CurrentTime = oldTime
 	}
@@ -880,6 +925,16 @@ func (at *auraTracker) GetMetricsProto() []*proto.AuraMetrics {
 	return metrics
 }
 
+// Adds the aura metrics of the same unit in another Environment. Auras are
+// matched by label, since some are only registered once the sim is running.
+func (at *auraTracker) mergeMetrics(other *auraTracker) {
+	for _, otherAura := range other.auras {
+		if aura := at.GetAura(otherAura.Label); aura != nil {
+			aura.metrics.merge(&otherAura.metrics)
+		}
+	}
+}
+
 // Invokes the OnRageChange for all tracked auras
 func (at *auraTracker) OnRageChange(sim *Simulation, metrics *ResourceMetrics) {
 	for _, aura := range at.onRageChangeAuras {
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// auraOverlaps tracks how long each pair of the auras selected in SimOptions
+// was active at the same time on a unit. Values are sums across iterations.
+type auraOverlaps struct {
+	ids        []ActionID
+	overlaps   []float64 // Seconds both auras were active, row-major by index in ids.
+	iterations int32
+}
+
+func newAuraOverlaps(auraIDs []*proto.ActionID) *auraOverlaps {
+	overlaps := &auraOverlaps{
+		overlaps: make([]float64, len(auraIDs)*len(auraIDs)),
+	}
+	for _, auraID := range auraIDs {
+		overlaps.ids = append(overlaps.ids, ProtoToActionID(auraID))
+	}
+	return overlaps
+}
+
+func (overlaps *auraOverlaps) index(actionID ActionID) int {
+	for i, id := range overlaps.ids {
+		if id == actionID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Adds the overlaps of an aura which was active from start to end, if the aura
+// is tracked. Called when the aura fades, so each overlap with another tracked
+// aura is added by whichever of the two fades first.
+func (overlaps *auraOverlaps) addAuraUptime(aura *Aura, start time.Duration, end time.Duration) {
+	i := overlaps.index(aura.ActionID)
+	if i == -1 {
+		return
+	}
+	numIDs := len(overlaps.ids)
+	overlaps.overlaps[i*numIDs+i] += (end - start).Seconds()
+
+	for _, other := range aura.Unit.auras {
+		if other == aura || !other.active {
+			continue
+		}
+		j := overlaps.index(other.ActionID)
+		if j == -1 {
+			continue
+		}
+		// Expired auras are only deactivated lazily, so end the other aura at its expiration.
+		overlap := min(end, other.expires) - max(start, other.startTime)
+		if overlap > 0 {
+			overlaps.overlaps[i*numIDs+j] += overlap.Seconds()
+			overlaps.overlaps[j*numIDs+i] += overlap.Seconds()
+		}
+	}
+}
+
+func (overlaps *auraOverlaps) doneIteration() {
+	overlaps.iterations++
+}
+
+// Adds the values of the same unit's overlaps in another Environment.
+func (overlaps *auraOverlaps) merge(other *auraOverlaps) {
+	overlaps.iterations += other.iterations
+	for i, overlap := range other.overlaps {
+		overlaps.overlaps[i] += overlap
+	}
+}
+
+func (overlaps *auraOverlaps) ToProto() *proto.AuraOverlaps {
+	overlapsProto := &proto.AuraOverlaps{
+		OverlapSecondsAvg: make([]float64, len(overlaps.overlaps)),
+	}
+	for _, id := range overlaps.ids {
+		overlapsProto.Ids = append(overlapsProto.Ids, id.ToProto())
+	}
+	if overlaps.iterations > 0 {
+		for i, overlap := range overlaps.overlaps {
+			overlapsProto.OverlapSecondsAvg[i] = overlap / float64(overlaps.iterations)
+		}
+	}
+	return overlapsProto
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+func TestAuraOverlaps(t *testing.T) {
+	trinketID := ActionID{ItemID: 1}
+	cooldownID := ActionID{SpellID: 2}
+	procID := ActionID{SpellID: 3}
+	overlaps := newAuraOverlaps([]*proto.ActionID{trinketID.ToProto(), cooldownID.ToProto(), procID.ToProto()})
+
+	unit := &Unit{}
+	trinket := &Aura{ActionID: trinketID, Unit: unit, active: true, startTime: 0, expires: time.Second * 20}
+	cooldown := &Aura{ActionID: cooldownID, Unit: unit, active: true, startTime: time.Second * 5, expires: time.Second * 15}
+	proc := &Aura{ActionID: procID, Unit: unit, active: true, startTime: time.Second * 10, expires: time.Second * 12}
+	untracked := &Aura{ActionID: ActionID{SpellID: 4}, Unit: unit, active: true, startTime: 0, expires: NeverExpires}
+	unit.auras = []*Aura{trinket, cooldown, proc, untracked}
+
+	// The proc has already expired, but is deactivated lazily.
+	cooldown.active = false
+	overlaps.addAuraUptime(cooldown, cooldown.startTime, cooldown.expires)
+	trinket.active = false
+	overlaps.addAuraUptime(trinket, trinket.startTime, trinket.expires)
+	proc.active = false
+	overlaps.addAuraUptime(proc, proc.startTime, proc.expires)
+	overlaps.doneIteration()
+
+	expected := []float64{
+		20, 10, 2,
+		10, 10, 2,
+		2, 2, 2,
+	}
+	result := overlaps.ToProto()
+	if len(result.Ids) != 3 {
+		t.Fatalf("Expected 3 ids, got %d", len(result.Ids))
+	}
+	for i := range expected {
+		if result.OverlapSecondsAvg[i] != expected[i] {
+			t.Fatalf("Overlaps %v, expected %v", result.OverlapSecondsAvg, expected)
+		}
+	}
+
+	overlaps.merge(newAuraOverlaps(result.Ids))
+	if avg := overlaps.ToProto().OverlapSecondsAvg[1]; avg != 10 {
+		t.Fatalf("Merging an empty matrix should not change the averages, got %v", avg)
+	}
+}
@@ -19,10 +19,15 @@ import (
 
 const (
 	defaultIterationsPerCombo = 1000
+
+	// Combos whose difference to another combo has a p-value below this are significantly different.
+	bulkSignificanceLevel = 0.05
+	// Standard normal quantile of a two-sided 95% confidence interval.
+	bulkConfidenceZ = 1.959964
 )
 
 // raidSimRunner runs a standard raid simulation.
-type raidSimRunner func(*proto.RaidSimRequest, chan *proto.ProgressMetrics, bool) *proto.RaidSimResult
+type raidSimRunner func(context.Context, *proto.RaidSimRequest, chan *proto.ProgressMetrics, bool) *proto.RaidSimResult
 
 // bulkSimRunner runs a bulk simulation.
 type bulkSimRunner struct {
@@ -72,6 +77,9 @@ func (b *bulkSimRunner) Run(pctx context.Context, progress chan *proto.ProgressM
 		cancel()
 	}()
 
+	// The request is modified below, so work on a copy of the caller's.
+	b.Request = goproto.Clone(b.Request).(*proto.BulkSimRequest)
+
 	// Bulk simming is only supported for the single-player use (i.e. not whole raid-wide simming).
 	// Verify that we have exactly 1 player.
 	var playerCount int
@@ -96,6 +104,13 @@ func (b *bulkSimRunner) Run(pctx context.Context, progress chan *proto.ProgressM
 	// clean to reduce memory
 	player.Database = nil
 
+	// Sim every combo with the same seeds and keep the value of each iteration, so that
+	// combos can be compared iteration by iteration.
+	b.Request.BaseSettings.SimOptions.SaveAllValues = true
+	if b.Request.BaseSettings.SimOptions.RandomSeed == 0 {
+		b.Request.BaseSettings.SimOptions.RandomSeed = time.Now().UnixNano()
+	}
+
 	// Gemming for now can happen before slots are decided.
 	// We might have to add logic after slot decisions if we want to enforce keeping meta gem active.
 
@@ -192,9 +207,14 @@ func (b *bulkSimRunner) Run(pctx context.Context, progress chan *proto.ProgressM
 			break
 		}
 
+		// More iterations won't make results more accurate than the requested target error.
+		if b.reachedTargetError(rankedResults) {
+			break
+		}
+
 		// Increase accuracy
 		newIters *= 2
-		newNumCombos := len(rankedResults) / 2
+		newNumCombos := numCombosToKeep(rankedResults)
 		validCombos = validCombos[:newNumCombos]
 		rankedResults = rankedResults[:newNumCombos]
 		for i, comb := range rankedResults {
@@ -214,29 +234,26 @@ func (b *bulkSimRunner) Run(pctx context.Context, progress chan *proto.ProgressM
 		rankedResults = rankedResults[:maxResults]
 	}
 
-	bum := baseResult.Result.GetRaidMetrics().GetParties()[0].GetPlayers()[0]
-	bum.Actions = nil
-	bum.Auras = nil
-	bum.Resources = nil
-	bum.Pets = nil
+	bestResult := rankedResults[0]
 
 	result = &proto.BulkSimResult{
 		EquippedGearResult: &proto.BulkComboResult{
-			UnitMetrics: bum,
+			UnitMetrics:               baseResult.comboUnitMetrics(),
+			IndistinguishableFromBest: !baseResult.isSignificantlyWorseThan(bestResult),
 		},
 	}
 
 	for _, r := range rankedResults {
-		um := r.Result.GetRaidMetrics().GetParties()[0].GetPlayers()[0]
-		um.Actions = nil
-		um.Auras = nil
-		um.Resources = nil
-		um.Pets = nil
-
-		result.Results = append(result.Results, &proto.BulkComboResult{
-			ItemsAdded:  r.ChangeLog.AddedItems,
-			UnitMetrics: um,
-		})
+		comboResult := &proto.BulkComboResult{
+			ItemsAdded:                r.ChangeLog.AddedItems,
+			UnitMetrics:               r.comboUnitMetrics(),
+			IndistinguishableFromBest: !r.isSignificantlyWorseThan(bestResult),
+		}
+		// The equipped gear is ranked along with the other combos, but isn't compared to itself.
+		if r != baseResult {
+			comboResult.VsEquipped = r.compareTo(baseResult)
+		}
+		result.Results = append(result.Results, comboResult)
 	}
 
 	if progress != nil {
@@ -248,6 +265,33 @@ func (b *bulkSimRunner) Run(pctx context.Context, progress chan *proto.ProgressM
 	return result, nil
 }
 
+// Returns how many of the ranked results to keep for the next fast mode round. This is the top
+// half, extended to every combo that is not significantly worse than the best one, since more
+// iterations are needed to tell those apart.
+func numCombosToKeep(rankedResults []*itemSubstitutionSimResult) int {
+	numCombos := len(rankedResults) / 2
+	for i := numCombos; i < len(rankedResults); i++ {
+		if !rankedResults[i].isSignificantlyWorseThan(rankedResults[0]) {
+			numCombos = i + 1
+		}
+	}
+	return numCombos
+}
+
+// Returns true if the base settings have a target error, and every result already reached it.
+func (b *bulkSimRunner) reachedTargetError(results []*itemSubstitutionSimResult) bool {
+	targetError := b.Request.GetBaseSettings().GetSimOptions().GetTargetRelativeError()
+	if targetError <= 0 {
+		return false
+	}
+	for _, r := range results {
+		if r.Result.RelativeError > targetError {
+			return false
+		}
+	}
+	return true
+}
+
 func (b *bulkSimRunner) getRankedResults(pctx context.Context, validCombos []singleBulkSim, iterations int64, progress chan *proto.ProgressMetrics) ([]*itemSubstitutionSimResult, *itemSubstitutionSimResult, error) {
 	concurrency := runtime.NumCPU() + 1
 	if concurrency <= 0 {
@@ -259,9 +303,10 @@ func (b *bulkSimRunner) getRankedResults(pctx context.Context, validCombos []sin
 		tickets <- struct{}{}
 	}
 
-	results := make(chan *itemSubstitutionSimResult, 10)
-
 	numCombinations := int32(len(validCombos))
+
+	// Buffered for every combo, so sims still running after a cancellation never block.
+	results := make(chan *itemSubstitutionSimResult, numCombinations)
 	totalIterationsUpperBound := int64(numCombinations) * iterations
 
 	var totalCompletedIterations int32
@@ -292,7 +337,11 @@ func (b *bulkSimRunner) getRankedResults(pctx context.Context, validCombos []sin
 	// launcher for all combos (limited by concurrency max)
 	go func() {
 		for _, singleCombo := range validCombos {
-			<-tickets
+			select {
+			case <-tickets:
+			case <-ctx.Done():
+				return
+			}
 			singleSimProgress := make(chan *proto.ProgressMetrics)
 			// watches this progress and pushes up to main reporter.
 			go func(prog chan *proto.ProgressMetrics) {
@@ -309,10 +358,21 @@ func (b *bulkSimRunner) getRankedResults(pctx context.Context, validCombos []sin
 			// actually run the sim in here.
 			go func(sub singleBulkSim) {
 				// overwrite the requests iterations with the input for this function.
+				// Every combo runs exactly these iterations, without stopping early at the
+				// target error, so that their values can be paired up by seed in compareTo().
+				// The target error only decides when fast mode stops adding iterations.
 				sub.req.SimOptions.Iterations = int32(iterations)
+				sub.req.SimOptions.MaxIterations = 0
+				sub.req.SimOptions.TargetRelativeError = 0
+				// Combos are already simmed concurrently, so keep each one on a single goroutine.
+				sub.req.SimOptions.Concurrency = 1
+				simResult := b.SingleRaidSimRunner(ctx, sub.req, singleSimProgress, false)
+				if simResult != nil && simResult.ErrorResult == "" {
+					keepRaidDpsSamples(simResult)
+				}
 				results <- &itemSubstitutionSimResult{
 					Request:      sub.req,
-					Result:       b.SingleRaidSimRunner(sub.req, singleSimProgress, false),
+					Result:       simResult,
 					Substitution: sub.eq,
 					ChangeLog:    sub.cl,
 				}
@@ -326,7 +386,13 @@ func (b *bulkSimRunner) getRankedResults(pctx context.Context, validCombos []sin
 	var baseResult *itemSubstitutionSimResult
 
 	for i := range rankedResults {
-		result := <-results
+		var result *itemSubstitutionSimResult
+		select {
+		case result = <-results:
+		case <-pctx.Done():
+			cancel() // cancel reporter and launcher
+			return nil, nil, pctx.Err()
+		}
 		if result.Result == nil || result.Result.ErrorResult != "" {
 			cancel() // cancel reporter
 			return nil, nil, errors.New("simulation failed: " + result.Result.ErrorResult)
@@ -338,6 +404,11 @@ func (b *bulkSimRunner) getRankedResults(pctx context.Context, validCombos []sin
 	}
 	cancel() // cancel reporter
 
+	// Sims that were cancelled only ran part of their iterations, so don't rank them.
+	if err := pctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
 	sort.Slice(rankedResults, func(i, j int) bool {
 		return rankedResults[i].Score() > rankedResults[j].Score()
 	})
@@ -362,6 +433,108 @@ func (r *itemSubstitutionSimResult) Score() float64 {
 	return r.Result.RaidMetrics.Dps.Avg
 }
 
+// Compares the score of this result to another one, pairing up the iterations with the same seed.
+// Combos run their iterations in order on a single goroutine, so the values with the same index
+// share a seed. Results from different fast mode rounds ran a different number of iterations,
+// in which case only the shared ones are used.
+func (r *itemSubstitutionSimResult) compareTo(other *itemSubstitutionSimResult) *proto.BulkComboComparison {
+	values := r.Result.RaidMetrics.Dps.AllValues
+	otherValues := other.Result.RaidMetrics.Dps.AllValues
+	n := min(len(values), len(otherValues))
+
+	var diffs aggregator
+	for i := 0; i < n; i++ {
+		diffs.add(values[i] - otherValues[i])
+	}
+	if n < 2 {
+		// Not enough iterations to estimate an error.
+		delta := r.Score() - other.Score()
+		return &proto.BulkComboComparison{Delta: delta, CiLower: delta, CiUpper: delta, PValue: 1, Iterations: int32(n)}
+	}
+
+	delta, stdDev := diffs.meanAndStdDev()
+	if math.IsNaN(stdDev) {
+		// Rounding can make the variance of identical values slightly negative.
+		stdDev = 0
+	}
+	// meanAndStdDev() gives the population standard deviation, so correct it to the sample one.
+	stdErr := stdDev / math.Sqrt(float64(n-1))
+
+	// Combos run at least 50 iterations in fast mode, and usually far more, so a normal approximation is fine.
+	pValue := 1.0
+	if stdErr > 0 {
+		pValue = math.Erfc(math.Abs(delta) / stdErr / math.Sqrt2)
+	} else if delta != 0 {
+		pValue = 0
+	}
+
+	return &proto.BulkComboComparison{
+		Delta:      delta,
+		CiLower:    delta - bulkConfidenceZ*stdErr,
+		CiUpper:    delta + bulkConfidenceZ*stdErr,
+		PValue:     pValue,
+		Iterations: int32(n),
+	}
+}
+
+// Returns true if this result has a significantly lower score than the other one.
+func (r *itemSubstitutionSimResult) isSignificantlyWorseThan(other *itemSubstitutionSimResult) bool {
+	if r == other {
+		return false
+	}
+	comparison := r.compareTo(other)
+	return comparison.Delta < 0 && comparison.PValue < bulkSignificanceLevel
+}
+
+// Returns the metrics of the bulk sim player, without the details that aren't shown for combos.
+func (r *itemSubstitutionSimResult) comboUnitMetrics() *proto.UnitMetrics {
+	um := r.Result.GetRaidMetrics().GetParties()[0].GetPlayers()[0]
+	um.Actions = nil
+	um.Auras = nil
+	um.Resources = nil
+	um.Pets = nil
+	clearUnitSamples(um)
+	return um
+}
+
+// Drops the values of each iteration from every distribution except the raid DPS, which is the
+// only one combos are compared by.
+func keepRaidDpsSamples(result *proto.RaidSimResult) {
+	raidMetrics := result.GetRaidMetrics()
+	clearSamples(raidMetrics.GetHps())
+	for _, party := range raidMetrics.GetParties() {
+		clearSamples(party.Dps, party.Hps)
+		for _, player := range party.Players {
+			clearUnitSamples(player)
+		}
+	}
+
+	encounterMetrics := result.GetEncounterMetrics()
+	clearSamples(encounterMetrics.GetFightDuration())
+	for _, target := range encounterMetrics.GetTargets() {
+		clearUnitSamples(target)
+	}
+	for _, kills := range encounterMetrics.GetTargetKills() {
+		clearSamples(kills.KillTime)
+	}
+}
+
+func clearUnitSamples(um *proto.UnitMetrics) {
+	clearSamples(um.Dps, um.Dpasp, um.Threat, um.Dtps, um.Tmi, um.Hps, um.Tto, um.DeathTime)
+	clearSamples(um.ExecutePhaseDps...)
+	for _, pet := range um.Pets {
+		clearUnitSamples(pet)
+	}
+}
+
+func clearSamples(distMetrics ...*proto.DistributionMetrics) {
+	for _, dm := range distMetrics {
+		if dm != nil {
+			dm.AllValues = nil
+		}
+	}
+}
+
 // equipmentSubstitution specifies all items to be used as replacements for the equipped gear.
 type equipmentSubstitution struct {
 	Items []*itemWithSlot
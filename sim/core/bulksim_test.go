@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"math"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -91,7 +92,7 @@ func createEquipmentFromItems(items ...*itemWithSlot) *proto.EquipmentSpec {
 func TestBulkSim(t *testing.T) {
 	t.Skip("TODO: Implement")
 
-	fakeRunSim := func(rsr *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, skipPresim bool) *proto.RaidSimResult {
+	fakeRunSim := func(_ context.Context, rsr *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, skipPresim bool) *proto.RaidSimResult {
 		return &proto.RaidSimResult{}
 	}
 
@@ -113,6 +114,78 @@ func TestBulkSim(t *testing.T) {
 	}
 }
 
+func TestBulkSimRunsFixedIterationsOnACopy(t *testing.T) {
+	addToDatabase(tinyItemDatabase)
+
+	request := &proto.BulkSimRequest{
+		BaseSettings: &proto.RaidSimRequest{
+			Raid: SinglePlayerRaidProto(&proto.Player{
+				Name:      "Player",
+				Equipment: createEquipmentFromItems(starshardEdge1),
+			}, nil, nil, nil),
+			SimOptions: &proto.SimOptions{TargetRelativeError: 0.01},
+		},
+		BulkSettings: &proto.BulkSettings{
+			Items:              []*proto.ItemSpec{ironmender.Item},
+			IterationsPerCombo: 4,
+		},
+	}
+
+	fakeRunSim := func(_ context.Context, rsr *proto.RaidSimRequest, _ chan *proto.ProgressMetrics, _ bool) *proto.RaidSimResult {
+		options := rsr.SimOptions
+		if options.Iterations != 4 || options.MaxIterations != 0 || options.TargetRelativeError != 0 || !options.SaveAllValues {
+			t.Errorf("Combo simmed with options %v, expected exactly 4 iterations saving all values", options)
+		}
+		values := []float64{100, 120, 90, 110}
+		if rsr.Raid.Parties[0].Players[0].Equipment.Items[proto.ItemSlot_ItemSlotOffHand].Id != 0 {
+			values = []float64{105, 126, 95, 114}
+		}
+		return &proto.RaidSimResult{
+			RaidMetrics: &proto.RaidMetrics{
+				Dps: &proto.DistributionMetrics{Avg: values[0], AllValues: values},
+				Parties: []*proto.PartyMetrics{{
+					Dps:     &proto.DistributionMetrics{AllValues: values},
+					Players: []*proto.UnitMetrics{{Dps: &proto.DistributionMetrics{AllValues: values}}},
+				}},
+			},
+			Iterations:    4,
+			RelativeError: 0.1,
+		}
+	}
+
+	// Not closed, as the progress reporter may still be sending when Run() returns.
+	progress := make(chan *proto.ProgressMetrics)
+	go func() {
+		for range progress {
+		}
+	}()
+
+	bulk := &bulkSimRunner{
+		SingleRaidSimRunner: fakeRunSim,
+		Request:             request,
+	}
+	result, err := bulk.Run(context.Background(), progress)
+	if err != nil {
+		t.Fatalf("BulkSim() returned error: %v", err)
+	}
+
+	if options := request.BaseSettings.SimOptions; options.SaveAllValues || options.RandomSeed != 0 {
+		t.Errorf("BulkSim() modified the caller's sim options: %v", options)
+	}
+	if len(result.Results) != 2 {
+		t.Fatalf("Expected 2 combo results, got %d", len(result.Results))
+	}
+	if comparison := result.Results[0].VsEquipped; comparison.Iterations != 4 || comparison.Delta <= 0 {
+		t.Errorf("Expected the best combo to beat the equipped gear over 4 iterations, got %v", comparison)
+	}
+	if comparison := result.Results[1].VsEquipped; comparison != nil {
+		t.Errorf("Expected no comparison of the equipped gear to itself, got %v", comparison)
+	}
+	if values := result.Results[0].UnitMetrics.Dps.AllValues; len(values) != 0 {
+		t.Errorf("Expected combo metrics without iteration values, got %v", values)
+	}
+}
+
 func TestGenerateAllEquipmentSubstitutions(t *testing.T) {
 	baseItems := make([]*proto.ItemSpec, len(proto.ItemSlot_name))
 	for i := range baseItems {
@@ -380,3 +453,60 @@ func TestGenerateAllEquipmentSubstitutions(t *testing.T) {
 		})
 	}
 }
+
+func createResultWithValues(values ...float64) *itemSubstitutionSimResult {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return &itemSubstitutionSimResult{
+		Result: &proto.RaidSimResult{
+			RaidMetrics: &proto.RaidMetrics{
+				Dps: &proto.DistributionMetrics{Avg: sum / float64(len(values)), AllValues: values},
+			},
+		},
+	}
+}
+
+func TestItemSubstitutionSimResultCompareTo(t *testing.T) {
+	base := createResultWithValues(100, 120, 90, 110, 105, 95, 115, 85)
+	better := createResultWithValues(105, 126, 95, 114, 110, 101, 120, 89)
+	tied := createResultWithValues(101, 119, 91, 109, 106, 94, 116, 84)
+
+	comparison := better.compareTo(base)
+	if math.Abs(comparison.Delta-5) > 1e-9 {
+		t.Errorf("Expected delta of 5, got %f", comparison.Delta)
+	}
+	if comparison.CiLower <= 0 || comparison.CiUpper <= comparison.Delta || comparison.PValue >= bulkSignificanceLevel {
+		t.Errorf("Expected a significant improvement, got %v", comparison)
+	}
+	if comparison.Iterations != 8 {
+		t.Errorf("Expected 8 compared iterations, got %d", comparison.Iterations)
+	}
+
+	if !base.isSignificantlyWorseThan(better) {
+		t.Errorf("Expected base to be significantly worse than better")
+	}
+	if tied.isSignificantlyWorseThan(base) || base.isSignificantlyWorseThan(tied) {
+		t.Errorf("Expected base and tied to be indistinguishable, got %v", tied.compareTo(base))
+	}
+
+	// Only the iterations both results ran are compared.
+	shorter := createResultWithValues(105, 126, 95)
+	if comparison := shorter.compareTo(base); comparison.Iterations != 3 || math.Abs(comparison.Delta-5.333333) > 1e-6 {
+		t.Errorf("Expected 3 compared iterations with a delta of 5.33, got %v", comparison)
+	}
+}
+
+func TestNumCombosToKeep(t *testing.T) {
+	best := createResultWithValues(105, 126, 95, 114, 110, 101, 120, 89)
+	tied := createResultWithValues(106, 125, 96, 113, 111, 100, 121, 88)
+	worse := createResultWithValues(100, 120, 90, 110, 105, 95, 115, 85)
+
+	if got := numCombosToKeep([]*itemSubstitutionSimResult{best, worse, worse, worse}); got != 2 {
+		t.Errorf("Expected to keep the top half, got %d", got)
+	}
+	if got := numCombosToKeep([]*itemSubstitutionSimResult{best, worse, worse, tied}); got != 4 {
+		t.Errorf("Expected to keep combos tied with the best, got %d", got)
+	}
+}
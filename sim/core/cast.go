@@ -3,6 +3,8 @@ package core
 import (
 	"fmt"
 	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
 )
 
 // A cast corresponds to any action which causes the in-game castbar to be
@@ -234,6 +236,7 @@ func (spell *Spell) makeCastFunc(config CastConfig) CastSuccessFunc {
 						spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
 							spell.ActionID, max(0, spell.CurCast.Cost), spell.CurCast.CastTime, spell.CurCast.EffectiveTime())
 						spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+						spell.emitCastEvent(sim, proto.CombatEventType_CombatEventCastFinish, target, max(0, spell.CurCast.Cost), spell.CurCast.CastTime)
 					}
 
 					if spell.Cost != nil {
@@ -257,6 +260,7 @@ func (spell *Spell) makeCastFunc(config CastConfig) CastSuccessFunc {
 			if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
 				spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
 					spell.ActionID, max(0, spell.CurCast.Cost), spell.CurCast.CastTime, spell.CurCast.EffectiveTime())
+				spell.emitCastEvent(sim, proto.CombatEventType_CombatEventCastStart, target, max(0, spell.CurCast.Cost), spell.CurCast.CastTime)
 			}
 
 			spell.Unit.Hardcast = Hardcast{
@@ -268,6 +272,7 @@ func (spell *Spell) makeCastFunc(config CastConfig) CastSuccessFunc {
 
 					if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
 						spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+						spell.emitCastEvent(sim, proto.CombatEventType_CombatEventCastFinish, target, max(0, spell.CurCast.Cost), spell.CurCast.CastTime)
 					}
 
 					if spell.Cost != nil {
@@ -304,6 +309,8 @@ func (spell *Spell) makeCastFunc(config CastConfig) CastSuccessFunc {
 			spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
 				spell.ActionID, max(0, spell.CurCast.Cost), spell.CurCast.CastTime, spell.CurCast.EffectiveTime())
 			spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+			spell.emitCastEvent(sim, proto.CombatEventType_CombatEventCastStart, target, max(0, spell.CurCast.Cost), spell.CurCast.CastTime)
+			spell.emitCastEvent(sim, proto.CombatEventType_CombatEventCastFinish, target, max(0, spell.CurCast.Cost), spell.CurCast.CastTime)
 		}
 
 		if spell.Cost != nil {
@@ -350,6 +357,8 @@ func (spell *Spell) makeCastFuncSimple() CastSuccessFunc {
 			spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
 				spell.ActionID, 0.0, "0s", "0s")
 			spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+			spell.emitCastEvent(sim, proto.CombatEventType_CombatEventCastStart, target, 0, 0)
+			spell.emitCastEvent(sim, proto.CombatEventType_CombatEventCastFinish, target, 0, 0)
 		}
 
 		spell.applyEffects(sim, target)
@@ -368,6 +377,8 @@ func (spell *Spell) makeCastFuncAutosOrProcs() CastSuccessFunc {
 			spell.Unit.Log(sim, "Casting %s (Cost = %0.03f, Cast Time = %s, Effective Time = %s)",
 				spell.ActionID, 0.0, "0s", "0s")
 			spell.Unit.Log(sim, "Completed cast %s", spell.ActionID)
+			spell.emitCastEvent(sim, proto.CombatEventType_CombatEventCastStart, target, 0, 0)
+			spell.emitCastEvent(sim, proto.CombatEventType_CombatEventCastFinish, target, 0, 0)
 		}
 
 		spell.applyEffects(sim, target)
@@ -308,15 +308,21 @@ func (character *Character) applyAllEffects(agent Agent, raidBuffs *proto.RaidBu
 	character.AddStat(stats.Parry, 5*ParryRatingPerParryChance)
 	character.AddStat(stats.Block, 5*BlockRatingPerBlockChance)
 
-	applyRaceEffects(agent)
+	character.withSpellSource(proto.SpellSource_SpellSourceRacial, func() {
+		applyRaceEffects(agent)
+	})
 	character.applyBuildPhaseAuras(CharacterBuildPhaseBase)
 	playerStats.BaseStats = measureStats()
 
 	character.applyEquipment()
 	character.applyWeaponSkills()
 	character.ApplyRingRunes()
-	character.applyItemEffects(agent)
-	character.applyItemSetBonusEffects(agent)
+	character.withSpellSource(proto.SpellSource_SpellSourceItemEffect, func() {
+		character.applyItemEffects(agent)
+	})
+	character.withSpellSource(proto.SpellSource_SpellSourceSetBonus, func() {
+		character.applyItemSetBonusEffects(agent)
+	})
 	character.applyBuildPhaseAuras(CharacterBuildPhaseGear)
 	playerStats.GearStats = measureStats()
 
@@ -325,11 +331,15 @@ func (character *Character) applyAllEffects(agent Agent, raidBuffs *proto.RaidBu
 	character.applyBuildPhaseAuras(CharacterBuildPhaseTalents)
 	playerStats.TalentsStats = measureStats()
 
-	applyBuffEffects(agent, agent.GetCharacter().GetFaction(), raidBuffs, partyBuffs, individualBuffs)
+	character.withSpellSource(proto.SpellSource_SpellSourceBuff, func() {
+		applyBuffEffects(agent, agent.GetCharacter().GetFaction(), raidBuffs, partyBuffs, individualBuffs)
+	})
 	character.applyBuildPhaseAuras(CharacterBuildPhaseBuffs)
 	playerStats.BuffsStats = measureStats()
 
-	applyConsumeEffects(agent)
+	character.withSpellSource(proto.SpellSource_SpellSourceConsumable, func() {
+		applyConsumeEffects(agent)
+	})
 	character.applyBuildPhaseAuras(CharacterBuildPhaseConsumes)
 	playerStats.ConsumesStats = measureStats()
 	character.clearBuildPhaseAuras(CharacterBuildPhaseAll)
@@ -161,6 +161,7 @@ func (eb *energyBar) addEnergyInternal(sim *Simulation, amount float64, metrics
 
 	if sim.Log != nil {
 		eb.unit.Log(sim, "Gained %0.3f energy from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, eb.currentEnergy, newEnergy)
+		eb.unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeEnergy, metrics.ActionID, amount, newEnergy)
 	}
 
 	crossedThreshold := eb.cumulativeEnergyDecisionThresholds == nil || eb.cumulativeEnergyDecisionThresholds[int(eb.currentEnergy)] != eb.cumulativeEnergyDecisionThresholds[int(newEnergy)]
@@ -183,6 +184,7 @@ func (eb *energyBar) SpendEnergy(sim *Simulation, amount float64, metrics *Resou
 
 	if sim.Log != nil {
 		eb.unit.Log(sim, "Spent %0.3f energy from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, eb.currentEnergy, newEnergy)
+		eb.unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeEnergy, metrics.ActionID, -amount, newEnergy)
 	}
 
 	eb.currentEnergy = newEnergy
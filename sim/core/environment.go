@@ -255,8 +255,7 @@ func (env *Environment) IsFinalized() bool {
 }
 
 func (env *Environment) reset(sim *Simulation) {
-	// Reset primary targets damage taken for tracking health fights.
-	env.Encounter.DamageTaken = 0
+	env.Encounter.reset()
 
 	// Targets need to be reset before the raid, so that players can check for
 	// the presence of permanent target auras in their Reset handlers.
@@ -0,0 +1,224 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// CombatEventSink receives the events of the iterations it records. Debug log
+// messages are received as events of type CombatEventLog.
+type CombatEventSink interface {
+	// Whether events of the given iteration should be sent to this sink.
+	RecordsIteration(iteration int32) bool
+
+	HandleEvent(event *proto.CombatEvent)
+}
+
+// Adds a sink for the events of this sim. Must be called before the sim runs.
+func (sim *Simulation) AddEventSink(sink CombatEventSink) {
+	sim.eventSinks = append(sim.eventSinks, sink)
+}
+
+// Selects the sinks recording the given iteration, and enables logging if there are any.
+func (sim *Simulation) startRecording(iteration int32) {
+	sim.activeEventSinks = sim.activeEventSinks[:0]
+	for _, sink := range sim.eventSinks {
+		if sink.RecordsIteration(iteration) {
+			sim.activeEventSinks = append(sim.activeEventSinks, sink)
+		}
+	}
+
+	if len(sim.activeEventSinks) == 0 {
+		sim.Log = nil
+		return
+	}
+	sim.Log = func(message string, vals ...interface{}) {
+		sim.emitEvent(&proto.CombatEvent{
+			Type:    proto.CombatEventType_CombatEventLog,
+			Message: fmt.Sprintf(message, vals...),
+		})
+	}
+}
+
+// Sends an event to all sinks recording the current iteration. Only call this while sim.Log is set.
+func (sim *Simulation) emitEvent(event *proto.CombatEvent) {
+	event.Timestamp = sim.CurrentTime.Seconds()
+	for _, sink := range sim.activeEventSinks {
+		sink.HandleEvent(event)
+	}
+}
+
+func newCombatEvent(eventType proto.CombatEventType, source *Unit, target *Unit, actionID ActionID) *proto.CombatEvent {
+	event := &proto.CombatEvent{
+		Type:     eventType,
+		Source:   source.Label,
+		ActionId: actionID.ToProto(),
+	}
+	if target != nil {
+		event.Target = target.Label
+	}
+	return event
+}
+
+func (spell *Spell) emitCastEvent(sim *Simulation, eventType proto.CombatEventType, target *Unit, cost float64, castTime time.Duration) {
+	event := newCombatEvent(eventType, spell.Unit, target, spell.ActionID)
+	event.Amount = cost
+	event.Duration = castTime.Seconds()
+	sim.emitEvent(event)
+}
+
+func (spell *Spell) emitResultEvent(sim *Simulation, eventType proto.CombatEventType, isPeriodic bool, result *SpellResult) {
+	event := newCombatEvent(eventType, spell.Unit, result.Target, spell.ActionID)
+	event.Amount = result.Damage
+	event.Threat = result.Threat
+	event.Periodic = isPeriodic
+	event.Miss = result.Outcome.Matches(OutcomeMiss)
+	event.Dodge = result.Outcome.Matches(OutcomeDodge)
+	event.Parry = result.Outcome.Matches(OutcomeParry)
+	event.Glance = result.Outcome.Matches(OutcomeGlance)
+	event.Block = result.Outcome.Matches(OutcomeBlock)
+	event.Crit = result.Outcome.Matches(OutcomeCrit)
+	event.Crush = result.Outcome.Matches(OutcomeCrush)
+	switch {
+	case result.Outcome.Matches(OutcomePartial1_4):
+		event.ResistedFraction = 0.25
+	case result.Outcome.Matches(OutcomePartial2_4):
+		event.ResistedFraction = 0.5
+	case result.Outcome.Matches(OutcomePartial3_4):
+		event.ResistedFraction = 0.75
+	}
+	sim.emitEvent(event)
+}
+
+func (aura *Aura) emitAuraEvent(sim *Simulation, eventType proto.CombatEventType) {
+	event := newCombatEvent(eventType, aura.Unit, nil, aura.ActionID)
+	event.Stacks = aura.stacks
+	event.Duration = aura.Duration.Seconds()
+	sim.emitEvent(event)
+}
+
+func (unit *Unit) emitResourceEvent(sim *Simulation, resourceType proto.ResourceType, actionID ActionID, amount float64, newLevel float64) {
+	event := newCombatEvent(proto.CombatEventType_CombatEventResourceChange, unit, nil, actionID)
+	event.ResourceType = resourceType
+	event.Amount = amount
+	event.ResourceLevel = newLevel
+	sim.emitEvent(event)
+}
+
+// Emits an event without an action, e.g. a pet being summoned or a unit moving.
+func (unit *Unit) emitUnitEvent(sim *Simulation, eventType proto.CombatEventType, target *Unit, amount float64) {
+	event := newCombatEvent(eventType, unit, target, ActionID{})
+	event.Amount = amount
+	sim.emitEvent(event)
+}
+
+// Collects events as protos, for RaidSimResult.combat_events.
+type protoEventSink struct {
+	iteration   int32
+	includeLogs bool
+	events      []*proto.CombatEvent
+}
+
+func (sink *protoEventSink) RecordsIteration(iteration int32) bool {
+	return iteration == sink.iteration
+}
+
+func (sink *protoEventSink) HandleEvent(event *proto.CombatEvent) {
+	if event.Type != proto.CombatEventType_CombatEventLog || sink.includeLogs {
+		sink.events = append(sink.events, event)
+	}
+}
+
+// Writes events as newline-delimited protojson, for RaidSimResult.combat_events_ndjson.
+type ndjsonEventSink struct {
+	iteration   int32
+	includeLogs bool
+	buffer      strings.Builder
+}
+
+func (sink *ndjsonEventSink) RecordsIteration(iteration int32) bool {
+	return iteration == sink.iteration
+}
+
+func (sink *ndjsonEventSink) HandleEvent(event *proto.CombatEvent) {
+	if event.Type == proto.CombatEventType_CombatEventLog && !sink.includeLogs {
+		return
+	}
+	line, err := protojson.Marshal(event)
+	if err != nil {
+		panic(err)
+	}
+	sink.buffer.Write(line)
+	sink.buffer.WriteByte('\n')
+}
+
+// Writes log messages as text, for RaidSimResult.logs.
+type logEventSink struct {
+	allIterations bool // Otherwise only the first iteration is logged.
+	buffer        strings.Builder
+}
+
+func (sink *logEventSink) RecordsIteration(iteration int32) bool {
+	return sink.allIterations || iteration == 0
+}
+
+func (sink *logEventSink) HandleEvent(event *proto.CombatEvent) {
+	if event.Type == proto.CombatEventType_CombatEventLog {
+		fmt.Fprintf(&sink.buffer, "[%0.2f] %s\n", event.Timestamp, event.Message)
+	}
+}
+
+// Prints log messages to the console, for debugging.
+type consoleEventSink struct{}
+
+func (sink *consoleEventSink) RecordsIteration(iteration int32) bool {
+	return true
+}
+
+func (sink *consoleEventSink) HandleEvent(event *proto.CombatEvent) {
+	if event.Type == proto.CombatEventType_CombatEventLog {
+		fmt.Printf("[%0.1f] %s\n", event.Timestamp, event.Message)
+	}
+}
+
+// Adds the sinks requested by the sim options, returning a function which writes
+// their output to a result and removes them again.
+func (sim *Simulation) addOptionEventSinks() func(result *proto.RaidSimResult) {
+	numSinks := len(sim.eventSinks)
+
+	var logSink *logEventSink
+	if sim.Options.Debug || sim.Options.DebugFirstIteration {
+		logSink = &logEventSink{allIterations: sim.Options.Debug}
+		sim.AddEventSink(logSink)
+	}
+
+	var protoSink *protoEventSink
+	var ndjsonSink *ndjsonEventSink
+	if eventOptions := sim.Options.CombatEvents; eventOptions != nil {
+		switch eventOptions.Format {
+		case proto.CombatEventFormat_CombatEventFormatNdjson:
+			ndjsonSink = &ndjsonEventSink{iteration: eventOptions.Iteration, includeLogs: eventOptions.IncludeLogs}
+			sim.AddEventSink(ndjsonSink)
+		default:
+			protoSink = &protoEventSink{iteration: eventOptions.Iteration, includeLogs: eventOptions.IncludeLogs}
+			sim.AddEventSink(protoSink)
+		}
+	}
+
+	return func(result *proto.RaidSimResult) {
+		sim.eventSinks = sim.eventSinks[:numSinks]
+		if logSink != nil {
+			result.Logs = logSink.buffer.String()
+		}
+		if protoSink != nil {
+			result.CombatEvents = protoSink.events
+		}
+		if ndjsonSink != nil {
+			result.CombatEventsNdjson = ndjsonSink.buffer.String()
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+func TestEventSinksRecordChosenIteration(t *testing.T) {
+	sim := &Simulation{
+		Options: &proto.SimOptions{
+			DebugFirstIteration: true,
+			CombatEvents: &proto.CombatEventOptions{
+				Iteration: 2,
+				Format:    proto.CombatEventFormat_CombatEventFormatProto,
+			},
+		},
+	}
+	writeEventSinks := sim.addOptionEventSinks()
+	unit := &Unit{Label: "Player"}
+
+	for iteration := int32(0); iteration < 4; iteration++ {
+		sim.startRecording(iteration)
+		sim.CurrentTime = time.Duration(iteration) * time.Second
+		if sim.Log != nil {
+			unit.Log(sim, "Iteration %d", iteration)
+			unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeMana, ActionID{SpellID: 1}, 10, 100)
+		}
+	}
+
+	result := &proto.RaidSimResult{}
+	writeEventSinks(result)
+
+	if expected := "[0.00] [Player] Iteration 0\n"; result.Logs != expected {
+		t.Fatalf("Logs %q, expected %q", result.Logs, expected)
+	}
+	if len(result.CombatEvents) != 1 {
+		t.Fatalf("Recorded %d events, expected only the resource event of iteration 2", len(result.CombatEvents))
+	}
+	event := result.CombatEvents[0]
+	if event.Type != proto.CombatEventType_CombatEventResourceChange || event.Timestamp != 2 || event.Source != "Player" || event.ResourceLevel != 100 {
+		t.Fatalf("Unexpected event %v", event)
+	}
+	if len(sim.eventSinks) != 0 {
+		t.Fatalf("Option sinks should be removed after the run, got %d", len(sim.eventSinks))
+	}
+}
+
+func TestNdjsonEventSink(t *testing.T) {
+	sink := &ndjsonEventSink{includeLogs: true}
+	sink.HandleEvent(&proto.CombatEvent{Type: proto.CombatEventType_CombatEventLog, Message: "a"})
+	sink.HandleEvent(&proto.CombatEvent{Type: proto.CombatEventType_CombatEventDamage, Amount: 5})
+
+	lines := 0
+	for _, c := range sink.buffer.String() {
+		if c == '\n' {
+			lines++
+		}
+	}
+	if lines != 2 {
+		t.Fatalf("Expected 2 lines, got %q", sink.buffer.String())
+	}
+}
+
+func TestReplayRequest(t *testing.T) {
+	rsr := &proto.RaidSimRequest{
+		SimOptions: &proto.SimOptions{
+			Iterations:          1000,
+			RandomSeed:          101,
+			TargetRelativeError: 0.001,
+			MaxIterations:       5000,
+			ReplaySeed:          1234,
+			CombatEvents:        &proto.CombatEventOptions{Iteration: 233},
+		},
+	}
+
+	replay := replayRequest(rsr)
+	options := replay.SimOptions
+	if options.RandomSeed != 1234 || options.Iterations != 1 || options.MaxIterations != 0 || options.TargetRelativeError != 0 {
+		t.Fatalf("Replay should run a single iteration seeded with the replay seed, got %v", options)
+	}
+	if !options.DebugFirstIteration || options.CombatEvents.Iteration != 0 {
+		t.Fatalf("Replay should record the logs and events of its only iteration, got %v", options)
+	}
+	if rsr.SimOptions.RandomSeed != 101 || rsr.SimOptions.CombatEvents.Iteration != 233 {
+		t.Fatalf("Replay should not modify the original request, got %v", rsr.SimOptions)
+	}
+}
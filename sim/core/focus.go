@@ -68,6 +68,7 @@ func (fb *focusBar) AddFocus(sim *Simulation, amount float64, metrics *ResourceM
 
 	if sim.Log != nil {
 		fb.unit.Log(sim, "Gained %0.3f focus from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, fb.currentFocus, newFocus)
+		fb.unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeFocus, metrics.ActionID, amount, newFocus)
 	}
 
 	fb.currentFocus = newFocus
@@ -87,6 +88,7 @@ func (fb *focusBar) SpendFocus(sim *Simulation, amount float64, metrics *Resourc
 
 	if sim.Log != nil {
 		fb.unit.Log(sim, "Spent %0.3f focus from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, fb.currentFocus, newFocus)
+		fb.unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeFocus, metrics.ActionID, -amount, newFocus)
 	}
 
 	fb.currentFocus = newFocus
@@ -56,6 +56,7 @@ func (hb *healthBar) GainHealth(sim *Simulation, amount float64, metrics *Resour
 
 	if sim.Log != nil {
 		hb.unit.Log(sim, "Gained %0.3f health from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, oldHealth, newHealth)
+		hb.unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeHealth, metrics.ActionID, amount, newHealth)
 	}
 
 	hb.currentHealth = newHealth
@@ -82,6 +83,7 @@ func (hb *healthBar) RemoveHealth(sim *Simulation, amount float64) {
 
 	if sim.Log != nil {
 		hb.unit.Log(sim, "Spent %0.3f health from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, oldHealth, newHealth)
+		hb.unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeHealth, metrics.ActionID, -amount, newHealth)
 	}
 
 	hb.currentHealth = newHealth
@@ -118,6 +120,7 @@ func (character *Character) trackChanceOfDeath(healingModel *proto.HealingModel)
 
 				if aura.Unit.CurrentHealth() <= 0 && !aura.Unit.Metrics.Died {
 					aura.Unit.Metrics.Died = true
+					aura.Unit.Metrics.DeathTime = sim.CurrentTime
 					if sim.Log != nil {
 						character.Log(sim, "Dead")
 					}
@@ -130,6 +133,7 @@ func (character *Character) trackChanceOfDeath(healingModel *proto.HealingModel)
 
 				if aura.Unit.CurrentHealth() <= 0 && !aura.Unit.Metrics.Died {
 					aura.Unit.Metrics.Died = true
+					aura.Unit.Metrics.DeathTime = sim.CurrentTime
 					if sim.Log != nil {
 						character.Log(sim, "Dead")
 					}
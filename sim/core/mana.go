@@ -86,6 +86,7 @@ func (unit *Unit) AddMana(sim *Simulation, amount float64, metrics *ResourceMetr
 
 	if sim.Log != nil {
 		unit.Log(sim, "Gained %0.3f mana from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, oldMana, newMana)
+		unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeMana, metrics.ActionID, amount, newMana)
 	}
 
 	unit.currentMana = newMana
@@ -102,6 +103,7 @@ func (unit *Unit) SpendMana(sim *Simulation, amount float64, metrics *ResourceMe
 
 	if sim.Log != nil {
 		unit.Log(sim, "Spent %0.3f mana from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, unit.CurrentMana(), newMana)
+		unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeMana, metrics.ActionID, -amount, newMana)
 	}
 
 	unit.currentMana = newMana
@@ -18,65 +18,171 @@ type DistributionMetrics struct {
 
 	// Aggregate values. These are updated after each iteration.
 	aggregator
-	max     float64
-	min     float64
-	maxSeed int64
-	minSeed int64
-	hist    map[int32]int32 // rounded DPS to count
-	sample  []float64
+	max       float64
+	min       float64
+	maxSeed   int64
+	minSeed   int64
+	hist      map[int32]int32 // value rounded to a multiple of histBinWidth, to count
+	quantiles quantileSketch
+	sample    []float64
+
+	// Width of the bins in hist, set from the sim options by the first sample. With
+	// autoBinWidth, hist has bins of width 1, which ToProto() merges into bins of a
+	// width picked from the distribution.
+	histBinWidth float64
+	autoBinWidth bool
 }
 
+// Width of the histogram bins when the sim options don't set one.
+const defaultHistBinWidth = 10
+
 func (distMetrics *DistributionMetrics) reset() {
 	distMetrics.Total = 0
 }
 
 // This should be called when a Sim iteration is complete.
 func (distMetrics *DistributionMetrics) doneIteration(sim *Simulation) {
-	dps := distMetrics.Total / sim.Duration.Seconds()
-	distMetrics.add(dps)
+	distMetrics.doneIterationOver(sim, sim.Duration)
+}
+
+// Like doneIteration, for a Total accumulated over only part of the iteration.
+func (distMetrics *DistributionMetrics) doneIterationOver(sim *Simulation, duration time.Duration) {
+	distMetrics.addSample(sim, distMetrics.Total/duration.Seconds())
+}
+
+// Adds the value of the current iteration directly, for metrics which aren't a per-second rate.
+func (distMetrics *DistributionMetrics) addSample(sim *Simulation, value float64) {
+	distMetrics.add(value)
+	distMetrics.quantiles.add(value)
+	if distMetrics.histBinWidth == 0 {
+		distMetrics.autoBinWidth = sim.Options.AutoHistogramBinWidth
+		switch {
+		case distMetrics.autoBinWidth:
+			distMetrics.histBinWidth = 1
+		case sim.Options.HistogramBinWidth > 0:
+			distMetrics.histBinWidth = max(1, sim.Options.HistogramBinWidth)
+		default:
+			distMetrics.histBinWidth = defaultHistBinWidth
+		}
+	}
 
 	if sim.Options.SaveAllValues {
 		if cap(distMetrics.sample) < int(sim.Options.Iterations) {
 			distMetrics.sample = make([]float64, 0, sim.Options.Iterations)
 		}
-		distMetrics.sample = append(distMetrics.sample, dps)
+		distMetrics.sample = append(distMetrics.sample, value)
 	}
 
-	if dps > distMetrics.max {
-		distMetrics.max = dps
+	if value > distMetrics.max {
+		distMetrics.max = value
 		distMetrics.maxSeed = sim.rand.GetSeed()
 	}
-	if dps <= distMetrics.min || distMetrics.min < 0 {
-		distMetrics.min = dps
+	if value <= distMetrics.min || distMetrics.min < 0 {
+		distMetrics.min = value
 		distMetrics.minSeed = sim.rand.GetSeed()
 	}
 
-	dpsRounded := int32(math.Round(dps/10) * 10)
-	distMetrics.hist[dpsRounded]++
+	distMetrics.hist[int32(math.Round(value/distMetrics.histBinWidth)*distMetrics.histBinWidth)]++
+}
+
+// Adds the aggregate values from another DistributionMetrics, whose iterations
+// come after the ones already recorded here.
+func (distMetrics *DistributionMetrics) merge(other *DistributionMetrics) {
+	if other.n == 0 {
+		return
+	}
+
+	distMetrics.aggregator = *distMetrics.aggregator.merge(&other.aggregator)
+	distMetrics.quantiles.merge(&other.quantiles)
+	distMetrics.sample = append(distMetrics.sample, other.sample...)
+	if distMetrics.histBinWidth == 0 {
+		distMetrics.histBinWidth = other.histBinWidth
+		distMetrics.autoBinWidth = other.autoBinWidth
+	}
+
+	if other.max > distMetrics.max {
+		distMetrics.max = other.max
+		distMetrics.maxSeed = other.maxSeed
+	}
+	if other.min <= distMetrics.min || distMetrics.min < 0 {
+		distMetrics.min = other.min
+		distMetrics.minSeed = other.minSeed
+	}
+
+	for value, count := range other.hist {
+		distMetrics.hist[value] += count
+	}
+}
+
+// Returns the width of the histogram bins. With autoBinWidth, this uses the
+// Freedman-Diaconis rule, rounded up to 1, 2 or 5 times a power of 10.
+func (distMetrics *DistributionMetrics) binWidth() float64 {
+	if distMetrics.histBinWidth == 0 {
+		return defaultHistBinWidth
+	}
+	if !distMetrics.autoBinWidth || distMetrics.n == 0 {
+		return distMetrics.histBinWidth
+	}
+
+	iqr := distMetrics.quantiles.quantile(0.75) - distMetrics.quantiles.quantile(0.25)
+	width := 2 * iqr / math.Cbrt(float64(distMetrics.n))
+	if width <= 1 {
+		return 1
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(width)))
+	for _, factor := range []float64{1, 2, 5} {
+		if width <= factor*magnitude {
+			return factor * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// Returns the histogram with bins of the given width, keyed by the center of each bin.
+func (distMetrics *DistributionMetrics) binnedHist(width float64) map[int32]int32 {
+	if width == distMetrics.histBinWidth {
+		return distMetrics.hist
+	}
+	hist := make(map[int32]int32, len(distMetrics.hist))
+	for value, count := range distMetrics.hist {
+		hist[int32(math.Round(float64(value)/width)*width)] += count
+	}
+	return hist
 }
 
 func (distMetrics *DistributionMetrics) ToProto() *proto.DistributionMetrics {
 	mean, stdev := distMetrics.meanAndStdDev()
+	binWidth := distMetrics.binWidth()
 
 	return &proto.DistributionMetrics{
-		Avg:       mean,
-		Stdev:     stdev,
-		Max:       distMetrics.max,
-		Min:       distMetrics.min,
-		MaxSeed:   distMetrics.maxSeed,
-		MinSeed:   distMetrics.minSeed,
-		Hist:      distMetrics.hist,
-		AllValues: distMetrics.sample,
+		Avg:          mean,
+		Stdev:        stdev,
+		Max:          distMetrics.max,
+		Min:          distMetrics.min,
+		MaxSeed:      distMetrics.maxSeed,
+		MinSeed:      distMetrics.minSeed,
+		Hist:         distMetrics.binnedHist(binWidth),
+		HistBinWidth: binWidth,
+		AllValues:    distMetrics.sample,
+		P5:           distMetrics.quantiles.quantile(0.05),
+		P25:          distMetrics.quantiles.quantile(0.25),
+		Median:       distMetrics.quantiles.quantile(0.5),
+		P75:          distMetrics.quantiles.quantile(0.75),
+		P95:          distMetrics.quantiles.quantile(0.95),
 	}
 }
 
 func NewDistributionMetrics() DistributionMetrics {
 	return DistributionMetrics{
-		hist: make(map[int32]int32),
-		min:  -1,
+		hist:      make(map[int32]int32),
+		quantiles: newQuantileSketch(),
+		min:       -1,
 	}
 }
 
+const numSpellSources = int(proto.SpellSource_SpellSourcePet) + 1
+
 type UnitMetrics struct {
 	dps    DistributionMetrics
 	dpasp  DistributionMetrics
@@ -90,6 +196,22 @@ type UnitMetrics struct {
 	isTanking bool
 	tmiBin    int32
 
+	timeline     *unitTimeline // Only set if SimOptions.TimelineBinSeconds is set.
+	auraOverlaps *auraOverlaps // Only set if SimOptions.OverlapAuras is set.
+	apl          *aplMetrics   // Only set for players with a rotation.
+
+	executePhaseDps [NumExecutePhases]DistributionMetrics
+
+	// Time of death in seconds, only for iterations in which the unit died.
+	deathTime DistributionMetrics
+
+	// Damage to opponents by spell school and by source, in the current iteration
+	// and summed over all iterations. These include the damage of pets.
+	iterationSchoolDamage map[SpellSchool]float64
+	iterationSourceDamage [numSpellSources]float64
+	schoolDamage          map[SpellSchool]float64
+	sourceDamage          [numSpellSources]float64
+
 	CharacterIterationMetrics
 
 	// Aggregate values. These are updated after each iteration.
@@ -111,6 +233,8 @@ type CharacterIterationMetrics struct {
 	OOMTime time.Duration // time spent not casting and waiting for regen.
 
 	FirstOOMTimestamp time.Duration // Timestamp at which unit first went OOM.
+
+	DeathTime time.Duration // Timestamp at which this unit died, if it did.
 }
 
 type ActionMetrics struct {
@@ -178,6 +302,9 @@ type SpellMetrics struct {
 	TotalCritHealing            float64 // Healing done by all critical casts of this spell.
 	TotalShielding              float64 // Shielding done by all casts of this spell.
 	TotalCastTime               time.Duration
+
+	ExecutePhaseCasts  [NumExecutePhases]int32   // Casts within each execute phase.
+	ExecutePhaseDamage [NumExecutePhases]float64 // Damage done within each execute phase.
 }
 
 type TargetedActionMetrics struct {
@@ -213,6 +340,47 @@ type TargetedActionMetrics struct {
 	CritHealing            float64
 	Shielding              float64
 	CastTime               time.Duration
+
+	ExecutePhaseCasts  [NumExecutePhases]int32
+	ExecutePhaseDamage [NumExecutePhases]float64
+}
+
+func (tam *TargetedActionMetrics) merge(other *TargetedActionMetrics) {
+	tam.Casts += other.Casts
+	tam.Misses += other.Misses
+	tam.Hits += other.Hits
+	tam.ResistedHits += other.ResistedHits
+	tam.Crits += other.Crits
+	tam.ResistedCrits += other.ResistedCrits
+	tam.Ticks += other.Ticks
+	tam.ResistedTicks += other.ResistedTicks
+	tam.CritTicks += other.CritTicks
+	tam.ResistedCritTicks += other.ResistedCritTicks
+	tam.Dodges += other.Dodges
+	tam.Glances += other.Glances
+	tam.Parries += other.Parries
+	tam.Blocks += other.Blocks
+	tam.BlockedCrits += other.BlockedCrits
+	tam.Damage += other.Damage
+	tam.ResistedDamage += other.ResistedDamage
+	tam.CritDamage += other.CritDamage
+	tam.ResistedCritDamage += other.ResistedCritDamage
+	tam.TickDamage += other.TickDamage
+	tam.ResistedTickDamage += other.ResistedTickDamage
+	tam.CritTickDamage += other.CritTickDamage
+	tam.ResistedCritTickDamage += other.ResistedCritTickDamage
+	tam.GlanceDamage += other.GlanceDamage
+	tam.BlockDamage += other.BlockDamage
+	tam.BlockedCritDamage += other.BlockedCritDamage
+	tam.Threat += other.Threat
+	tam.Healing += other.Healing
+	tam.CritHealing += other.CritHealing
+	tam.Shielding += other.Shielding
+	tam.CastTime += other.CastTime
+	for i := range tam.ExecutePhaseCasts {
+		tam.ExecutePhaseCasts[i] += other.ExecutePhaseCasts[i]
+		tam.ExecutePhaseDamage[i] += other.ExecutePhaseDamage[i]
+	}
 }
 
 func (tam *TargetedActionMetrics) ToProto(unitIndex int32) *proto.TargetedActionMetrics {
@@ -250,11 +418,13 @@ func (tam *TargetedActionMetrics) ToProto(unitIndex int32) *proto.TargetedAction
 		CritHealing:            tam.CritHealing,
 		Shielding:              tam.Shielding,
 		CastTimeMs:             float64(tam.CastTime.Milliseconds()),
+		ExecutePhaseCasts:      tam.ExecutePhaseCasts[:],
+		ExecutePhaseDamage:     tam.ExecutePhaseDamage[:],
 	}
 }
 
 func NewUnitMetrics() UnitMetrics {
-	return UnitMetrics{
+	unitMetrics := UnitMetrics{
 		dps:     NewDistributionMetrics(),
 		dpasp:   NewDistributionMetrics(),
 		threat:  NewDistributionMetrics(),
@@ -263,7 +433,16 @@ func NewUnitMetrics() UnitMetrics {
 		hps:     NewDistributionMetrics(),
 		tto:     NewDistributionMetrics(),
 		actions: make(map[ActionID]*ActionMetrics),
+
+		deathTime: NewDistributionMetrics(),
+
+		iterationSchoolDamage: make(map[SpellSchool]float64),
+		schoolDamage:          make(map[SpellSchool]float64),
 	}
+	for phase := range unitMetrics.executePhaseDps {
+		unitMetrics.executePhaseDps[phase] = NewDistributionMetrics()
+	}
+	return unitMetrics
 }
 
 type ResourceMetrics struct {
@@ -289,6 +468,12 @@ func (resourceMetrics *ResourceMetrics) ToProto() *proto.ResourceMetrics {
 	}
 }
 
+func (resourceMetrics *ResourceMetrics) merge(other *ResourceMetrics) {
+	resourceMetrics.Events += other.Events
+	resourceMetrics.Gain += other.Gain
+	resourceMetrics.ActualGain += other.ActualGain
+}
+
 func (resourceMetrics *ResourceMetrics) reset() {
 	resourceMetrics.EventsFromPreviousIterations = resourceMetrics.Events
 	resourceMetrics.ActualGainFromPreviousIterations = resourceMetrics.ActualGain
@@ -410,6 +595,12 @@ func (unitMetrics *UnitMetrics) addSpellMetrics(spell *Spell, actionID ActionID,
 		if !spell.Flags.Matches(SpellFlagPassiveSpell) {
 			tam.CastTime += spellTargetMetrics.TotalCastTime
 		}
+		for phase := range tam.ExecutePhaseDamage {
+			if !spell.Flags.Matches(SpellFlagPassiveSpell) {
+				tam.ExecutePhaseCasts[phase] += spellTargetMetrics.ExecutePhaseCasts[phase]
+			}
+			tam.ExecutePhaseDamage[phase] += spellTargetMetrics.ExecutePhaseDamage[phase]
+		}
 
 		target := spell.Unit.Env.AllUnits[i]
 		target.Metrics.dtps.Total += spellTargetMetrics.TotalDamage
@@ -417,6 +608,13 @@ func (unitMetrics *UnitMetrics) addSpellMetrics(spell *Spell, actionID ActionID,
 		if spell.Unit.IsOpponent(target) {
 			unitMetrics.dps.Total += spellTargetMetrics.TotalDamage
 			unitMetrics.threat.Total += spellTargetMetrics.TotalThreat
+			for phase, damage := range spellTargetMetrics.ExecutePhaseDamage {
+				unitMetrics.executePhaseDps[phase].Total += damage
+			}
+			if spellTargetMetrics.TotalDamage != 0 {
+				unitMetrics.iterationSchoolDamage[spell.SpellSchool] += spellTargetMetrics.TotalDamage
+				unitMetrics.iterationSourceDamage[spell.Source] += spellTargetMetrics.TotalDamage
+			}
 		} else {
 			unitMetrics.hps.Total += spellTargetMetrics.TotalHealing + spellTargetMetrics.TotalShielding
 		}
@@ -428,6 +626,15 @@ func (unitMetrics *UnitMetrics) addSpellMetrics(spell *Spell, actionID ActionID,
 // Assumes that doneIteration() has already been called on the pet metrics.
 func (unitMetrics *UnitMetrics) AddFinalPetMetrics(petMetrics *UnitMetrics) {
 	unitMetrics.dps.Total += petMetrics.dps.Total
+	for phase := range unitMetrics.executePhaseDps {
+		unitMetrics.executePhaseDps[phase].Total += petMetrics.executePhaseDps[phase].Total
+	}
+	for school, damage := range petMetrics.iterationSchoolDamage {
+		unitMetrics.iterationSchoolDamage[school] += damage
+	}
+	for _, damage := range petMetrics.iterationSourceDamage {
+		unitMetrics.iterationSourceDamage[proto.SpellSource_SpellSourcePet] += damage
+	}
 }
 
 func (unitMetrics *UnitMetrics) AddOOMTime(sim *Simulation, dur time.Duration) {
@@ -457,7 +664,17 @@ func (unitMetrics *UnitMetrics) reset() {
 	unitMetrics.tmiList = nil
 	unitMetrics.hps.reset()
 	unitMetrics.tto.reset()
+	for phase := range unitMetrics.executePhaseDps {
+		unitMetrics.executePhaseDps[phase].reset()
+	}
+	for school := range unitMetrics.iterationSchoolDamage {
+		unitMetrics.iterationSchoolDamage[school] = 0
+	}
+	unitMetrics.iterationSourceDamage = [numSpellSources]float64{}
 	unitMetrics.CharacterIterationMetrics = CharacterIterationMetrics{}
+	if unitMetrics.apl != nil {
+		unitMetrics.apl.reset()
+	}
 
 	for _, resourceMetrics := range unitMetrics.resources {
 		resourceMetrics.reset()
@@ -465,7 +682,7 @@ func (unitMetrics *UnitMetrics) reset() {
 }
 
 // This should be called when a Sim iteration is complete.
-func (unitMetrics *UnitMetrics) doneIteration(unit *Unit, sim *Simulation) {
+func (unitMetrics *UnitMetrics) doneIteration(unit *Unit, sim *Simulation, fightDuration time.Duration) {
 	if unit.HasManaBar() {
 		encounterDurationSeconds := sim.Duration.Seconds()
 		timeToOOM := unitMetrics.FirstOOMTimestamp
@@ -502,9 +719,94 @@ func (unitMetrics *UnitMetrics) doneIteration(unit *Unit, sim *Simulation) {
 	unitMetrics.hps.doneIteration(sim)
 	unitMetrics.tto.doneIteration(sim)
 
+	for phase := range unitMetrics.executePhaseDps {
+		// Phases which weren't reached don't count towards their distribution.
+		if duration := sim.executePhaseDuration(proto.ExecutePhase(phase)); duration > 0 {
+			unitMetrics.executePhaseDps[phase].doneIterationOver(sim, duration)
+		}
+	}
+
+	if unitMetrics.timeline != nil {
+		unitMetrics.timeline.doneIteration(fightDuration)
+	}
+	if unitMetrics.auraOverlaps != nil {
+		unitMetrics.auraOverlaps.doneIteration()
+	}
+	if unitMetrics.apl != nil {
+		unitMetrics.apl.doneIteration()
+	}
+
+	for school, damage := range unitMetrics.iterationSchoolDamage {
+		unitMetrics.schoolDamage[school] += damage
+	}
+	for source, damage := range unitMetrics.iterationSourceDamage {
+		unitMetrics.sourceDamage[source] += damage
+	}
+
 	unitMetrics.oomTimeSum += unitMetrics.OOMTime.Seconds()
 	if unitMetrics.Died {
 		unitMetrics.numItersDead++
+		unitMetrics.deathTime.addSample(sim, unitMetrics.DeathTime.Seconds())
+	}
+}
+
+// Adds the aggregate values from the metrics of the same unit in another Environment.
+func (unitMetrics *UnitMetrics) merge(other *UnitMetrics) {
+	unitMetrics.dps.merge(&other.dps)
+	unitMetrics.dpasp.merge(&other.dpasp)
+	unitMetrics.threat.merge(&other.threat)
+	unitMetrics.dtps.merge(&other.dtps)
+	unitMetrics.tmi.merge(&other.tmi)
+	unitMetrics.hps.merge(&other.hps)
+	unitMetrics.tto.merge(&other.tto)
+	for phase := range unitMetrics.executePhaseDps {
+		unitMetrics.executePhaseDps[phase].merge(&other.executePhaseDps[phase])
+	}
+	if unitMetrics.timeline != nil && other.timeline != nil {
+		unitMetrics.timeline.merge(other.timeline)
+	}
+	if unitMetrics.auraOverlaps != nil && other.auraOverlaps != nil {
+		unitMetrics.auraOverlaps.merge(other.auraOverlaps)
+	}
+	if unitMetrics.apl != nil && other.apl != nil {
+		unitMetrics.apl.merge(other.apl)
+	}
+
+	unitMetrics.numItersDead += other.numItersDead
+	unitMetrics.deathTime.merge(&other.deathTime)
+	for school, damage := range other.schoolDamage {
+		unitMetrics.schoolDamage[school] += damage
+	}
+	for source, damage := range other.sourceDamage {
+		unitMetrics.sourceDamage[source] += damage
+	}
+	unitMetrics.oomTimeSum += other.oomTimeSum
+
+	for actionID, otherAction := range other.actions {
+		actionMetrics, ok := unitMetrics.actions[actionID]
+		if !ok {
+			unitMetrics.actions[actionID] = otherAction
+			continue
+		}
+		for i := range actionMetrics.Targets {
+			actionMetrics.Targets[i].merge(&otherAction.Targets[i])
+		}
+	}
+
+	// Resource metrics may share the same key, so match them up by their order of registration.
+	resourcesByKey := make(map[ResourceKey][]*ResourceMetrics)
+	for _, resourceMetrics := range unitMetrics.resources {
+		key := ResourceKey{ActionID: resourceMetrics.ActionID, Type: resourceMetrics.Type}
+		resourcesByKey[key] = append(resourcesByKey[key], resourceMetrics)
+	}
+	for _, otherResource := range other.resources {
+		key := ResourceKey{ActionID: otherResource.ActionID, Type: otherResource.Type}
+		if matches := resourcesByKey[key]; len(matches) > 0 {
+			matches[0].merge(otherResource)
+			resourcesByKey[key] = matches[1:]
+		} else {
+			unitMetrics.resources = append(unitMetrics.resources, otherResource)
+		}
 	}
 }
 
@@ -594,6 +896,37 @@ func (unitMetrics *UnitMetrics) ToProto() *proto.UnitMetrics {
 		ChanceOfDeath: float64(unitMetrics.numItersDead) / n,
 	}
 
+	if unitMetrics.timeline != nil {
+		protoMetrics.Timeline = unitMetrics.timeline.ToProto()
+	}
+	if unitMetrics.auraOverlaps != nil {
+		protoMetrics.AuraOverlaps = unitMetrics.auraOverlaps.ToProto()
+	}
+	if unitMetrics.apl != nil {
+		protoMetrics.Apl = unitMetrics.apl.ToProto()
+	}
+
+	protoMetrics.ExecutePhaseDps = make([]*proto.DistributionMetrics, len(unitMetrics.executePhaseDps))
+	for phase := range unitMetrics.executePhaseDps {
+		if unitMetrics.executePhaseDps[phase].n == 0 {
+			protoMetrics.ExecutePhaseDps[phase] = &proto.DistributionMetrics{}
+		} else {
+			protoMetrics.ExecutePhaseDps[phase] = unitMetrics.executePhaseDps[phase].ToProto()
+		}
+	}
+	if unitMetrics.deathTime.n > 0 {
+		protoMetrics.DeathTime = unitMetrics.deathTime.ToProto()
+	}
+
+	protoMetrics.DamageBySchool = make(map[int32]float64, len(unitMetrics.schoolDamage))
+	for school, damage := range unitMetrics.schoolDamage {
+		protoMetrics.DamageBySchool[int32(school)] = damage / n
+	}
+	protoMetrics.DamageBySource = make([]float64, len(unitMetrics.sourceDamage))
+	for source, damage := range unitMetrics.sourceDamage {
+		protoMetrics.DamageBySource[source] = damage / n
+	}
+
 	protoMetrics.Actions = make([]*proto.ActionMetrics, 0, len(unitMetrics.actions))
 	for actionID, action := range unitMetrics.actions {
 		protoMetrics.Actions = append(protoMetrics.Actions, action.ToProto(actionID))
@@ -613,23 +946,43 @@ type AuraMetrics struct {
 	ID ActionID
 
 	// Metrics for the current iteration.
-	Uptime time.Duration
-	Procs  int32
+	Uptime       time.Duration
+	StackUptime  time.Duration // Uptime multiplied by the number of stacks.
+	Procs        int32
+	Applications int32
+	Refreshes    int32
 
 	// Aggregate values. These are updated after each iteration.
 	aggregator
-	procsSum int32
+	procsSum        int32
+	applicationsSum int32
+	refreshesSum    int32
+	stackUptimeSum  float64
 }
 
 func (auraMetrics *AuraMetrics) reset() {
 	auraMetrics.Uptime = 0
+	auraMetrics.StackUptime = 0
 	auraMetrics.Procs = 0
+	auraMetrics.Applications = 0
+	auraMetrics.Refreshes = 0
 }
 
 // This should be called when a Sim iteration is complete.
 func (auraMetrics *AuraMetrics) doneIteration() {
 	auraMetrics.add(auraMetrics.Uptime.Seconds())
 	auraMetrics.procsSum += auraMetrics.Procs
+	auraMetrics.applicationsSum += auraMetrics.Applications
+	auraMetrics.refreshesSum += auraMetrics.Refreshes
+	auraMetrics.stackUptimeSum += auraMetrics.StackUptime.Seconds()
+}
+
+func (auraMetrics *AuraMetrics) merge(other *AuraMetrics) {
+	auraMetrics.aggregator = *auraMetrics.aggregator.merge(&other.aggregator)
+	auraMetrics.procsSum += other.procsSum
+	auraMetrics.applicationsSum += other.applicationsSum
+	auraMetrics.refreshesSum += other.refreshesSum
+	auraMetrics.stackUptimeSum += other.stackUptimeSum
 }
 
 func (auraMetrics *AuraMetrics) ToProto() *proto.AuraMetrics {
@@ -641,5 +994,9 @@ func (auraMetrics *AuraMetrics) ToProto() *proto.AuraMetrics {
 		UptimeSecondsAvg:   mean,
 		UptimeSecondsStdev: stdev,
 		ProcsAvg:           float64(auraMetrics.procsSum) / float64(auraMetrics.n),
+
+		ApplicationsAvg:       float64(auraMetrics.applicationsSum) / float64(auraMetrics.n),
+		RefreshesAvg:          float64(auraMetrics.refreshesSum) / float64(auraMetrics.n),
+		StackUptimeSecondsAvg: auraMetrics.stackUptimeSum / float64(auraMetrics.n),
 	}
 }
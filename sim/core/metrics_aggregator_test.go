@@ -0,0 +1,188 @@
+package core
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+)
+
+func TestDistributionMetricsMerge(t *testing.T) {
+	sim := &Simulation{
+		Options:  &proto.SimOptions{Iterations: 6, SaveAllValues: true},
+		Duration: time.Second,
+		rand:     NewSplitMix(1),
+	}
+
+	values := []float64{1200, 980, 1500, 1500, 870, 1100}
+
+	expected := NewDistributionMetrics()
+	first := NewDistributionMetrics()
+	second := NewDistributionMetrics()
+	for i, v := range values {
+		sim.rand.Seed(int64(i))
+		for _, dm := range []*DistributionMetrics{&expected, &first, &second} {
+			if dm == &first && i >= 3 || dm == &second && i < 3 {
+				continue
+			}
+			dm.Total = v
+			dm.doneIteration(sim)
+		}
+	}
+
+	first.merge(&second)
+
+	want := expected.ToProto()
+	got := first.ToProto()
+	if got.Avg != want.Avg || got.Stdev != want.Stdev {
+		t.Fatalf("Merged avg/stdev %f/%f, expected %f/%f", got.Avg, got.Stdev, want.Avg, want.Stdev)
+	}
+	if got.Max != want.Max || got.MaxSeed != want.MaxSeed || got.Min != want.Min || got.MinSeed != want.MinSeed {
+		t.Fatalf("Merged extremes %v, expected %v", got, want)
+	}
+	if got.P5 != want.P5 || got.Median != want.Median || got.P95 != want.P95 {
+		t.Fatalf("Merged percentiles %f/%f/%f, expected %f/%f/%f", got.P5, got.Median, got.P95, want.P5, want.Median, want.P95)
+	}
+	for dpsRounded, count := range want.Hist {
+		if got.Hist[dpsRounded] != count {
+			t.Fatalf("Merged hist[%d] = %d, expected %d", dpsRounded, got.Hist[dpsRounded], count)
+		}
+	}
+	for i := range want.AllValues {
+		if got.AllValues[i] != want.AllValues[i] {
+			t.Fatalf("Merged AllValues %v, expected %v", got.AllValues, want.AllValues)
+		}
+	}
+}
+
+func TestDistributionMetricsHistBinWidth(t *testing.T) {
+	sim := &Simulation{
+		Options:  &proto.SimOptions{Iterations: 1000},
+		Duration: time.Second,
+		rand:     NewSplitMix(1),
+	}
+
+	histFor := func(options *proto.SimOptions) *proto.DistributionMetrics {
+		sim.Options = options
+		dm := NewDistributionMetrics()
+		for i := 0; i < 1000; i++ {
+			dm.Total = 1000 + float64(i%200)
+			dm.doneIteration(sim)
+		}
+		return dm.ToProto()
+	}
+
+	for _, test := range []struct {
+		options       *proto.SimOptions
+		expectedWidth int32
+	}{
+		{&proto.SimOptions{}, 10},
+		{&proto.SimOptions{HistogramBinWidth: 50}, 50},
+		// IQR of 100 over 1000 values gives a width of 20 by the Freedman-Diaconis rule.
+		{&proto.SimOptions{HistogramBinWidth: 50, AutoHistogramBinWidth: true}, 20},
+	} {
+		result := histFor(test.options)
+		if result.HistBinWidth != float64(test.expectedWidth) {
+			t.Fatalf("Bin width %f for %v, expected %d", result.HistBinWidth, test.options, test.expectedWidth)
+		}
+		total := int32(0)
+		for value, count := range result.Hist {
+			if value%test.expectedWidth != 0 {
+				t.Fatalf("Hist key %d is not the center of a bin of width %d", value, test.expectedWidth)
+			}
+			total += count
+		}
+		if total != 1000 {
+			t.Fatalf("Hist counts %d values, expected 1000", total)
+		}
+	}
+
+	// The default bins match the 10 wide bins of DPS values rounded to the nearest 10.
+	sim.Options = &proto.SimOptions{}
+	dm := NewDistributionMetrics()
+	dm.Total = 1014.6
+	dm.doneIteration(sim)
+	if result := dm.ToProto(); result.Hist[1010] != 1 {
+		t.Fatalf("Expected 1014.6 in the bin of 1010, got %v", result.Hist)
+	}
+}
+
+func TestAggregatorRelativeStdError(t *testing.T) {
+	var agg aggregator
+	agg.add(100)
+	if !math.IsInf(agg.relativeStdError(), 1) {
+		t.Fatalf("Relative error of a single value should be undefined, got %f", agg.relativeStdError())
+	}
+
+	agg.add(100)
+	if agg.relativeStdError() != 0 {
+		t.Fatalf("Relative error of identical values should be 0, got %f", agg.relativeStdError())
+	}
+
+	agg.add(90)
+	agg.add(110)
+	// mean 100, stdev sqrt(50), n 4
+	if expected := math.Sqrt(50) / 2 / 100; math.Abs(agg.relativeStdError()-expected) > 1e-12 {
+		t.Fatalf("Relative error %f, expected %f", agg.relativeStdError(), expected)
+	}
+}
+
+func TestExecutePhaseDuration(t *testing.T) {
+	sim := &Simulation{CurrentTime: time.Second * 100}
+	sim.executePhaseStarts = [NumExecutePhases]time.Duration{0, 65 * time.Second, -1, 80 * time.Second}
+
+	for phase, expected := range []time.Duration{65 * time.Second, 15 * time.Second, 0, 20 * time.Second} {
+		if got := sim.executePhaseDuration(proto.ExecutePhase(phase)); got != expected {
+			t.Errorf("Duration of phase %d = %s, expected %s", phase, got, expected)
+		}
+	}
+}
+
+func TestEncounterTargetKills(t *testing.T) {
+	targetStats := func(health float64) []float64 {
+		s := stats.Stats{}
+		s[stats.Health] = health
+		return s.ToFloatArray()
+	}
+	encounter := NewEncounter(&proto.Encounter{
+		UseHealth: true,
+		Targets:   []*proto.Target{{Stats: targetStats(1000)}, {Stats: targetStats(500)}},
+	})
+	sim := &Simulation{Options: &proto.SimOptions{}, rand: NewSplitMix(1)}
+
+	// The second target dies first, and the first one only took 900 of its 1000 health
+	// when the fight ended, so it dies at the end.
+	encounter.reset()
+	sim.CurrentTime = time.Second * 10
+	encounter.addDamageTaken(sim, &encounter.Targets[1].Unit, 500)
+	sim.CurrentTime = time.Second * 20
+	encounter.addDamageTaken(sim, &encounter.Targets[0].Unit, 900)
+	encounter.doneIteration(sim, time.Second*25)
+
+	// The first target dies first.
+	encounter.reset()
+	sim.CurrentTime = time.Second * 15
+	encounter.addDamageTaken(sim, &encounter.Targets[0].Unit, 1000)
+	sim.CurrentTime = time.Second * 30
+	encounter.addDamageTaken(sim, &encounter.Targets[1].Unit, 500)
+	encounter.doneIteration(sim, time.Second*30)
+
+	first := encounter.targetKills[0].ToProto(0)
+	if first.KillTime.Avg != 20 || first.KillTime.Min != 15 || first.KillTime.Max != 25 {
+		t.Errorf("Expected kill times of 15s and 25s for the first target, got %v", first.KillTime)
+	}
+	if first.KillOrder[0] != 0.5 || first.KillOrder[1] != 0.5 || first.AvgKillOrder != 1.5 {
+		t.Errorf("Expected the first target to die first half of the time, got %v", first.KillOrder)
+	}
+
+	second := encounter.targetKills[1].ToProto(1)
+	if second.KillTime.Avg != 20 {
+		t.Errorf("Expected an average kill time of 20s for the second target, got %f", second.KillTime.Avg)
+	}
+
+	if fightDuration := encounter.fightDuration.ToProto(); fightDuration.Avg != 27.5 {
+		t.Errorf("Expected an average fight duration of 27.5s, got %f", fightDuration.Avg)
+	}
+}
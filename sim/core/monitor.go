@@ -0,0 +1,23 @@
+package core
+
+import (
+	"time"
+)
+
+// SimMonitor receives events about the sims run in this process, e.g. to export
+// metrics from a long-running server. Methods may be called concurrently.
+type SimMonitor interface {
+	// Called after the presims of a sim are done.
+	PresimDone(duration time.Duration)
+	// Called once per requested sim, with the number of iterations it ran. Presim iterations are not included.
+	SimDone(iterations int32, duration time.Duration)
+	// Called when a sim panics, before the panic is turned into an error result.
+	SimPanicked(err interface{})
+}
+
+var simMonitor SimMonitor
+
+// SetSimMonitor sets the monitor for all sims. It must be called before any sims are started.
+func SetSimMonitor(monitor SimMonitor) {
+	simMonitor = monitor
+}
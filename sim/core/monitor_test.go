@@ -0,0 +1,44 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+type testSimMonitor struct {
+	simsDone   int
+	iterations int32
+	panics     int
+}
+
+func (monitor *testSimMonitor) PresimDone(_ time.Duration) {}
+func (monitor *testSimMonitor) SimDone(iterations int32, _ time.Duration) {
+	monitor.simsDone++
+	monitor.iterations += iterations
+}
+func (monitor *testSimMonitor) SimPanicked(_ interface{}) {
+	monitor.panics++
+}
+
+func TestSimMonitorReportsFailedSims(t *testing.T) {
+	monitor := &testSimMonitor{}
+	SetSimMonitor(monitor)
+	defer SetSimMonitor(nil)
+
+	// No agent is registered for this player, so the sim fails before running any iterations.
+	rsr := &proto.RaidSimRequest{
+		Raid:       &proto.Raid{Parties: []*proto.Party{{Players: []*proto.Player{{Class: proto.Class_ClassMage}}}}},
+		Encounter:  &proto.Encounter{Duration: 60, Targets: []*proto.Target{{}}},
+		SimOptions: &proto.SimOptions{Iterations: 10},
+	}
+	result := RunRaidSim(context.Background(), rsr)
+	if result.ErrorResult == "" {
+		t.Fatalf("Expected the sim to fail")
+	}
+	if monitor.simsDone != 1 || monitor.iterations != 0 || monitor.panics != 1 {
+		t.Fatalf("Expected 1 failed sim without iterations, got %+v", monitor)
+	}
+}
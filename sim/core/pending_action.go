@@ -1,6 +1,7 @@
 package core
 
 import (
+	"slices"
 	"time"
 )
 
@@ -49,3 +50,112 @@ func (pa *PendingAction) Cancel(sim *Simulation) {
 
 	pa.cancelled = true
 }
+
+// An action in the pending action queue. The ordering key is copied when the action
+// is added, so modifying NextActionAt or Priority of a queued action doesn't break
+// the heap; the action simply keeps its place in the queue.
+type pendingActionEntry struct {
+	at       time.Duration
+	priority ActionPriority
+	seq      uint64
+	pa       *PendingAction
+}
+
+// Returns true if e should run before other: earliest time first, then highest
+// priority, then in the order the actions were added.
+func (e *pendingActionEntry) before(other *pendingActionEntry) bool {
+	if e.at != other.at {
+		return e.at < other.at
+	}
+	if e.priority != other.priority {
+		return e.priority > other.priority
+	}
+	return e.seq < other.seq
+}
+
+// Binary min-heap of pending actions, replacing a sorted slice which needed a
+// linear scan and copy for every insertion.
+type pendingActionQueue struct {
+	entries []pendingActionEntry
+	nextSeq uint64
+}
+
+func (q *pendingActionQueue) reset() {
+	clear(q.entries)
+	q.entries = q.entries[:0]
+	q.nextSeq = 0
+}
+
+func (q *pendingActionQueue) push(pa *PendingAction) {
+	q.entries = append(q.entries, pendingActionEntry{
+		at:       pa.NextActionAt,
+		priority: pa.Priority,
+		seq:      q.nextSeq,
+		pa:       pa,
+	})
+	q.nextSeq++
+
+	// Sift up.
+	i := len(q.entries) - 1
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.entries[i].before(&q.entries[parent]) {
+			break
+		}
+		q.entries[i], q.entries[parent] = q.entries[parent], q.entries[i]
+		i = parent
+	}
+}
+
+// Returns the next action to run. When the queue is empty this is the sentinel
+// action, which is scheduled at NeverExpires.
+func (q *pendingActionQueue) peek() *PendingAction {
+	if len(q.entries) == 0 {
+		return sentinelPendingAction
+	}
+	return q.entries[0].pa
+}
+
+// Removes the next action to run.
+func (q *pendingActionQueue) pop() {
+	last := len(q.entries) - 1
+	if last < 0 {
+		return
+	}
+	q.entries[0] = q.entries[last]
+	q.entries[last] = pendingActionEntry{}
+	q.entries = q.entries[:last]
+
+	// Sift down.
+	i := 0
+	for {
+		first := i
+		if left := 2*i + 1; left < last && q.entries[left].before(&q.entries[first]) {
+			first = left
+		}
+		if right := 2*i + 2; right < last && q.entries[right].before(&q.entries[first]) {
+			first = right
+		}
+		if first == i {
+			return
+		}
+		q.entries[i], q.entries[first] = q.entries[first], q.entries[i]
+		i = first
+	}
+}
+
+// Calls fn for every queued action, in the reverse of the order they would run.
+// This leaves the queue unordered, so it must be reset before it is used again.
+func (q *pendingActionQueue) forEachReversed(fn func(pa *PendingAction)) {
+	slices.SortFunc(q.entries, func(a, b pendingActionEntry) int {
+		if b.before(&a) {
+			return -1
+		} else if a.before(&b) {
+			return 1
+		}
+		return 0
+	})
+	for _, entry := range q.entries {
+		fn(entry.pa)
+	}
+}
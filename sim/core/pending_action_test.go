@@ -0,0 +1,72 @@
+package core
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPendingActionQueueOrder(t *testing.T) {
+	var q pendingActionQueue
+	var order []string
+	add := func(name string, at time.Duration, priority ActionPriority) {
+		q.push(&PendingAction{
+			NextActionAt: at,
+			Priority:     priority,
+			OnAction: func(_ *Simulation) {
+				order = append(order, name)
+			},
+		})
+	}
+
+	add("late", time.Second*3, ActionPriorityDOT)
+	add("gcd 1", time.Second, ActionPriorityGCD)
+	add("auto", time.Second, ActionPriorityAuto)
+	add("gcd 2", time.Second, ActionPriorityGCD)
+	add("low", time.Second, ActionPriorityLow)
+	add("early", 0, ActionPriorityLow)
+
+	for pa := q.peek(); pa != sentinelPendingAction; pa = q.peek() {
+		q.pop()
+		pa.OnAction(nil)
+	}
+
+	expected := []string{"early", "auto", "gcd 1", "gcd 2", "low", "late"}
+	if !slices.Equal(order, expected) {
+		t.Fatalf("Actions ran in order %v, expected %v", order, expected)
+	}
+}
+
+func TestPendingActionQueueMatchesStableSort(t *testing.T) {
+	var q pendingActionQueue
+	rng := rand.New(rand.NewSource(1))
+
+	var added []*PendingAction
+	for i := 0; i < 1000; i++ {
+		pa := &PendingAction{
+			NextActionAt: time.Duration(rng.Intn(50)) * time.Millisecond,
+			Priority:     ActionPriority(rng.Intn(5) - 1),
+		}
+		added = append(added, pa)
+		q.push(pa)
+	}
+
+	expected := slices.Clone(added)
+	slices.SortStableFunc(expected, func(a, b *PendingAction) int {
+		if a.NextActionAt != b.NextActionAt {
+			return int(a.NextActionAt - b.NextActionAt)
+		}
+		return int(b.Priority - a.Priority)
+	})
+
+	for i, pa := range expected {
+		if next := q.peek(); next != pa {
+			t.Fatalf("Action %d was %v, expected %v", i, next, pa)
+		}
+		q.pop()
+	}
+	if q.peek() != sentinelPendingAction {
+		t.Fatalf("Queue should be empty")
+	}
+}
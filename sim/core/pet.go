@@ -62,6 +62,7 @@ func NewPet(name string, owner *Character, baseStats stats.Stats, statInheritanc
 				PseudoStats: stats.NewPseudoStats(),
 				auraTracker: newAuraTracker(),
 				Metrics:     NewUnitMetrics(),
+				spellSource: proto.SpellSource_SpellSourcePet,
 
 				StatDependencyManager: stats.NewStatDependencyManager(),
 			},
@@ -170,6 +171,7 @@ func (pet *Pet) Enable(sim *Simulation, petAgent PetAgent) {
 		pet.Log(sim, "Pet stats: %s", pet.GetStats().FlatString())
 		pet.Log(sim, "Pet inherited stats: %s", pet.ApplyStatDependencies(pet.inheritedStats).FlatString())
 		pet.Log(sim, "Pet summoned")
+		pet.Owner.emitUnitEvent(sim, proto.CombatEventType_CombatEventPetSummon, &pet.Unit, 0)
 	}
 
 	sim.addTracker(&pet.auraTracker)
@@ -257,6 +259,7 @@ func (pet *Pet) Disable(sim *Simulation) {
 	if sim.Log != nil {
 		pet.Log(sim, "Pet dismissed")
 		pet.Log(sim, pet.GetStats().FlatString())
+		pet.Owner.emitUnitEvent(sim, proto.CombatEventType_CombatEventPetDismiss, &pet.Unit, 0)
 	}
 }
 
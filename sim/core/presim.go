@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"time"
 
 	"github.com/wowsims/sod/sim/core/proto"
@@ -31,7 +32,28 @@ type PresimOptions struct {
 	OnPresimResult func(presimResult *proto.UnitMetrics, iterations int32, duration time.Duration) bool
 }
 
-func (sim *Simulation) runPresims(request *proto.RaidSimRequest) *proto.RaidSimResult {
+// Runs the presim rounds for this sim's agents, and returns the result of each round.
+func (sim *Simulation) runPresims(ctx context.Context, request *proto.RaidSimRequest) []*proto.RaidSimResult {
+	return sim.doPresims(request, func(presimRequest *proto.RaidSimRequest) *proto.RaidSimResult {
+		return runSim(ctx, presimRequest, nil, true)
+	})
+}
+
+// Gives this sim's agents the same presim settings as another sim constructed from
+// the same request, by replaying the rounds it ran instead of running them again.
+func (sim *Simulation) applyPresimRounds(request *proto.RaidSimRequest, rounds []*proto.RaidSimResult) {
+	nextRound := 0
+	sim.doPresims(request, func(_ *proto.RaidSimRequest) *proto.RaidSimResult {
+		if nextRound >= len(rounds) {
+			panic("Presim rounds don't match the request")
+		}
+		nextRound++
+		return rounds[nextRound-1]
+	})
+}
+
+// Runs presim rounds with runRound until every agent is done with its presims.
+func (sim *Simulation) doPresims(request *proto.RaidSimRequest, runRound func(*proto.RaidSimRequest) *proto.RaidSimResult) []*proto.RaidSimResult {
 	const numPresimIterations = 100
 
 	// Run presims if requested.
@@ -67,12 +89,17 @@ func (sim *Simulation) runPresims(request *proto.RaidSimRequest) *proto.RaidSimR
 	// done with presims.
 	presimRequest := googleProto.Clone(request).(*proto.RaidSimRequest)
 	presimRequest.SimOptions.RandomSeed = 1
+	presimRequest.SimOptions.ReplaySeed = 0
 	presimRequest.SimOptions.Debug = false
 	presimRequest.SimOptions.DebugFirstIteration = false
+	presimRequest.SimOptions.CombatEvents = nil
+	presimRequest.SimOptions.TimelineBinSeconds = 0
+	presimRequest.SimOptions.OverlapAuras = nil
 	presimRequest.SimOptions.Iterations = numPresimIterations
+	presimRequest.SimOptions.TargetRelativeError = 0 // Presim results assume exactly numPresimIterations.
 	duration := DurationFromSeconds(presimRequest.Encounter.Duration)
 
-	var lastResult *proto.RaidSimResult
+	var rounds []*proto.RaidSimResult
 
 	doOne := sim.Encounter.EndFightAtHealth > 0
 	for doOne || remainingAgents > 0 {
@@ -94,8 +121,8 @@ func (sim *Simulation) runPresims(request *proto.RaidSimRequest) *proto.RaidSimR
 		}
 
 		// Run the presim.
-		presimResult := runSim(presimRequest, nil, true)
-		lastResult = presimResult
+		presimResult := runRound(presimRequest)
+		rounds = append(rounds, presimResult)
 
 		if presimResult.ErrorResult != "" {
 			break
@@ -118,5 +145,5 @@ func (sim *Simulation) runPresims(request *proto.RaidSimRequest) *proto.RaidSimR
 		}
 		doOne = false
 	}
-	return lastResult
+	return rounds
 }
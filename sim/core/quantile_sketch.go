@@ -0,0 +1,108 @@
+package core
+
+import (
+	"math"
+	"slices"
+)
+
+// Relative accuracy of the values returned by quantileSketch.
+const quantileSketchAccuracy = 0.005
+
+var (
+	quantileSketchGamma    = (1 + quantileSketchAccuracy) / (1 - quantileSketchAccuracy)
+	quantileSketchLogGamma = math.Log(quantileSketchGamma)
+)
+
+// quantileSketch estimates quantiles of a stream of values without keeping them,
+// by counting values in logarithmically sized buckets. Every estimate is within
+// quantileSketchAccuracy of the true quantile, relative to its value.
+//
+// Merging sketches is exact, so results don't depend on how iterations are split
+// across parallel sims.
+type quantileSketch struct {
+	positive map[int32]int64 // Bucket index of value to count.
+	negative map[int32]int64 // Bucket index of -value to count.
+	zeros    int64
+	n        int64
+}
+
+func newQuantileSketch() quantileSketch {
+	return quantileSketch{
+		positive: make(map[int32]int64),
+		negative: make(map[int32]int64),
+	}
+}
+
+func quantileSketchIndex(v float64) int32 {
+	return int32(math.Ceil(math.Log(v) / quantileSketchLogGamma))
+}
+
+// Returns the value in the middle of bucket idx, in terms of relative error.
+func quantileSketchValue(idx int32) float64 {
+	return 2 * math.Pow(quantileSketchGamma, float64(idx)) / (quantileSketchGamma + 1)
+}
+
+func (qs *quantileSketch) add(v float64) {
+	qs.n++
+	switch {
+	case v > 0:
+		qs.positive[quantileSketchIndex(v)]++
+	case v < 0:
+		qs.negative[quantileSketchIndex(-v)]++
+	default:
+		qs.zeros++
+	}
+}
+
+func (qs *quantileSketch) merge(other *quantileSketch) {
+	for idx, count := range other.positive {
+		qs.positive[idx] += count
+	}
+	for idx, count := range other.negative {
+		qs.negative[idx] += count
+	}
+	qs.zeros += other.zeros
+	qs.n += other.n
+}
+
+// Returns the estimated q-quantile, for 0 <= q <= 1. Returns 0 if there are no values.
+func (qs *quantileSketch) quantile(q float64) float64 {
+	if qs.n == 0 {
+		return 0
+	}
+
+	// Rank of the quantile among all values, in ascending order.
+	rank := int64(math.Round(q * float64(qs.n-1)))
+
+	negativeIndices := sortedKeys(qs.negative)
+	for i := len(negativeIndices) - 1; i >= 0; i-- {
+		idx := negativeIndices[i]
+		if rank < qs.negative[idx] {
+			return -quantileSketchValue(idx)
+		}
+		rank -= qs.negative[idx]
+	}
+
+	if rank < qs.zeros {
+		return 0
+	}
+	rank -= qs.zeros
+
+	positiveIndices := sortedKeys(qs.positive)
+	for _, idx := range positiveIndices {
+		if rank < qs.positive[idx] {
+			return quantileSketchValue(idx)
+		}
+		rank -= qs.positive[idx]
+	}
+	return quantileSketchValue(positiveIndices[len(positiveIndices)-1])
+}
+
+func sortedKeys(m map[int32]int64) []int32 {
+	keys := make([]int32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
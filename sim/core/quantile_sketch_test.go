@@ -0,0 +1,68 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantileSketch(t *testing.T) {
+	qs := newQuantileSketch()
+	for v := 1; v <= 1000; v++ {
+		qs.add(float64(v))
+	}
+
+	for _, tc := range []struct {
+		q        float64
+		expected float64
+	}{
+		{0, 1},
+		{0.05, 51},
+		{0.25, 251},
+		{0.5, 500},
+		{0.75, 750},
+		{0.95, 950},
+		{1, 1000},
+	} {
+		if got := qs.quantile(tc.q); math.Abs(got-tc.expected) > tc.expected*quantileSketchAccuracy {
+			t.Errorf("quantile(%f) = %f, expected %f", tc.q, got, tc.expected)
+		}
+	}
+}
+
+func TestQuantileSketchNegativeAndZero(t *testing.T) {
+	qs := newQuantileSketch()
+	for _, v := range []float64{-100, -10, 0, 0, 10} {
+		qs.add(v)
+	}
+
+	if got := qs.quantile(0); math.Abs(got+100) > 100*quantileSketchAccuracy {
+		t.Errorf("quantile(0) = %f, expected -100", got)
+	}
+	if got := qs.quantile(0.5); got != 0 {
+		t.Errorf("quantile(0.5) = %f, expected 0", got)
+	}
+	if got := qs.quantile(1); math.Abs(got-10) > 10*quantileSketchAccuracy {
+		t.Errorf("quantile(1) = %f, expected 10", got)
+	}
+}
+
+func TestQuantileSketchMerge(t *testing.T) {
+	expected := newQuantileSketch()
+	first := newQuantileSketch()
+	second := newQuantileSketch()
+	for v := 1; v <= 100; v++ {
+		expected.add(float64(v * v))
+		if v%3 == 0 {
+			first.add(float64(v * v))
+		} else {
+			second.add(float64(v * v))
+		}
+	}
+
+	first.merge(&second)
+	for _, q := range []float64{0.05, 0.25, 0.5, 0.75, 0.95} {
+		if got, want := first.quantile(q), expected.quantile(q); got != want {
+			t.Errorf("Merged quantile(%f) = %f, expected %f", q, got, want)
+		}
+	}
+}
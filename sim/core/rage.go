@@ -165,6 +165,7 @@ func (rb *rageBar) AddRage(sim *Simulation, amount float64, metrics *ResourceMet
 
 	if sim.Log != nil {
 		rb.unit.Log(sim, "Gained %0.3f rage from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, rb.currentRage, newRage)
+		rb.unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeRage, metrics.ActionID, amount, newRage)
 	}
 
 	rb.currentRage = newRage
@@ -190,6 +191,7 @@ func (rb *rageBar) SpendRage(sim *Simulation, amount float64, metrics *ResourceM
 
 	if sim.Log != nil {
 		rb.unit.Log(sim, "Spent %0.3f rage from %s (%0.3f --> %0.3f).", amount, metrics.ActionID, rb.currentRage, newRage)
+		rb.unit.emitResourceEvent(sim, proto.ResourceType_ResourceTypeRage, metrics.ActionID, -amount, newRage)
 	}
 
 	rb.currentRage = newRage
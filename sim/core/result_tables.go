@@ -0,0 +1,302 @@
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// A flat table of sim results, with one value per column in each row.
+//
+// Values are strings, int32s, float64s, bools, or nil when a column doesn't apply to a row.
+type ResultTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Columns identifying the unit of a row, at the start of every table except runs.
+var unitColumns = []string{"run_id", "unit_kind", "party_index", "unit_index", "unit_name", "owner_name"}
+
+// Columns identifying the action of a row.
+var actionIDColumns = []string{"spell_id", "item_id", "other_id", "tag", "rank"}
+
+// Flattens a RaidSimResult into tidy tables, which can be joined on their unit and action columns:
+//   - runs: one row for the whole result.
+//   - units: one row for each player, pet and target.
+//   - actions: one row for each action of each unit, and each unit targeted by it.
+//   - auras: one row for each aura of each unit.
+//   - resources: one row for each resource gain of each unit.
+//   - damage_by_school: one row for each spell school each unit dealt damage with.
+//   - damage_by_source: one row for each SpellSource of each unit.
+//   - target_kills: one row for each target of a health based encounter.
+//   - kill_order: one row for each target of a health based encounter, and each position it can die in.
+//
+// runID is included in every row, so that the tables of several sims can be concatenated.
+func RaidSimResultTables(result *proto.RaidSimResult, runID string) []*ResultTable {
+	runs := &ResultTable{
+		Name: "runs",
+		Columns: []string{"run_id", "iterations", "avg_iteration_duration", "relative_error", "raid_dps_avg", "raid_dps_stdev", "raid_hps_avg", "raid_hps_stdev",
+			"fight_duration_avg", "fight_duration_stdev"},
+	}
+	units := &ResultTable{
+		Name: "units",
+		Columns: append(cloneStrings(unitColumns), "dps_avg", "dps_stdev", "dpasp_avg", "tps_avg", "dtps_avg", "tmi_avg",
+			"hps_avg", "hps_stdev", "tto_avg", "seconds_oom_avg", "chance_of_death", "death_time_avg"),
+	}
+	actions := &ResultTable{
+		Name: "actions",
+		Columns: append(append(cloneStrings(unitColumns), actionIDColumns...), "is_melee", "is_passive", "spell_school", "target_index", "target_name",
+			"casts", "hits", "resisted_hits", "crits", "resisted_crits", "ticks", "resisted_ticks", "crit_ticks", "resisted_crit_ticks",
+			"misses", "dodges", "parries", "blocks", "blocked_crits", "glances",
+			"damage", "resisted_damage", "crit_damage", "resisted_crit_damage", "tick_damage", "resisted_tick_damage",
+			"crit_tick_damage", "resisted_crit_tick_damage", "glance_damage", "block_damage", "blocked_crit_damage",
+			"threat", "healing", "crit_healing", "shielding", "cast_time_ms"),
+	}
+	auras := &ResultTable{
+		Name: "auras",
+		Columns: append(append(cloneStrings(unitColumns), actionIDColumns...), "uptime_seconds_avg", "uptime_seconds_stdev", "procs_avg",
+			"applications_avg", "refreshes_avg", "stack_uptime_seconds_avg"),
+	}
+	resources := &ResultTable{
+		Name:    "resources",
+		Columns: append(append(cloneStrings(unitColumns), actionIDColumns...), "resource_type", "events", "gain", "actual_gain"),
+	}
+	damageBySchool := &ResultTable{
+		Name:    "damage_by_school",
+		Columns: append(cloneStrings(unitColumns), "spell_school", "school_name", "damage_avg"),
+	}
+	damageBySource := &ResultTable{
+		Name:    "damage_by_source",
+		Columns: append(cloneStrings(unitColumns), "source", "damage_avg"),
+	}
+	targetKills := &ResultTable{
+		Name:    "target_kills",
+		Columns: append(cloneStrings(unitColumns), "kill_time_avg", "kill_time_stdev", "kill_time_min", "kill_time_median", "kill_time_max", "avg_kill_order"),
+	}
+	killOrder := &ResultTable{
+		Name:    "kill_order",
+		Columns: append(cloneStrings(unitColumns), "position", "chance"),
+	}
+
+	raidMetrics := result.GetRaidMetrics()
+	fightDuration := result.GetEncounterMetrics().GetFightDuration()
+	runs.Rows = append(runs.Rows, []interface{}{
+		runID, result.Iterations, result.AvgIterationDuration, result.RelativeError,
+		raidMetrics.GetDps().GetAvg(), raidMetrics.GetDps().GetStdev(), raidMetrics.GetHps().GetAvg(), raidMetrics.GetHps().GetStdev(),
+		fightDuration.GetAvg(), fightDuration.GetStdev(),
+	})
+
+	// Unit indices are shared by the targets and the raid units, so one map names the targets of any action.
+	unitNames := make(map[int32]string)
+	for _, target := range result.GetEncounterMetrics().GetTargets() {
+		unitNames[target.UnitIndex] = target.Name
+	}
+	for _, party := range raidMetrics.GetParties() {
+		for _, player := range party.Players {
+			unitNames[player.UnitIndex] = player.Name
+			for _, pet := range player.Pets {
+				unitNames[pet.UnitIndex] = pet.Name
+			}
+		}
+	}
+
+	addUnit := func(kind string, partyIndex interface{}, unit *proto.UnitMetrics, owner *proto.UnitMetrics) {
+		unitValues := []interface{}{runID, kind, partyIndex, unit.UnitIndex, unit.Name, nil}
+		if owner != nil {
+			unitValues[5] = owner.Name
+		}
+
+		units.Rows = append(units.Rows, append(cloneValues(unitValues),
+			unit.GetDps().GetAvg(), unit.GetDps().GetStdev(), unit.GetDpasp().GetAvg(), unit.GetThreat().GetAvg(),
+			unit.GetDtps().GetAvg(), unit.GetTmi().GetAvg(), unit.GetHps().GetAvg(), unit.GetHps().GetStdev(),
+			unit.GetTto().GetAvg(), unit.SecondsOomAvg, unit.ChanceOfDeath, nullableAvg(unit.DeathTime)))
+
+		for _, action := range unit.Actions {
+			actionValues := append(cloneValues(unitValues), actionIDValues(action.Id)...)
+			for _, tam := range action.Targets {
+				var targetName interface{}
+				if name, ok := unitNames[tam.UnitIndex]; ok {
+					targetName = name
+				}
+				actions.Rows = append(actions.Rows, append(cloneValues(actionValues),
+					action.IsMelee, action.IsPassive, action.SpellSchool, tam.UnitIndex, targetName,
+					tam.Casts, tam.Hits, tam.ResistedHits, tam.Crits, tam.ResistedCrits, tam.Ticks, tam.ResistedTicks, tam.CritTicks, tam.ResistedCritTicks,
+					tam.Misses, tam.Dodges, tam.Parries, tam.Blocks, tam.BlockedCrits, tam.Glances,
+					tam.Damage, tam.ResistedDamage, tam.CritDamage, tam.ResistedCritDamage, tam.TickDamage, tam.ResistedTickDamage,
+					tam.CritTickDamage, tam.ResistedCritTickDamage, tam.GlanceDamage, tam.BlockDamage, tam.BlockedCritDamage,
+					tam.Threat, tam.Healing, tam.CritHealing, tam.Shielding, tam.CastTimeMs))
+			}
+		}
+
+		for _, aura := range unit.Auras {
+			auras.Rows = append(auras.Rows, append(append(cloneValues(unitValues), actionIDValues(aura.Id)...),
+				aura.UptimeSecondsAvg, aura.UptimeSecondsStdev, aura.ProcsAvg, aura.ApplicationsAvg, aura.RefreshesAvg, aura.StackUptimeSecondsAvg))
+		}
+
+		for _, resource := range unit.Resources {
+			resources.Rows = append(resources.Rows, append(append(cloneValues(unitValues), actionIDValues(resource.Id)...),
+				resource.Type.String(), resource.Events, resource.Gain, resource.ActualGain))
+		}
+
+		schools := make([]int32, 0, len(unit.DamageBySchool))
+		for school := range unit.DamageBySchool {
+			schools = append(schools, school)
+		}
+		sort.Slice(schools, func(i, j int) bool { return schools[i] < schools[j] })
+		for _, school := range schools {
+			damageBySchool.Rows = append(damageBySchool.Rows, append(cloneValues(unitValues),
+				school, spellSchoolName(SpellSchool(school)), unit.DamageBySchool[school]))
+		}
+
+		for source, damage := range unit.DamageBySource {
+			damageBySource.Rows = append(damageBySource.Rows, append(cloneValues(unitValues), proto.SpellSource(source).String(), damage))
+		}
+	}
+
+	for partyIndex, party := range raidMetrics.GetParties() {
+		for _, player := range party.Players {
+			addUnit("player", int32(partyIndex), player, nil)
+			for _, pet := range player.Pets {
+				addUnit("pet", int32(partyIndex), pet, player)
+			}
+		}
+	}
+	for _, target := range result.GetEncounterMetrics().GetTargets() {
+		addUnit("target", nil, target, nil)
+	}
+
+	for _, kills := range result.GetEncounterMetrics().GetTargetKills() {
+		var targetName interface{}
+		if name, ok := unitNames[kills.UnitIndex]; ok {
+			targetName = name
+		}
+		unitValues := []interface{}{runID, "target", nil, kills.UnitIndex, targetName, nil}
+
+		killTime := kills.KillTime
+		targetKills.Rows = append(targetKills.Rows, append(cloneValues(unitValues),
+			killTime.GetAvg(), killTime.GetStdev(), killTime.GetMin(), killTime.GetMedian(), killTime.GetMax(), kills.AvgKillOrder))
+		for i, chance := range kills.KillOrder {
+			killOrder.Rows = append(killOrder.Rows, append(cloneValues(unitValues), int32(i+1), chance))
+		}
+	}
+
+	return []*ResultTable{runs, units, actions, auras, resources, damageBySchool, damageBySource, targetKills, killOrder}
+}
+
+// Returns the names of all schools in the mask, e.g. Fire+Frost.
+func spellSchoolName(school SpellSchool) string {
+	var names []string
+	for i := 0; i < len(proto.SpellSchool_name); i++ {
+		if school.Matches(SpellSchoolFromProto(proto.SpellSchool(i))) {
+			names = append(names, strings.TrimPrefix(proto.SpellSchool(i).String(), "SpellSchool"))
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// Returns the average of a distribution, or nil if it isn't set.
+func nullableAvg(distMetrics *proto.DistributionMetrics) interface{} {
+	if distMetrics == nil {
+		return nil
+	}
+	return distMetrics.Avg
+}
+
+func actionIDValues(id *proto.ActionID) []interface{} {
+	values := []interface{}{nil, nil, nil, id.GetTag(), id.GetRank()}
+	switch {
+	case id.GetSpellId() != 0:
+		values[0] = id.GetSpellId()
+	case id.GetItemId() != 0:
+		values[1] = id.GetItemId()
+	case id.GetOtherId() != proto.OtherAction_OtherActionNone:
+		values[2] = id.GetOtherId().String()
+	}
+	return values
+}
+
+func cloneStrings(values []string) []string {
+	return append([]string(nil), values...)
+}
+
+func cloneValues(values []interface{}) []interface{} {
+	return append([]interface{}(nil), values...)
+}
+
+// Writes the table as CSV, with a header row of the column names.
+func (table *ResultTable) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(table.Columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(table.Columns))
+	for _, row := range table.Rows {
+		for i, value := range row {
+			record[i] = formatResultValue(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes the rows of all tables as newline-delimited JSON objects, keyed by column name in
+// column order. Each object also has a "table" key with the name of its table.
+func WriteResultTablesNDJSON(w io.Writer, tables []*ResultTable) error {
+	for _, table := range tables {
+		for _, row := range table.Rows {
+			line := []byte(`{"table":`)
+			line = strconv.AppendQuote(line, table.Name)
+			for i, value := range row {
+				line = append(line, ',')
+				line = strconv.AppendQuote(line, table.Columns[i])
+				line = append(line, ':')
+
+				if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+					// Not representable in JSON.
+					value = nil
+				}
+				encoded, err := json.Marshal(value)
+				if err != nil {
+					return fmt.Errorf("table %s, column %s: %w", table.Name, table.Columns[i], err)
+				}
+				line = append(line, encoded...)
+			}
+			line = append(line, '}', '\n')
+
+			if _, err := w.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatResultValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
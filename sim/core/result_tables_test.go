@@ -0,0 +1,160 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+func testRaidSimResult() *proto.RaidSimResult {
+	return &proto.RaidSimResult{
+		Iterations: 10,
+		RaidMetrics: &proto.RaidMetrics{
+			Dps: &proto.DistributionMetrics{Avg: 150},
+			Parties: []*proto.PartyMetrics{{
+				Players: []*proto.UnitMetrics{{
+					Name:      "Player",
+					UnitIndex: 1,
+					Dps:       &proto.DistributionMetrics{Avg: 150, Stdev: 10},
+					Actions: []*proto.ActionMetrics{{
+						Id:      &proto.ActionID{RawId: &proto.ActionID_SpellId{SpellId: 100}},
+						Targets: []*proto.TargetedActionMetrics{{UnitIndex: 0, Casts: 3, Damage: 1200.5}},
+					}, {
+						Id:      &proto.ActionID{RawId: &proto.ActionID_SpellId{SpellId: 101}},
+						Targets: []*proto.TargetedActionMetrics{{UnitIndex: 1, Casts: 1, Healing: 400}},
+					}},
+					Auras: []*proto.AuraMetrics{{
+						Id:               &proto.ActionID{RawId: &proto.ActionID_OtherId{OtherId: proto.OtherAction_OtherActionAttack}},
+						UptimeSecondsAvg: 30,
+					}},
+					DamageBySchool: map[int32]float64{int32(SpellSchoolShadow): 300, int32(SpellSchoolFire | SpellSchoolFrost): 100},
+					DamageBySource: []float64{200, 150, 0, 0, 0, 0, 50},
+					Pets: []*proto.UnitMetrics{{
+						Name:      "Pet, the Second",
+						UnitIndex: 2,
+						Dps:       &proto.DistributionMetrics{Avg: 50},
+					}},
+				}},
+			}},
+		},
+		EncounterMetrics: &proto.EncounterMetrics{
+			Targets: []*proto.UnitMetrics{{
+				Name:      "Target 1",
+				UnitIndex: 0,
+				Actions: []*proto.ActionMetrics{{
+					Id:      &proto.ActionID{RawId: &proto.ActionID_ItemId{ItemId: 200}, Tag: 1},
+					Targets: []*proto.TargetedActionMetrics{{UnitIndex: 2, Hits: 2}},
+				}},
+			}},
+		},
+	}
+}
+
+func TestRaidSimResultTables(t *testing.T) {
+	tables := RaidSimResultTables(testRaidSimResult(), "run1")
+
+	tablesByName := make(map[string]*ResultTable)
+	for _, table := range tables {
+		tablesByName[table.Name] = table
+		for _, row := range table.Rows {
+			if len(row) != len(table.Columns) {
+				t.Fatalf("Table %s has a row with %d values for %d columns", table.Name, len(row), len(table.Columns))
+			}
+		}
+	}
+
+	if numUnits := len(tablesByName["units"].Rows); numUnits != 3 {
+		t.Errorf("Expected 3 units, got %d", numUnits)
+	}
+
+	actions := tablesByName["actions"]
+	if len(actions.Rows) != 3 {
+		t.Fatalf("Expected 3 actions, got %d", len(actions.Rows))
+	}
+	column := func(name string) int {
+		for i, col := range actions.Columns {
+			if col == name {
+				return i
+			}
+		}
+		t.Fatalf("No column %s", name)
+		return -1
+	}
+	if got := actions.Rows[0][column("target_name")]; got != "Target 1" {
+		t.Errorf("Expected the player action to target Target 1, got %v", got)
+	}
+	if got := actions.Rows[1][column("target_name")]; got != "Player" {
+		t.Errorf("Expected the player heal to target the player, got %v", got)
+	}
+	if got := actions.Rows[2][column("target_name")]; got != "Pet, the Second" {
+		t.Errorf("Expected the target action to target the pet, got %v", got)
+	}
+	if got := actions.Rows[2][column("item_id")]; got != int32(200) {
+		t.Errorf("Expected item ID 200, got %v", got)
+	}
+	if got := actions.Rows[2][column("spell_id")]; got != nil {
+		t.Errorf("Expected no spell ID, got %v", got)
+	}
+}
+
+func TestResultTableWriteCSV(t *testing.T) {
+	units := RaidSimResultTables(testRaidSimResult(), "run1")[1]
+
+	var buf bytes.Buffer
+	if err := units.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() returned error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected a header and 3 rows, got %d lines", len(lines))
+	}
+	if !strings.HasPrefix(lines[0], "run_id,unit_kind,party_index,unit_index,unit_name,owner_name,dps_avg") {
+		t.Errorf("Unexpected header: %s", lines[0])
+	}
+	if expected := `run1,pet,0,2,"Pet, the Second",Player,50,`; !strings.HasPrefix(lines[2], expected) {
+		t.Errorf("Expected pet row to start with %s, got %s", expected, lines[2])
+	}
+	if expected := "run1,target,,0,Target 1,,0,"; !strings.HasPrefix(lines[3], expected) {
+		t.Errorf("Expected target row to start with %s, got %s", expected, lines[3])
+	}
+}
+
+func TestWriteResultTablesNDJSON(t *testing.T) {
+	tables := RaidSimResultTables(testRaidSimResult(), "run1")
+
+	var buf bytes.Buffer
+	if err := WriteResultTablesNDJSON(&buf, tables[:1]); err != nil {
+		t.Fatalf("WriteResultTablesNDJSON() returned error: %v", err)
+	}
+
+	expected := `{"table":"runs","run_id":"run1","iterations":10,"avg_iteration_duration":0,"relative_error":0,"raid_dps_avg":150,"raid_dps_stdev":0,"raid_hps_avg":0,"raid_hps_stdev":0,"fight_duration_avg":0,"fight_duration_stdev":0}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, buf.String())
+	}
+}
+
+func TestRaidSimResultTablesDamageBreakdown(t *testing.T) {
+	tables := RaidSimResultTables(testRaidSimResult(), "run1")
+
+	bySchool := tables[5]
+	if len(bySchool.Rows) != 2 {
+		t.Fatalf("Expected 2 schools, got %d", len(bySchool.Rows))
+	}
+	if name := bySchool.Rows[0][7]; name != "Fire+Frost" {
+		t.Errorf("Expected Fire+Frost first, got %v", name)
+	}
+	if name := bySchool.Rows[1][7]; name != "Shadow" {
+		t.Errorf("Expected Shadow second, got %v", name)
+	}
+
+	bySource := tables[6]
+	if len(bySource.Rows) != 7 {
+		t.Fatalf("Expected a row for each source, got %d", len(bySource.Rows))
+	}
+	if source, damage := bySource.Rows[6][6], bySource.Rows[6][7]; source != "SpellSourcePet" || damage != 50.0 {
+		t.Errorf("Expected 50 pet damage, got %v for %v", damage, source)
+	}
+}
@@ -1,6 +1,10 @@
 package core
 
-import "strconv"
+import (
+	"strconv"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
 
 type ShieldConfig struct {
 	SelfOnly bool // Set to true to only create the self-shield.
@@ -34,9 +38,15 @@ func (shield *Shield) Apply(sim *Simulation, shieldAmount float64) {
 	shield.Spell.SpellMetrics[target.UnitIndex].TotalThreat += threat
 	shield.Spell.SpellMetrics[target.UnitIndex].TotalShielding += shieldAmount
 	shield.Spell.SpellMetrics[target.UnitIndex].Hits++
+	if timeline := caster.Metrics.timeline; timeline != nil {
+		timeline.addHealing(sim, shieldAmount)
+	}
 
 	if sim.Log != nil {
 		caster.Log(sim, "%s %s Hit for %0.3f shielding. (Threat: %0.3f)", target.LogLabel(), shield.Spell.ActionID, shieldAmount, threat)
+		event := newCombatEvent(proto.CombatEventType_CombatEventHeal, caster, target, shield.Spell.ActionID)
+		event.Amount = shieldAmount
+		sim.emitEvent(event)
 	}
 }
 
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
@@ -9,10 +10,10 @@ import (
 	"runtime/debug"
 	"slices"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
 )
 
 type Task interface {
@@ -27,23 +28,32 @@ type Simulation struct {
 	rand  Rand
 	rseed int64
 
+	// Index of the first iteration run by this Simulation, when iterations are
+	// split across multiple worker Simulations. See runParallel().
+	iterationOffset int32
+
 	// Used for testing only, see RandomFloat().
 	isTest    bool
 	testRands map[string]Rand
 
 	// Current Simulation State
-	pendingActions []*PendingAction
+	pendingActions pendingActionQueue
 	CurrentTime    time.Duration // duration that has elapsed in the sim since starting
 	Duration       time.Duration // Duration of current iteration
 	NeedsInput     bool          // Sim is in interactive mode and needs input
 
 	ProgressReport func(*proto.ProgressMetrics)
 
+	// Set while any event sink records the current iteration.
 	Log func(string, ...interface{})
 
+	eventSinks       []CombatEventSink
+	activeEventSinks []CombatEventSink
+
 	executePhase int32 // 20, 25, or 35 for the respective execute range, 100 otherwise
 
-	executePhaseCallbacks []func(*Simulation, int32) // 2nd parameter is 35 for 35%, 25 for 25% and 20 for 20%
+	executePhaseCallbacks []func(*Simulation, int32)      // 2nd parameter is 35 for 35%, 25 for 25% and 20 for 20%
+	executePhaseStarts    [NumExecutePhases]time.Duration // Start time of each phase in the current iteration, or -1 if not reached.
 
 	nextExecuteDuration time.Duration
 	nextExecuteDamage   float64
@@ -56,7 +66,7 @@ type Simulation struct {
 
 	minWeaponAttackTime time.Duration
 	weaponAttacks       []*WeaponAttack
-	extraAttacks int32
+	extraAttacks        int32
 
 	minTaskTime time.Duration
 	tasks       []Task
@@ -105,14 +115,18 @@ func (sim *Simulation) RemoveTask(task Task) {
 	}
 }
 
-func RunSim(rsr *proto.RaidSimRequest, progress chan *proto.ProgressMetrics) *proto.RaidSimResult {
-	return runSim(rsr, progress, false)
+func RunSim(ctx context.Context, rsr *proto.RaidSimRequest, progress chan *proto.ProgressMetrics) *proto.RaidSimResult {
+	return runSim(ctx, rsr, progress, false)
 }
 
-func runSim(rsr *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, skipPresim bool) (result *proto.RaidSimResult) {
+func runSim(ctx context.Context, rsr *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, skipPresim bool) (result *proto.RaidSimResult) {
+	t0 := time.Now()
 	if !rsr.SimOptions.IsTest {
 		defer func() {
 			if err := recover(); err != nil {
+				if simMonitor != nil {
+					simMonitor.SimPanicked(err)
+				}
 				errStr := ""
 				switch errt := err.(type) {
 				case string:
@@ -137,21 +151,42 @@ func runSim(rsr *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, ski
 		}()
 	}
 
+	// Called on every exit, including failed presims and panics, which report 0 iterations.
+	// Presims run their own sims with skipPresim, which shouldn't be reported separately.
+	var iterations int32
+	if simMonitor != nil && !skipPresim {
+		defer func() {
+			simMonitor.SimDone(iterations, time.Since(t0))
+		}()
+	}
+
+	if rsr.SimOptions.ReplaySeed != 0 {
+		rsr = replayRequest(rsr)
+	}
 	sim := NewSim(rsr)
 
+	var presimRounds []*proto.RaidSimResult
 	if !skipPresim {
 		if progress != nil {
 			progress <- &proto.ProgressMetrics{
-				TotalIterations: sim.Options.Iterations,
+				TotalIterations: sim.maxIterations(),
 				PresimRunning:   true,
 			}
 			runtime.Gosched() // allow time for message to make it back out.
 		}
-		presimResult := sim.runPresims(rsr)
+		presimStart := time.Now()
+		presimRounds = sim.runPresims(ctx, rsr)
+		var presimResult *proto.RaidSimResult
+		if len(presimRounds) > 0 {
+			presimResult = presimRounds[len(presimRounds)-1]
+		}
+		if simMonitor != nil {
+			simMonitor.PresimDone(time.Since(presimStart))
+		}
 		if presimResult != nil && presimResult.ErrorResult != "" {
 			if progress != nil {
 				progress <- &proto.ProgressMetrics{
-					TotalIterations: sim.Options.Iterations,
+					TotalIterations: sim.maxIterations(),
 					FinalRaidResult: presimResult,
 				}
 			}
@@ -159,7 +194,7 @@ func runSim(rsr *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, ski
 		}
 		if progress != nil {
 			progress <- &proto.ProgressMetrics{
-				TotalIterations: sim.Options.Iterations,
+				TotalIterations: sim.maxIterations(),
 				PresimRunning:   false,
 			}
 			sim.ProgressReport = func(progMetric *proto.ProgressMetrics) {
@@ -167,20 +202,49 @@ func runSim(rsr *proto.RaidSimRequest, progress chan *proto.ProgressMetrics, ski
 			}
 			runtime.Gosched() // allow time for message to make it back out.
 		}
-		// Use pre-sim as estimate for length of fight (when using health fight)
-		if sim.Encounter.EndFightAtHealth > 0 && presimResult != nil {
-			sim.BaseDuration = time.Duration(presimResult.AvgIterationDuration) * time.Second
-			sim.Duration = time.Duration(presimResult.AvgIterationDuration) * time.Second
-			sim.Encounter.DurationIsEstimate = false // we now have a pretty good value for duration
-		}
+		sim.applyPresimResult(presimResult)
 	}
 
 	// using a variable here allows us to mutate it in the deferred recover, sending out error info
-	result = sim.run()
+	if numWorkers := sim.numIterationWorkers(); numWorkers > 1 {
+		result = sim.runParallel(ctx, rsr, numWorkers, presimRounds)
+	} else {
+		result = sim.run(ctx)
+	}
+	iterations = result.Iterations
 
 	return result
 }
 
+// Returns a copy of the request which only runs the iteration seeded with its
+// replay seed, recording it as iteration 0.
+//
+// Iteration i of a sim is seeded with random_seed+i, and the seed of an iteration
+// is all that determines its outcome (including its duration variation), so
+// running a single iteration with the replayed seed as random_seed reproduces it.
+func replayRequest(rsr *proto.RaidSimRequest) *proto.RaidSimRequest {
+	replay := googleProto.Clone(rsr).(*proto.RaidSimRequest)
+	options := replay.SimOptions
+	options.RandomSeed = options.ReplaySeed
+	options.Iterations = 1
+	options.MaxIterations = 0
+	options.TargetRelativeError = 0
+	options.DebugFirstIteration = true
+	if options.CombatEvents != nil {
+		options.CombatEvents.Iteration = 0
+	}
+	return replay
+}
+
+// Use pre-sim as estimate for length of fight (when using health fight)
+func (sim *Simulation) applyPresimResult(presimResult *proto.RaidSimResult) {
+	if sim.Encounter.EndFightAtHealth > 0 && presimResult != nil {
+		sim.BaseDuration = time.Duration(presimResult.AvgIterationDuration) * time.Second
+		sim.Duration = time.Duration(presimResult.AvgIterationDuration) * time.Second
+		sim.Encounter.DurationIsEstimate = false // we now have a pretty good value for duration
+	}
+}
+
 func NewSim(rsr *proto.RaidSimRequest) *Simulation {
 	env, _, _ := NewEnvironment(rsr.Raid, rsr.Encounter, false)
 	return newSimWithEnv(env, rsr.SimOptions)
@@ -277,22 +341,22 @@ func (sim *Simulation) Reseed(seed int64) {
 
 // Run runs the simulation for the configured number of iterations, and
 // collects all the metrics together.
-func (sim *Simulation) run() *proto.RaidSimResult {
+//
+// If ctx is cancelled, the remaining iterations are skipped and the result
+// only includes the metrics of the iterations that completed.
+func (sim *Simulation) run(ctx context.Context) *proto.RaidSimResult {
 	t0 := time.Now()
 
-	logsBuffer := &strings.Builder{}
-	if sim.Options.Debug || sim.Options.DebugFirstIteration {
-		sim.Log = func(message string, vals ...interface{}) {
-			logsBuffer.WriteString(fmt.Sprintf("[%0.2f] "+message+"\n", append([]interface{}{sim.CurrentTime.Seconds()}, vals...)...))
-		}
-	}
+	writeEventSinks := sim.addOptionEventSinks()
 
 	// Uncomment this to print logs directly to console.
 	// sim.Options.Debug = true
-	// sim.Log = func(message string, vals ...interface{}) {
-	// 	fmt.Printf(fmt.Sprintf("[%0.1f] "+message+"\n", append([]interface{}{sim.CurrentTime.Seconds()}, vals...)...))
-	// }
+	// sim.AddEventSink(&consoleEventSink{})
 
+	if sim.iterationOffset > 0 || sim.Options.ReplaySeed != 0 {
+		sim.reseedRands(int64(sim.iterationOffset))
+	}
+	sim.startRecording(sim.iterationOffset)
 	sim.runOnce()
 	firstIterationDuration := sim.Duration
 	if sim.Encounter.EndFightAtHealth != 0 {
@@ -300,22 +364,30 @@ func (sim *Simulation) run() *proto.RaidSimResult {
 	}
 	totalDuration := firstIterationDuration
 
-	if !sim.Options.Debug {
-		sim.Log = nil
-	}
-
 	var st time.Time
-	for i := int32(1); i < sim.Options.Iterations; i++ {
+	maxIterations := sim.maxIterations()
+	completedIterations := int32(1)
+	cancelled := false
+	for i := int32(1); i < maxIterations; i++ {
+		if ctx.Err() != nil {
+			cancelled = true
+			break
+		}
+		if i >= sim.Options.Iterations && sim.hasConverged() {
+			break
+		}
+
 		// fmt.Printf("Iteration: %d\n", i)
 		if sim.ProgressReport != nil && time.Since(st) > time.Millisecond*100 {
 			metrics := sim.Raid.GetMetrics()
-			sim.ProgressReport(&proto.ProgressMetrics{TotalIterations: sim.Options.Iterations, CompletedIterations: i, Dps: metrics.Dps.Avg, Hps: metrics.Hps.Avg})
+			sim.ProgressReport(&proto.ProgressMetrics{TotalIterations: maxIterations, CompletedIterations: i, Dps: metrics.Dps.Avg, Hps: metrics.Hps.Avg})
 			runtime.Gosched() // ensure that reporting threads are given time to report, mostly only important in wasm (only 1 thread)
 			st = time.Now()
 		}
 
 		// Before each iteration, reset state to seed+iterations
-		sim.reseedRands(int64(i))
+		sim.reseedRands(int64(sim.iterationOffset + i))
+		sim.startRecording(sim.iterationOffset + i)
 
 		sim.runOnce()
 		iterDuration := sim.Duration
@@ -323,28 +395,63 @@ func (sim *Simulation) run() *proto.RaidSimResult {
 			iterDuration = sim.CurrentTime
 		}
 		totalDuration += iterDuration
+		completedIterations++
 	}
 	result := &proto.RaidSimResult{
 		RaidMetrics:      sim.Raid.GetMetrics(),
 		EncounterMetrics: sim.Encounter.GetMetricsProto(),
 
-		Logs:                   logsBuffer.String(),
 		FirstIterationDuration: firstIterationDuration.Seconds(),
-		AvgIterationDuration:   totalDuration.Seconds() / float64(sim.Options.Iterations),
+		AvgIterationDuration:   totalDuration.Seconds() / float64(completedIterations),
+		Cancelled:              cancelled,
+		Iterations:             completedIterations,
+		RelativeError:          sim.relativeError(),
 	}
+	writeEventSinks(result)
+	sim.Log = nil
 
 	// Final progress report
 	if sim.ProgressReport != nil {
-		sim.ProgressReport(&proto.ProgressMetrics{TotalIterations: sim.Options.Iterations, CompletedIterations: sim.Options.Iterations, Dps: result.RaidMetrics.Dps.Avg, FinalRaidResult: result})
+		sim.ProgressReport(&proto.ProgressMetrics{TotalIterations: maxIterations, CompletedIterations: completedIterations, Dps: result.RaidMetrics.Dps.Avg, FinalRaidResult: result})
 	}
 
-	if d := sim.Options.Iterations; d > 3000 {
+	if d := completedIterations; d > 3000 {
 		log.Printf("running %d iterations took %s", d, time.Since(t0))
 	}
 
 	return result
 }
 
+// Returns the most iterations this sim may run. Without a target error, this is
+// always the requested number of iterations.
+func (sim *Simulation) maxIterations() int32 {
+	if sim.Options.TargetRelativeError > 0 {
+		return max(sim.Options.Iterations, sim.Options.MaxIterations)
+	}
+	return sim.Options.Iterations
+}
+
+// Returns the metrics whose relative standard error decides when adaptive iterations stop.
+func (sim *Simulation) convergenceMetrics() *DistributionMetrics {
+	switch sim.Options.ConvergenceMetric {
+	case proto.ConvergenceMetric_ConvergenceMetricHps:
+		return &sim.Raid.hpsMetrics
+	case proto.ConvergenceMetric_ConvergenceMetricTps:
+		return &sim.Raid.AllPlayerUnits[0].Metrics.threat
+	default:
+		return &sim.Raid.dpsMetrics
+	}
+}
+
+// Returns the relative standard error of the convergence metric over all completed iterations.
+func (sim *Simulation) relativeError() float64 {
+	return sim.convergenceMetrics().relativeStdError()
+}
+
+func (sim *Simulation) hasConverged() bool {
+	return sim.Options.TargetRelativeError > 0 && sim.relativeError() <= sim.Options.TargetRelativeError
+}
+
 // RunOnce is the main event loop. It will run the simulation for number of seconds.
 func (sim *Simulation) runOnce() {
 	sim.reset()
@@ -375,12 +482,15 @@ func (sim *Simulation) reset() {
 		sim.Duration += time.Duration(sim.RandomFloat("sim duration")*float64(variation)) - sim.DurationVariation
 	}
 
-	sim.pendingActions = sim.pendingActions[:0]
-	sim.pendingActions = append(sim.pendingActions, sentinelPendingAction)
+	sim.pendingActions.reset()
 
 	sim.executePhase = 0
 	sim.nextExecutePhase()
 	sim.executePhaseCallbacks = nil
+	for i := range sim.executePhaseStarts {
+		sim.executePhaseStarts[i] = -1
+	}
+	sim.executePhaseStarts[proto.ExecutePhase_ExecutePhaseAbove35] = 0
 
 	// Use duration as an end check if not using health.
 	sim.endOfCombatDuration = sim.Duration
@@ -437,22 +547,28 @@ func (sim *Simulation) Cleanup() {
 	// quite at the Duration. Explicitly set this so that accesses to CurrentTime
 	// during the doneIteration phase will return the Duration value, which is
 	// intuitive.
+	//
+	// Health fights end once the targets are dead instead, so keep that time for the encounter metrics.
+	fightDuration := sim.Duration
+	if sim.Encounter.EndFightAtHealth > 0 {
+		fightDuration = sim.CurrentTime
+	}
 	sim.CurrentTime = sim.Duration
 
-	for _, pa := range sim.pendingActions {
+	sim.pendingActions.forEachReversed(func(pa *PendingAction) {
 		if pa.CleanUp != nil {
 			pa.CleanUp(sim)
 		}
-	}
+	})
 
 	sim.Raid.doneIteration(sim)
-	sim.Encounter.doneIteration(sim)
+	sim.Encounter.doneIteration(sim, fightDuration)
 
 	for _, unit := range sim.Raid.AllUnits {
-		unit.Metrics.doneIteration(unit, sim)
+		unit.Metrics.doneIteration(unit, sim, fightDuration)
 	}
 	for _, target := range sim.Encounter.TargetUnits {
-		target.Metrics.doneIteration(target, sim)
+		target.Metrics.doneIteration(target, sim, fightDuration)
 	}
 }
 
@@ -465,8 +581,7 @@ func (sim *Simulation) runPendingActions() {
 }
 
 func (sim *Simulation) Step() bool {
-	last := len(sim.pendingActions) - 1
-	pa := sim.pendingActions[last]
+	pa := sim.pendingActions.peek()
 
 	if pa.NextActionAt >= sim.minWeaponAttackTime && sim.minWeaponAttackTime <= sim.minTaskTime {
 		if sim.minWeaponAttackTime > sim.endOfCombatDuration || sim.Encounter.DamageTaken > sim.endOfCombatDamage {
@@ -484,7 +599,7 @@ func (sim *Simulation) Step() bool {
 		return false
 	}
 
-	sim.pendingActions = sim.pendingActions[:last]
+	sim.pendingActions.pop()
 	if pa.cancelled {
 		return false
 	}
@@ -535,6 +650,7 @@ func (sim *Simulation) advance(nextTime time.Duration) {
 	// execute phases 35%, 25%, and 20% in the first advance() call.
 	for sim.CurrentTime >= sim.nextExecuteDuration || sim.Encounter.DamageTaken >= sim.nextExecuteDamage {
 		sim.nextExecutePhase()
+		sim.executePhaseStarts[sim.executePhaseIndex()] = sim.CurrentTime
 		for _, callback := range sim.executePhaseCallbacks {
 			callback(sim, sim.executePhase)
 		}
@@ -581,29 +697,44 @@ func (sim *Simulation) AddPendingAction(pa *PendingAction) {
 	//	panic(fmt.Sprintf("Cant add action in the past: %s", pa.NextActionAt))
 	//}
 	pa.consumed = false
-	for index, v := range sim.pendingActions[1:] {
-		if v.NextActionAt < pa.NextActionAt || (v.NextActionAt == pa.NextActionAt && v.Priority >= pa.Priority) {
-			//if sim.Log != nil {
-			//	sim.Log("Adding action at index %d for time %s", index - len(sim.pendingActions), pa.NextActionAt)
-			//	for i := index; i < len(sim.pendingActions); i++ {
-			//		sim.Log("Upcoming action at %s", sim.pendingActions[i].NextActionAt)
-			//	}
-			//}
-			sim.pendingActions = append(sim.pendingActions, pa)
-			copy(sim.pendingActions[index+2:], sim.pendingActions[index+1:])
-			sim.pendingActions[index+1] = pa
-			return
-		}
-	}
-	//if sim.Log != nil {
-	//	sim.Log("Adding action at end for time %s", pa.NextActionAt)
-	//}
-	sim.pendingActions = append(sim.pendingActions, pa)
+	sim.pendingActions.push(pa)
 }
 
 func (sim *Simulation) RegisterExecutePhaseCallback(callback func(sim *Simulation, isExecute int32)) {
 	sim.executePhaseCallbacks = append(sim.executePhaseCallbacks, callback)
 }
+
+const NumExecutePhases = 4
+
+// Returns the current execute phase, as an index for per-phase metrics.
+func (sim *Simulation) executePhaseIndex() proto.ExecutePhase {
+	switch {
+	case sim.executePhase <= 20:
+		return proto.ExecutePhase_ExecutePhaseBelow20
+	case sim.executePhase <= 25:
+		return proto.ExecutePhase_ExecutePhase25To20
+	case sim.executePhase <= 35:
+		return proto.ExecutePhase_ExecutePhase35To25
+	}
+	return proto.ExecutePhase_ExecutePhaseAbove35
+}
+
+// Returns how long the current iteration spent in an execute phase, up to the current time.
+func (sim *Simulation) executePhaseDuration(phase proto.ExecutePhase) time.Duration {
+	start := sim.executePhaseStarts[phase]
+	if start < 0 {
+		return 0
+	}
+	end := sim.CurrentTime
+	for _, nextStart := range sim.executePhaseStarts[phase+1:] {
+		if nextStart >= 0 {
+			end = min(end, nextStart)
+			break
+		}
+	}
+	return max(0, end-start)
+}
+
 func (sim *Simulation) IsExecutePhase20() bool {
 	return sim.executePhase <= 20
 }
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+// Requests with fewer iterations than this per worker are not worth the cost
+// of constructing additional environments.
+const minIterationsPerWorker = 500
+
+// Returns how many worker Simulations the iterations of this sim should be split across.
+func (sim *Simulation) numIterationWorkers() int {
+	// Debug logs and interactive mode need every iteration to run on this sim,
+	// and tests compare exact results so keep them on a single goroutine too.
+	if sim.isTest || sim.Options.Debug || sim.Options.Interactive {
+		return 1
+	}
+
+	numWorkers := int(sim.Options.Concurrency)
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+	return max(1, min(numWorkers, int(sim.maxIterations()/minIterationsPerWorker)))
+}
+
+// runParallel splits the iterations of this sim across numWorkers Simulations,
+// each constructed from the same request and running on its own goroutine.
+//
+// Every iteration is seeded the same way it would be in a single-threaded run,
+// so for a fixed seed the merged results match those of sim.run(). presimRounds
+// are the presim results of this sim, which are applied to the other workers.
+func (sim *Simulation) runParallel(ctx context.Context, rsr *proto.RaidSimRequest, numWorkers int, presimRounds []*proto.RaidSimResult) *proto.RaidSimResult {
+	t0 := time.Now()
+	totalIterations := sim.maxIterations()
+	progressReport := sim.ProgressReport
+
+	workers := make([]*Simulation, numWorkers)
+	results := make([]*proto.RaidSimResult, numWorkers)
+	panics := make([]interface{}, numWorkers)
+
+	// Latest progress of each worker, combined into a single report for the caller.
+	var progressMut sync.Mutex
+	workerProgress := make([]*proto.ProgressMetrics, numWorkers)
+	reportProgress := func(workerIdx int, progMetric *proto.ProgressMetrics) {
+		progressMut.Lock()
+		defer progressMut.Unlock()
+
+		workerProgress[workerIdx] = progMetric
+
+		var completedIterations int32
+		var dps, hps float64
+		for _, wp := range workerProgress {
+			if wp == nil {
+				continue
+			}
+			completedIterations += wp.CompletedIterations
+			dps += wp.Dps * float64(wp.CompletedIterations)
+			hps += wp.Hps * float64(wp.CompletedIterations)
+		}
+		if completedIterations > 0 {
+			dps /= float64(completedIterations)
+			hps /= float64(completedIterations)
+		}
+
+		progressReport(&proto.ProgressMetrics{
+			TotalIterations:     totalIterations,
+			CompletedIterations: completedIterations,
+			Dps:                 dps,
+			Hps:                 hps,
+		})
+	}
+
+	// Share of n iterations for worker i.
+	split := func(n int32, i int) int32 {
+		share := n / int32(numWorkers)
+		if int32(i) < n%int32(numWorkers) {
+			share++
+		}
+		return share
+	}
+
+	workerOptions := make([]*proto.SimOptions, numWorkers)
+	workerMaxIterations := make([]int32, numWorkers)
+	for i := range workerOptions {
+		workerOptions[i] = googleProto.Clone(sim.Options).(*proto.SimOptions)
+		workerOptions[i].Iterations = split(sim.Options.Iterations, i)
+		workerMaxIterations[i] = split(totalIterations, i)
+		if sim.Options.TargetRelativeError > 0 {
+			workerOptions[i].MaxIterations = workerMaxIterations[i]
+			// The standard error of the merged results shrinks with the square root of
+			// the number of workers, so each worker can stop at a proportionally larger error.
+			workerOptions[i].TargetRelativeError *= math.Sqrt(float64(numWorkers))
+		}
+		if i > 0 {
+			// Only the first iteration is logged, which always belongs to the first worker.
+			workerOptions[i].DebugFirstIteration = false
+		}
+	}
+
+	var waitGroup sync.WaitGroup
+	var iterationOffset int32
+	for i, options := range workerOptions {
+		waitGroup.Add(1)
+		go func(workerIdx int, options *proto.SimOptions, iterationOffset int32) {
+			defer waitGroup.Done()
+			defer func() {
+				if err := recover(); err != nil {
+					panics[workerIdx] = err
+				}
+			}()
+
+			worker := sim
+			if workerIdx > 0 {
+				worker = NewSim(rsr)
+				if len(presimRounds) > 0 {
+					worker.applyPresimRounds(rsr, presimRounds)
+					worker.applyPresimResult(presimRounds[len(presimRounds)-1])
+				}
+			}
+			worker.Options = options
+			worker.iterationOffset = iterationOffset
+			worker.ProgressReport = nil
+			if progressReport != nil {
+				worker.ProgressReport = func(progMetric *proto.ProgressMetrics) {
+					reportProgress(workerIdx, progMetric)
+				}
+			}
+			workers[workerIdx] = worker
+
+			results[workerIdx] = worker.run(ctx)
+		}(i, options, iterationOffset)
+
+		iterationOffset += workerMaxIterations[i]
+	}
+	waitGroup.Wait()
+
+	// Re-raise worker panics on this goroutine, so they are reported like any other sim error.
+	for _, err := range panics {
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var totalDuration float64
+	var completedIterations int32
+	cancelled := false
+	for i, worker := range workers {
+		workerIterations := results[i].Iterations
+		totalDuration += results[i].AvgIterationDuration * float64(workerIterations)
+		completedIterations += workerIterations
+		cancelled = cancelled || results[i].Cancelled
+		if i > 0 {
+			sim.Environment.mergeMetrics(worker.Environment)
+		}
+	}
+
+	result := &proto.RaidSimResult{
+		RaidMetrics:      sim.Raid.GetMetrics(),
+		EncounterMetrics: sim.Encounter.GetMetricsProto(),
+
+		Logs:                   results[0].Logs,
+		FirstIterationDuration: results[0].FirstIterationDuration,
+		AvgIterationDuration:   totalDuration / float64(completedIterations),
+		Cancelled:              cancelled,
+		Iterations:             completedIterations,
+		RelativeError:          sim.relativeError(),
+	}
+	// Combat events are recorded by whichever worker ran the chosen iteration.
+	for _, workerResult := range results {
+		if len(workerResult.CombatEvents) > 0 || workerResult.CombatEventsNdjson != "" {
+			result.CombatEvents = workerResult.CombatEvents
+			result.CombatEventsNdjson = workerResult.CombatEventsNdjson
+		}
+	}
+
+	if progressReport != nil {
+		progressReport(&proto.ProgressMetrics{TotalIterations: totalIterations, CompletedIterations: completedIterations, Dps: result.RaidMetrics.Dps.Avg, FinalRaidResult: result})
+	}
+
+	if completedIterations > 3000 {
+		log.Printf("running %d iterations on %d workers took %s", completedIterations, numWorkers, time.Since(t0))
+	}
+
+	return result
+}
+
+// Adds the aggregated metrics of another Environment, constructed from the same
+// request, into the metrics of this one.
+func (env *Environment) mergeMetrics(other *Environment) {
+	if len(env.AllUnits) != len(other.AllUnits) {
+		panic(fmt.Sprintf("Cannot merge metrics of environments with %d and %d units", len(env.AllUnits), len(other.AllUnits)))
+	}
+
+	env.Raid.dpsMetrics.merge(&other.Raid.dpsMetrics)
+	env.Raid.hpsMetrics.merge(&other.Raid.hpsMetrics)
+	for i, party := range env.Raid.Parties {
+		party.dpsMetrics.merge(&other.Raid.Parties[i].dpsMetrics)
+		party.hpsMetrics.merge(&other.Raid.Parties[i].hpsMetrics)
+	}
+
+	env.Encounter.mergeMetrics(&other.Encounter)
+
+	for i, unit := range env.AllUnits {
+		otherUnit := other.AllUnits[i]
+		unit.Metrics.merge(&otherUnit.Metrics)
+		unit.auraTracker.mergeMetrics(&otherUnit.auraTracker)
+	}
+}
@@ -89,6 +89,9 @@ type Spell struct {
 	// From which slot this spell cast. Usually from Mainhand
 	CastType proto.CastType
 
+	// Where this spell comes from, for the damage breakdown in metrics.
+	Source proto.SpellSource
+
 	// Speed in yards/second. Spell missile speeds can be found in the game data.
 	// Example: https://wow.tools/dbc/?dbc=spellmisc&build=3.4.0.44996
 	MissileSpeed float64
@@ -168,6 +171,16 @@ func (unit *Unit) OnSpellRegistered(handler SpellRegisteredHandler) {
 	unit.spellRegistrationHandlers = append(unit.spellRegistrationHandlers, handler)
 }
 
+// Calls f, attributing all spells it registers to the given source.
+//
+// Spells registered later, e.g. from OnInit callbacks, keep the default source.
+func (unit *Unit) withSpellSource(source proto.SpellSource, f func()) {
+	previousSource := unit.spellSource
+	unit.spellSource = source
+	f()
+	unit.spellSource = previousSource
+}
+
 // Registers a new spell to the unit. Returns the newly created spell.
 func (unit *Unit) RegisterSpell(config SpellConfig) *Spell {
 	if len(unit.Spellbook) > 200 {
@@ -217,6 +230,7 @@ func (unit *Unit) RegisterSpell(config SpellConfig) *Spell {
 		Flags:        config.Flags,
 		CastType:     config.CastType,
 		MissileSpeed: config.MissileSpeed,
+		Source:       unit.spellSource,
 
 		SpellSchool:       config.SpellSchool,
 		SchoolIndex:       config.SpellSchool.GetSchoolIndex(),
@@ -532,6 +546,7 @@ func (spell *Spell) Cast(sim *Simulation, target *Unit) bool {
 
 func (spell *Spell) applyEffects(sim *Simulation, target *Unit) {
 	spell.SpellMetrics[target.UnitIndex].Casts++
+	spell.SpellMetrics[target.UnitIndex].ExecutePhaseCasts[sim.executePhaseIndex()]++
 	spell.casts++
 
 	spell.ApplyEffects(sim, target, spell)
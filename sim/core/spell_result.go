@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 
+	"github.com/wowsims/sod/sim/core/proto"
 	"github.com/wowsims/sod/sim/core/stats"
 )
 
@@ -391,6 +392,7 @@ func (spell *Spell) dealDamageInternal(sim *Simulation, isPeriodic bool, result
 
 	if sim.CurrentTime >= 0 {
 		spell.SpellMetrics[result.Target.UnitIndex].TotalDamage += result.Damage
+		spell.SpellMetrics[result.Target.UnitIndex].ExecutePhaseDamage[sim.executePhaseIndex()] += result.Damage
 		if isPartialResist {
 			spell.SpellMetrics[result.Target.UnitIndex].TotalResistedDamage += result.Damage
 		}
@@ -420,12 +422,16 @@ func (spell *Spell) dealDamageInternal(sim *Simulation, isPeriodic bool, result
 			spell.SpellMetrics[result.Target.UnitIndex].TotalBlockDamage += result.Damage
 		}
 		spell.SpellMetrics[result.Target.UnitIndex].TotalThreat += result.Threat
+
+		if timeline := spell.Unit.Metrics.timeline; timeline != nil && spell.Unit.IsOpponent(result.Target) {
+			timeline.addDamage(sim, result.Damage, result.Threat)
+		}
 	}
 
 	// Mark total damage done in raid so far for health based fights.
 	// Don't include damage done by EnemyUnits to Players
 	if result.Target.Type == EnemyUnit {
-		sim.Encounter.DamageTaken += result.Damage
+		sim.Encounter.addDamageTaken(sim, result.Target, result.Damage)
 	}
 
 	if sim.Log != nil && !spell.Flags.Matches(SpellFlagNoLogs) {
@@ -434,6 +440,7 @@ func (spell *Spell) dealDamageInternal(sim *Simulation, isPeriodic bool, result
 		} else {
 			spell.Unit.Log(sim, "%s %s %s (SpellSchool: %d). (Threat: %0.3f)", result.Target.LogLabel(), spell.ActionID, result.DamageString(), spell.SpellSchool, result.Threat)
 		}
+		spell.emitResultEvent(sim, proto.CombatEventType_CombatEventDamage, isPeriodic, result)
 	}
 
 	if !spell.Flags.Matches(SpellFlagNoOnDamageDealt) {
@@ -532,6 +539,9 @@ func (spell *Spell) dealHealingInternal(sim *Simulation, isPeriodic bool, result
 	}
 	spell.SpellMetrics[result.Target.UnitIndex].TotalHealing += result.Damage
 	spell.SpellMetrics[result.Target.UnitIndex].TotalThreat += result.Threat
+	if timeline := spell.Unit.Metrics.timeline; timeline != nil && !spell.Unit.IsOpponent(result.Target) {
+		timeline.addHealing(sim, result.Damage)
+	}
 	if result.Target.HasHealthBar() {
 		result.Target.GainHealth(sim, result.Damage, spell.HealthMetrics(result.Target))
 	}
@@ -542,6 +552,7 @@ func (spell *Spell) dealHealingInternal(sim *Simulation, isPeriodic bool, result
 		} else {
 			spell.Unit.Log(sim, "%s %s %s. (Threat: %0.3f)", result.Target.LogLabel(), spell.ActionID, result.HealingString(), result.Threat)
 		}
+		spell.emitResultEvent(sim, proto.CombatEventType_CombatEventHeal, isPeriodic, result)
 	}
 
 	if isPeriodic {
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"math"
 	"runtime"
 	"sync"
@@ -78,6 +79,12 @@ type StatWeightsResult struct {
 	Dtps   StatWeightValues
 	Tmi    StatWeightValues
 	PDeath StatWeightValues
+
+	Baseline      *proto.UnitMetrics
+	Cancelled     bool
+	Iterations    int32
+	RelativeError float64
+	ErrorResult   string
 }
 
 func NewStatWeightsResult() *StatWeightsResult {
@@ -99,10 +106,19 @@ func (swr *StatWeightsResult) ToProto() *proto.StatWeightsResult {
 		Dtps:   swr.Dtps.ToProto(),
 		Tmi:    swr.Tmi.ToProto(),
 		PDeath: swr.PDeath.ToProto(),
+
+		Baseline:      swr.Baseline,
+		Cancelled:     swr.Cancelled,
+		Iterations:    swr.Iterations,
+		RelativeError: swr.RelativeError,
+		ErrorResult:   swr.ErrorResult,
 	}
 }
 
-func CalcStatWeight(swr *proto.StatWeightsRequest, referenceStat stats.Stat, progress chan *proto.ProgressMetrics) *StatWeightsResult {
+// CalcStatWeight runs a baseline sim plus one sim above and below the baseline
+// for every weighed stat. If ctx is cancelled, no further sims are started and
+// the result is flagged as cancelled, with weights for the stats whose sims completed.
+func CalcStatWeight(ctx context.Context, swr *proto.StatWeightsRequest, referenceStat stats.Stat, progress chan *proto.ProgressMetrics) *StatWeightsResult {
 	if swr.Player.BonusStats == nil {
 		swr.Player.BonusStats = &proto.UnitStats{}
 	}
@@ -122,6 +138,7 @@ func CalcStatWeight(swr *proto.StatWeightsRequest, referenceStat stats.Stat, pro
 	// Cut in half since we're doing above and below separately.
 	// This number needs to be the same for the baseline sim too, so that RNG lines up perfectly.
 	simOptions.Iterations /= 2
+	simOptions.MaxIterations /= 2
 
 	// Make sure an RNG seed is always set because it gives more consistent results.
 	// When there is no user-supplied seed it needs to be a randomly-selected seed
@@ -143,12 +160,27 @@ func CalcStatWeight(swr *proto.StatWeightsRequest, referenceStat stats.Stat, pro
 		Encounter:  swr.Encounter,
 		SimOptions: simOptions,
 	}
-	baselineResult := RunRaidSim(baseSimRequest)
+	baselineResult := RunRaidSim(ctx, baseSimRequest)
 	if baselineResult.ErrorResult != "" {
-		// TODO: get stack trace out.
-		return &StatWeightsResult{}
+		return &StatWeightsResult{ErrorResult: baselineResult.ErrorResult}
+	}
+	baselinePlayer := baselineResult.RaidMetrics.Parties[0].Players[0]
+
+	result := NewStatWeightsResult()
+	result.Baseline = googleProto.Clone(baselinePlayer).(*proto.UnitMetrics)
+	clearUnitSamples(result.Baseline)
+	result.Iterations = baselineResult.Iterations
+	result.RelativeError = baselineResult.RelativeError
+	if ctx.Err() != nil {
+		result.Cancelled = true
+		return result
 	}
 
+	// With a target error, the baseline decides how many iterations to run. All other sims
+	// then run exactly as many, so that their iterations can be compared one by one.
+	simOptions.Iterations = baselineResult.Iterations
+	simOptions.TargetRelativeError = 0
+
 	var waitGroup sync.WaitGroup
 
 	// Do half the iterations with a positive, and half with a negative value for better accuracy.
@@ -174,12 +206,16 @@ func CalcStatWeight(swr *proto.StatWeightsRequest, referenceStat stats.Stat, pro
 		defer waitGroup.Done()
 		// wait until we have CPU time available.
 		<-tickets
+		if ctx.Err() != nil {
+			tickets <- struct{}{}
+			return
+		}
 
 		simRequest := googleProto.Clone(baseSimRequest).(*proto.RaidSimRequest)
 		stat.AddToStatsProto(simRequest.Raid.Parties[0].Players[0].BonusStats, value)
 
 		reporter := make(chan *proto.ProgressMetrics, 10)
-		go RunSim(simRequest, reporter) // RunRaidSim(simRequest)
+		go RunSim(ctx, simRequest, reporter) // RunRaidSim(simRequest)
 
 		var localIterations int32
 		var errorStr string
@@ -260,15 +296,15 @@ func CalcStatWeight(swr *proto.StatWeightsRequest, referenceStat stats.Stat, pro
 	// Wait for thread results.
 	waitGroup.Wait()
 
-	// Compute weight results.
-	result := NewStatWeightsResult()
+	// Compute weight results. When cancelled, sims may have been skipped or stopped early,
+	// and only the stats whose sims both completed can be compared against the baseline.
+	result.Cancelled = ctx.Err() != nil
 	for i := 0; i < stats.UnitStatsLen; i++ {
 		stat := stats.UnitStatFromIdx(i)
-		if resultsLow[stat] == nil && resultsHigh[stat] == nil {
+		if resultsLow[stat] == nil || resultsHigh[stat] == nil || resultsLow[stat].Cancelled || resultsHigh[stat].Cancelled {
 			continue
 		}
 
-		baselinePlayer := baselineResult.RaidMetrics.Parties[0].Players[0]
 		modPlayerLow := resultsLow[stat].RaidMetrics.Parties[0].Players[0]
 		modPlayerHigh := resultsHigh[stat].RaidMetrics.Parties[0].Players[0]
 
@@ -25,6 +25,12 @@ type Encounter struct {
 	// In health fight: set to true until we get something to base on
 	DurationIsEstimate bool
 
+	// Duration of each iteration, which is the time to kill all targets in health fights.
+	fightDuration DistributionMetrics
+	// Only set in health fights, indexed by target.
+	targetKills []targetKillMetrics
+	numKilled   int32 // Number of targets killed so far in the current iteration.
+
 	// Value to multiply by, for damage spells which are subject to the aoe cap.
 	aoeCapMultiplier float64
 }
@@ -40,6 +46,7 @@ func NewEncounter(options *proto.Encounter) Encounter {
 		ExecuteProportion_25: max(options.ExecuteProportion_25, 0),
 		ExecuteProportion_35: max(options.ExecuteProportion_35, 0),
 		Targets:              []*Target{},
+		fightDuration:        NewDistributionMetrics(),
 	}
 	// If UseHealth is set, we use the sum of targets health.
 	if options.UseHealth {
@@ -68,6 +75,14 @@ func NewEncounter(options *proto.Encounter) Encounter {
 		// Until we pre-sim set duration to 10m
 		encounter.Duration = time.Minute * 10
 		encounter.DurationIsEstimate = true
+
+		for _, target := range encounter.Targets {
+			encounter.targetKills = append(encounter.targetKills, targetKillMetrics{
+				health:     target.stats[stats.Health],
+				killTimes:  NewDistributionMetrics(),
+				killOrders: make([]int32, len(encounter.Targets)),
+			})
+		}
 	}
 
 	encounter.updateAOECapMultiplier()
@@ -82,11 +97,65 @@ func (encounter *Encounter) updateAOECapMultiplier() {
 	encounter.aoeCapMultiplier = min(10/float64(len(encounter.Targets)), 1)
 }
 
-func (encounter *Encounter) doneIteration(sim *Simulation) {
+func (encounter *Encounter) reset() {
+	// Reset primary targets damage taken for tracking health fights.
+	encounter.DamageTaken = 0
+
+	encounter.numKilled = 0
+	for i := range encounter.targetKills {
+		encounter.targetKills[i].damageTaken = 0
+		encounter.targetKills[i].killed = false
+	}
+}
+
+// Adds damage taken by a target, for tracking health fights.
+func (encounter *Encounter) addDamageTaken(sim *Simulation, target *Unit, damage float64) {
+	encounter.DamageTaken += damage
+
+	if len(encounter.targetKills) == 0 {
+		return
+	}
+	targetKills := &encounter.targetKills[target.Index]
+	targetKills.damageTaken += damage
+	if !targetKills.killed && targetKills.damageTaken >= targetKills.health {
+		encounter.markKilled(targetKills, sim.CurrentTime)
+	}
+}
+
+func (encounter *Encounter) markKilled(targetKills *targetKillMetrics, killTime time.Duration) {
+	encounter.numKilled++
+	targetKills.killed = true
+	targetKills.killTime = killTime
+	targetKills.killOrder = encounter.numKilled
+}
+
+// fightDuration is the actual length of the iteration, which in health fights can differ from sim.Duration.
+func (encounter *Encounter) doneIteration(sim *Simulation, fightDuration time.Duration) {
 	for i := range encounter.Targets {
 		target := encounter.Targets[i]
 		target.doneIteration(sim)
 	}
+
+	encounter.fightDuration.addSample(sim, fightDuration.Seconds())
+
+	for i := range encounter.targetKills {
+		targetKills := &encounter.targetKills[i]
+		if !targetKills.killed {
+			// The fight ends once the targets took their combined health worth of damage,
+			// which may not have been spread exactly over their individual health.
+			encounter.markKilled(targetKills, fightDuration)
+		}
+		targetKills.killTimes.addSample(sim, targetKills.killTime.Seconds())
+		targetKills.killOrders[targetKills.killOrder-1]++
+	}
+}
+
+// Adds the aggregated metrics of the same encounter in another Environment.
+func (encounter *Encounter) mergeMetrics(other *Encounter) {
+	encounter.fightDuration.merge(&other.fightDuration)
+	for i := range encounter.targetKills {
+		encounter.targetKills[i].merge(&other.targetKills[i])
+	}
 }
 
 func (encounter *Encounter) GetMetricsProto() *proto.EncounterMetrics {
@@ -100,6 +169,49 @@ func (encounter *Encounter) GetMetricsProto() *proto.EncounterMetrics {
 		i++
 	}
 
+	if encounter.fightDuration.n > 0 {
+		metrics.FightDuration = encounter.fightDuration.ToProto()
+	}
+	for i := range encounter.targetKills {
+		metrics.TargetKills = append(metrics.TargetKills, encounter.targetKills[i].ToProto(encounter.Targets[i].UnitIndex))
+	}
+
+	return metrics
+}
+
+// Time and order in which a target dies, for health fights.
+type targetKillMetrics struct {
+	health float64
+
+	// Values for the current iteration.
+	damageTaken float64
+	killed      bool
+	killTime    time.Duration
+	killOrder   int32 // 1 for the first target to die.
+
+	// Aggregate values. These are updated after each iteration.
+	killTimes  DistributionMetrics
+	killOrders []int32 // Number of iterations in which this target was the (i+1)th to die.
+}
+
+func (targetKills *targetKillMetrics) merge(other *targetKillMetrics) {
+	targetKills.killTimes.merge(&other.killTimes)
+	for i, count := range other.killOrders {
+		targetKills.killOrders[i] += count
+	}
+}
+
+func (targetKills *targetKillMetrics) ToProto(unitIndex int32) *proto.TargetKillMetrics {
+	n := float64(targetKills.killTimes.n)
+	metrics := &proto.TargetKillMetrics{
+		UnitIndex: unitIndex,
+		KillTime:  targetKills.killTimes.ToProto(),
+		KillOrder: make([]float64, len(targetKills.killOrders)),
+	}
+	for i, count := range targetKills.killOrders {
+		metrics.KillOrder[i] = float64(count) / n
+		metrics.AvgKillOrder += float64(i+1) * float64(count) / n
+	}
 	return metrics
 }
 
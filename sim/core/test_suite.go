@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -37,7 +38,7 @@ func NewIndividualTestSuite(suiteName string) *IndividualTestSuite {
 func (testSuite *IndividualTestSuite) TestCharacterStats(testName string, csr *proto.ComputeStatsRequest) {
 	testSuite.testNames = append(testSuite.testNames, testName)
 
-	result := ComputeStats(csr)
+	result := ComputeStats(context.Background(), csr)
 	finalStats := stats.FromFloatArray(result.RaidStats.Parties[0].Players[0].FinalStats.Stats)
 
 	testSuite.testResults.CharacterStatsResults[testName] = &proto.CharacterStatsTestResult{
@@ -48,7 +49,7 @@ func (testSuite *IndividualTestSuite) TestCharacterStats(testName string, csr *p
 func (testSuite *IndividualTestSuite) TestStatWeights(testName string, swr *proto.StatWeightsRequest) {
 	testSuite.testNames = append(testSuite.testNames, testName)
 
-	result := StatWeights(swr)
+	result := StatWeights(context.Background(), swr)
 	weights := stats.FromFloatArray(result.Dps.Weights.Stats)
 
 	testSuite.testResults.StatWeightsResults[testName] = &proto.StatWeightsTestResult{
@@ -59,7 +60,7 @@ func (testSuite *IndividualTestSuite) TestStatWeights(testName string, swr *prot
 func (testSuite *IndividualTestSuite) TestDPS(testName string, rsr *proto.RaidSimRequest) {
 	testSuite.testNames = append(testSuite.testNames, testName)
 
-	result := RunRaidSim(rsr)
+	result := RunRaidSim(context.Background(), rsr)
 	if result.Logs != "" {
 		fmt.Printf("LOGS: %s\n", result.Logs)
 	}
@@ -76,7 +77,7 @@ func (testSuite *IndividualTestSuite) TestDPS(testName string, rsr *proto.RaidSi
 
 func (testSuite *IndividualTestSuite) TestCasts(testName string, rsr *proto.RaidSimRequest) {
 	testSuite.testNames = append(testSuite.testNames, testName)
-	result := RunRaidSim(rsr)
+	result := RunRaidSim(context.Background(), rsr)
 	if result.Logs != "" {
 		fmt.Printf("LOGS: %s\n", result.Logs)
 	}
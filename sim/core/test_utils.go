@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"log"
 	"os"
 	"testing"
@@ -505,7 +506,7 @@ func CharacterStatsTest(label string, t *testing.T, raid *proto.Raid, expectedSt
 		Raid: raid,
 	}
 
-	result := ComputeStats(csr)
+	result := ComputeStats(context.Background(), csr)
 	finalStats := stats.FromFloatArray(result.RaidStats.Parties[0].Players[0].FinalStats.Stats)
 
 	const tolerance = 0.5
@@ -521,7 +522,7 @@ func StatWeightsTest(label string, t *testing.T, _swr *proto.StatWeightsRequest,
 	swr.Encounter.Duration = LongDuration
 	swr.SimOptions.Iterations = 5000
 
-	result := StatWeights(swr)
+	result := StatWeights(context.Background(), swr)
 	resultWeights := stats.FromFloatArray(result.Dps.Weights.Stats)
 
 	const tolerance = 0.05
@@ -531,7 +532,7 @@ func StatWeightsTest(label string, t *testing.T, _swr *proto.StatWeightsRequest,
 }
 
 func RaidSimTest(label string, t *testing.T, rsr *proto.RaidSimRequest, expectedDps float64) {
-	result := RunRaidSim(rsr)
+	result := RunRaidSim(context.Background(), rsr)
 	if result.ErrorResult != "" {
 		t.Fatalf("Sim failed with error: %s", result.ErrorResult)
 	}
@@ -553,7 +554,7 @@ func RaidBenchmark(b *testing.B, rsr *proto.RaidSimRequest) {
 	rsr.SimOptions.IsTest = false
 
 	for i := 0; i < b.N; i++ {
-		result := RunRaidSim(rsr)
+		result := RunRaidSim(context.Background(), rsr)
 		if result.ErrorResult != "" {
 			b.Fatalf("RaidBenchmark() at iteration %d failed: %v", i, result.ErrorResult)
 		}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+)
+
+// unitTimeline aggregates the metrics of a unit over time, in bins of fixed
+// width. All values are sums across iterations, and are averaged over the
+// iterations which lasted into each bin when converted to proto.
+type unitTimeline struct {
+	binWidth time.Duration
+
+	iterations []int32 // Number of iterations which lasted into each bin.
+	damage     []float64
+	healing    []float64
+	threat     []float64
+	resources  []*resourceTimeline
+	auras      []*auraTimeline // Only for the auras selected in SimOptions.
+}
+
+type resourceTimeline struct {
+	resourceType proto.ResourceType
+	levels       []float64 // Resource level at the start of each bin.
+}
+
+type auraTimeline struct {
+	actionID ActionID
+	uptime   []float64 // Seconds the aura was active within each bin.
+	stacks   []float64 // Stack-seconds within each bin.
+}
+
+func newUnitTimeline(unit *Unit, options *proto.SimOptions) *unitTimeline {
+	timeline := &unitTimeline{
+		binWidth: DurationFromSeconds(options.TimelineBinSeconds),
+	}
+
+	if unit.HasManaBar() {
+		timeline.resources = append(timeline.resources, &resourceTimeline{resourceType: proto.ResourceType_ResourceTypeMana})
+	}
+	if unit.HasRageBar() {
+		timeline.resources = append(timeline.resources, &resourceTimeline{resourceType: proto.ResourceType_ResourceTypeRage})
+	}
+	if unit.HasEnergyBar() {
+		timeline.resources = append(timeline.resources, &resourceTimeline{resourceType: proto.ResourceType_ResourceTypeEnergy})
+	}
+
+	for _, auraID := range options.TimelineAuras {
+		timeline.auras = append(timeline.auras, &auraTimeline{actionID: ProtoToActionID(auraID)})
+	}
+
+	return timeline
+}
+
+// Returns values with room for bin idx.
+func growTimeline(values []float64, idx int) []float64 {
+	if idx < len(values) {
+		return values
+	}
+	return append(values, make([]float64, idx+1-len(values))...)
+}
+
+func (timeline *unitTimeline) bin(t time.Duration) int {
+	return int(t / timeline.binWidth)
+}
+
+func (timeline *unitTimeline) addDamage(sim *Simulation, damage float64, threat float64) {
+	if sim.CurrentTime < 0 {
+		// Prepull damage is only part of the totals.
+		return
+	}
+	idx := timeline.bin(sim.CurrentTime)
+	timeline.damage = growTimeline(timeline.damage, idx)
+	timeline.damage[idx] += damage
+	timeline.threat = growTimeline(timeline.threat, idx)
+	timeline.threat[idx] += threat
+}
+
+func (timeline *unitTimeline) addHealing(sim *Simulation, healing float64) {
+	if sim.CurrentTime < 0 {
+		// Prepull healing is only part of the totals.
+		return
+	}
+	idx := timeline.bin(sim.CurrentTime)
+	timeline.healing = growTimeline(timeline.healing, idx)
+	timeline.healing[idx] += healing
+}
+
+// Adds the seconds from start to end, multiplied by weight, to the bins they fall into.
+func (timeline *unitTimeline) addSeconds(values []float64, start time.Duration, end time.Duration, weight float64) []float64 {
+	for t := start; t < end; {
+		idx := timeline.bin(t)
+		binEnd := min(end, time.Duration(idx+1)*timeline.binWidth)
+		values = growTimeline(values, idx)
+		values[idx] += (binEnd - t).Seconds() * weight
+		t = binEnd
+	}
+	return values
+}
+
+// Adds the uptime of an aura which was active from start to end, if the aura is tracked.
+func (timeline *unitTimeline) addAuraUptime(actionID ActionID, start time.Duration, end time.Duration) {
+	for _, aura := range timeline.auras {
+		if aura.actionID == actionID {
+			aura.uptime = timeline.addSeconds(aura.uptime, start, end, 1)
+		}
+	}
+}
+
+// Adds the stacks of an aura which had them from start to end, if the aura is tracked.
+func (timeline *unitTimeline) addAuraStacks(actionID ActionID, stacks int32, start time.Duration, end time.Duration) {
+	for _, aura := range timeline.auras {
+		if aura.actionID == actionID {
+			aura.stacks = timeline.addSeconds(aura.stacks, start, end, float64(stacks))
+		}
+	}
+}
+
+// Samples resource levels at the start of each bin, for the rest of the iteration.
+func (timeline *unitTimeline) startPull(sim *Simulation, unit *Unit) {
+	if len(timeline.resources) == 0 {
+		return
+	}
+
+	StartPeriodicAction(sim, PeriodicActionOptions{
+		Period:          timeline.binWidth,
+		TickImmediately: true,
+		OnAction: func(sim *Simulation) {
+			if sim.CurrentTime >= sim.endOfCombatDuration {
+				return
+			}
+			idx := timeline.bin(sim.CurrentTime)
+			for _, resource := range timeline.resources {
+				resource.levels = growTimeline(resource.levels, idx)
+				switch resource.resourceType {
+				case proto.ResourceType_ResourceTypeMana:
+					resource.levels[idx] += unit.CurrentMana()
+				case proto.ResourceType_ResourceTypeRage:
+					resource.levels[idx] += unit.CurrentRage()
+				case proto.ResourceType_ResourceTypeEnergy:
+					resource.levels[idx] += unit.CurrentEnergy()
+				}
+			}
+		},
+	})
+}
+
+// Counts the iteration in each bin up to the end of the fight, which is before
+// the Duration in health fights.
+func (timeline *unitTimeline) doneIteration(fightDuration time.Duration) {
+	numBins := int((fightDuration + timeline.binWidth - 1) / timeline.binWidth)
+	if numBins > len(timeline.iterations) {
+		timeline.iterations = append(timeline.iterations, make([]int32, numBins-len(timeline.iterations))...)
+	}
+	for i := 0; i < numBins; i++ {
+		timeline.iterations[i]++
+	}
+}
+
+func mergeTimelineValues(values []float64, other []float64) []float64 {
+	if len(other) > 0 {
+		values = growTimeline(values, len(other)-1)
+	}
+	for i, v := range other {
+		values[i] += v
+	}
+	return values
+}
+
+// Adds the values of the same unit's timeline in another Environment.
+func (timeline *unitTimeline) merge(other *unitTimeline) {
+	if len(other.iterations) > len(timeline.iterations) {
+		timeline.iterations = append(timeline.iterations, make([]int32, len(other.iterations)-len(timeline.iterations))...)
+	}
+	for i, n := range other.iterations {
+		timeline.iterations[i] += n
+	}
+
+	timeline.damage = mergeTimelineValues(timeline.damage, other.damage)
+	timeline.healing = mergeTimelineValues(timeline.healing, other.healing)
+	timeline.threat = mergeTimelineValues(timeline.threat, other.threat)
+	for i, resource := range timeline.resources {
+		resource.levels = mergeTimelineValues(resource.levels, other.resources[i].levels)
+	}
+	for i, aura := range timeline.auras {
+		aura.uptime = mergeTimelineValues(aura.uptime, other.auras[i].uptime)
+		aura.stacks = mergeTimelineValues(aura.stacks, other.auras[i].stacks)
+	}
+}
+
+// Returns the average of the values in each bin, across the iterations which lasted into it, divided by scale.
+func (timeline *unitTimeline) averages(values []float64, scale float64) []float64 {
+	averages := make([]float64, len(timeline.iterations))
+	for i, n := range timeline.iterations {
+		if i < len(values) && n > 0 {
+			averages[i] = values[i] / float64(n) / scale
+		}
+	}
+	return averages
+}
+
+func (timeline *unitTimeline) ToProto() *proto.UnitTimeline {
+	binSeconds := timeline.binWidth.Seconds()
+	timelineProto := &proto.UnitTimeline{
+		BinSeconds: binSeconds,
+		Iterations: timeline.iterations,
+		Dps:        timeline.averages(timeline.damage, binSeconds),
+		Hps:        timeline.averages(timeline.healing, binSeconds),
+		Tps:        timeline.averages(timeline.threat, binSeconds),
+	}
+
+	for _, resource := range timeline.resources {
+		timelineProto.Resources = append(timelineProto.Resources, &proto.ResourceTimeline{
+			Type:   resource.resourceType,
+			Levels: timeline.averages(resource.levels, 1),
+		})
+	}
+	for _, aura := range timeline.auras {
+		auraProto := &proto.AuraTimeline{
+			Id:     aura.actionID.ToProto(),
+			Uptime: timeline.averages(aura.uptime, binSeconds),
+		}
+		if len(aura.stacks) > 0 {
+			auraProto.Stacks = timeline.averages(aura.stacks, binSeconds)
+		}
+		timelineProto.Auras = append(timelineProto.Auras, auraProto)
+	}
+
+	return timelineProto
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnitTimelineAuraUptime(t *testing.T) {
+	auraID := ActionID{SpellID: 1}
+	timeline := &unitTimeline{
+		binWidth: time.Second,
+		auras:    []*auraTimeline{{actionID: auraID}},
+	}
+	sim := &Simulation{Duration: time.Second * 3}
+
+	timeline.addAuraUptime(auraID, time.Millisecond*500, time.Millisecond*2250)
+	timeline.addAuraUptime(ActionID{SpellID: 2}, 0, time.Second*3)
+	timeline.doneIteration(sim.Duration)
+
+	uptime := timeline.ToProto().Auras[0].Uptime
+	expected := []float64{0.5, 1, 0.25}
+	for i := range expected {
+		if uptime[i] != expected[i] {
+			t.Fatalf("Aura uptime %v, expected %v", uptime, expected)
+		}
+	}
+}
+
+func TestUnitTimelineMerge(t *testing.T) {
+	first := &unitTimeline{binWidth: time.Second * 2}
+	second := &unitTimeline{binWidth: time.Second * 2}
+	sim := &Simulation{}
+
+	sim.Duration = time.Second * 4
+	sim.CurrentTime = time.Second
+	first.addDamage(sim, 100, 50)
+	first.doneIteration(sim.Duration)
+
+	sim.Duration = time.Second * 6
+	sim.CurrentTime = time.Second * 5
+	second.addDamage(sim, 300, 0)
+	second.doneIteration(sim.Duration)
+
+	first.merge(second)
+	result := first.ToProto()
+
+	// The first bin is averaged over both iterations, the last only over the second.
+	if expected := []int32{2, 2, 1}; len(result.Iterations) != 3 || result.Iterations[0] != expected[0] || result.Iterations[2] != expected[2] {
+		t.Fatalf("Iterations %v, expected %v", result.Iterations, expected)
+	}
+	if expected := []float64{25, 0, 150}; result.Dps[0] != expected[0] || result.Dps[1] != expected[1] || result.Dps[2] != expected[2] {
+		t.Fatalf("Dps %v, expected %v", result.Dps, expected)
+	}
+	if result.Tps[0] != 12.5 {
+		t.Fatalf("Tps %v, expected 12.5 in the first bin", result.Tps)
+	}
+}
+
+func TestUnitTimelineAuraStacks(t *testing.T) {
+	auraID := ActionID{SpellID: 1}
+	timeline := &unitTimeline{
+		binWidth: time.Second,
+		auras:    []*auraTimeline{{actionID: auraID}},
+	}
+	sim := &Simulation{Duration: time.Second * 2}
+
+	timeline.addAuraStacks(auraID, 1, 0, time.Millisecond*500)
+	timeline.addAuraStacks(auraID, 3, time.Millisecond*500, time.Millisecond*1500)
+	timeline.doneIteration(sim.Duration)
+
+	stacks := timeline.ToProto().Auras[0].Stacks
+	expected := []float64{2, 1.5}
+	for i := range expected {
+		if stacks[i] != expected[i] {
+			t.Fatalf("Aura stacks %v, expected %v", stacks, expected)
+		}
+	}
+}
+
+func TestUnitTimelineSkipsPrepullDamageAndHealing(t *testing.T) {
+	timeline := &unitTimeline{binWidth: time.Second}
+	sim := &Simulation{Duration: time.Second * 2}
+
+	sim.CurrentTime = -time.Second * 2
+	timeline.addDamage(sim, 500, 500)
+	timeline.addHealing(sim, 500)
+	sim.CurrentTime = time.Second
+	timeline.addDamage(sim, 100, 0)
+	timeline.addHealing(sim, 50)
+	timeline.doneIteration(time.Millisecond * 1500)
+
+	result := timeline.ToProto()
+	if expected := []float64{0, 100}; len(result.Dps) != 2 || result.Dps[0] != expected[0] || result.Dps[1] != expected[1] {
+		t.Fatalf("Dps %v, expected %v", result.Dps, expected)
+	}
+	if expected := []float64{0, 50}; len(result.Hps) != 2 || result.Hps[0] != expected[0] || result.Hps[1] != expected[1] {
+		t.Fatalf("Hps %v, expected %v", result.Hps, expected)
+	}
+}
@@ -114,6 +114,9 @@ type Unit struct {
 	Spellbook                 []*Spell
 	spellRegistrationHandlers []SpellRegisteredHandler
 
+	// Source of the spells registered right now, see withSpellSource().
+	spellSource proto.SpellSource
+
 	// Pets owned by this Unit.
 	PetAgents []PetAgent
 
@@ -532,6 +535,15 @@ func (unit *Unit) reset(sim *Simulation, _ Agent) {
 	unit.Hardcast.Expires = startingCDTime
 	unit.ChanneledDot = nil
 	unit.Metrics.reset()
+	if sim.Options.TimelineBinSeconds > 0 && unit.Metrics.timeline == nil {
+		unit.Metrics.timeline = newUnitTimeline(unit, sim.Options)
+	}
+	if len(sim.Options.OverlapAuras) > 0 && unit.Metrics.auraOverlaps == nil {
+		unit.Metrics.auraOverlaps = newAuraOverlaps(sim.Options.OverlapAuras)
+	}
+	if unit.Type == PlayerUnit && unit.Rotation != nil && unit.Metrics.apl == nil {
+		unit.Metrics.apl = unit.Rotation.newAPLMetrics()
+	}
 	unit.ResetStatDeps()
 	unit.statsWithoutDeps = unit.initialStatsWithoutDeps
 	unit.stats = unit.initialStats
@@ -569,6 +581,10 @@ func (unit *Unit) reset(sim *Simulation, _ Agent) {
 func (unit *Unit) startPull(sim *Simulation) {
 	unit.AutoAttacks.startPull(sim)
 
+	if unit.Metrics.timeline != nil {
+		unit.Metrics.timeline.startPull(sim, unit)
+	}
+
 	if unit.Type == PlayerUnit {
 		unit.SetGCDTimer(sim, max(0, unit.GCD.ReadyAt()))
 	}
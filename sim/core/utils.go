@@ -192,3 +192,22 @@ func (x *aggregator) meanAndStdDev() (float64, float64) {
 	stdDev := math.Sqrt(x.sumSq/float64(x.n) - mean*mean)
 	return mean, stdDev
 }
+
+// Returns the standard error of the mean, relative to the mean.
+func (x *aggregator) relativeStdError() float64 {
+	if x.n < 2 {
+		return math.Inf(1)
+	}
+	mean, stdDev := x.meanAndStdDev()
+	if math.IsNaN(stdDev) {
+		// Rounding can make the variance of identical values slightly negative.
+		stdDev = 0
+	}
+	if mean == 0 {
+		if stdDev == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return stdDev / math.Sqrt(float64(x.n)) / math.Abs(mean)
+}
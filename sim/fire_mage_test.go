@@ -0,0 +1,52 @@
+package sim
+
+import (
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+)
+
+// fireMageRaidSimRequest returns a 2 minute sim of a fire mage against a single demon.
+// A nil rotation or equipment uses the p4 fire presets, and tests override the rest as needed.
+func fireMageRaidSimRequest(rotation *proto.APLRotation, equipment *proto.EquipmentSpec) *proto.RaidSimRequest {
+	if rotation == nil {
+		rotation = core.GetAplRotation("../ui/mage/apls", "p4_fire").Rotation
+	}
+	if equipment == nil {
+		equipment = core.GetGearSet("../ui/mage/gear_sets", "p4_fire").GearSet
+	}
+
+	return &proto.RaidSimRequest{
+		Raid: core.SinglePlayerRaidProto(&proto.Player{
+			Name:          "Fire Mage",
+			Race:          proto.Race_RaceTroll,
+			Class:         proto.Class_ClassMage,
+			Level:         60,
+			Equipment:     equipment,
+			Rotation:      rotation,
+			TalentsString: "21-5052300123033151-203500031",
+			Spec: &proto.Player_Mage{
+				Mage: &proto.Mage{
+					Options: &proto.Mage_Options{
+						Armor: proto.Mage_Options_MoltenArmor,
+					},
+				},
+			},
+			Consumes: &proto.Consumes{},
+			Buffs:    &proto.IndividualBuffs{},
+		}, &proto.PartyBuffs{}, &proto.RaidBuffs{}, &proto.Debuffs{}),
+		Encounter: &proto.Encounter{
+			Duration: 120,
+			Targets: []*proto.Target{
+				{
+					Level:   63,
+					MobType: proto.MobType_MobTypeDemon,
+					Stats:   stats.Stats{stats.Armor: 3731}.ToFloatArray(),
+				},
+			},
+		},
+		SimOptions: &proto.SimOptions{
+			RandomSeed: 101,
+		},
+	}
+}
@@ -1,7 +1,12 @@
 package sim
 
 import (
+	"fmt"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core"
 	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
 )
 
 // 1 moonkin, 1 ele shaman, 1 spriest, 2x arcane
@@ -147,6 +152,98 @@ var castersWithResto = &proto.Party{
 	},
 }
 
+// Full 40-man raid of level 60 fire mages, destruction warlocks with imps and marksmanship
+// hunters with pets. The casters above use gear which isn't in the database.
+func fullRaid() *proto.Raid {
+	players := []*proto.Player{
+		fireMageRaidSimRequest(nil, nil).Raid.Parties[0].Players[0],
+		{
+			Name:          "Destruction Warlock",
+			Race:          proto.Race_RaceOrc,
+			Class:         proto.Class_ClassWarlock,
+			Level:         60,
+			Equipment:     core.GetGearSet("../ui/warlock/gear_sets/p4", "destruction").GearSet,
+			Rotation:      core.GetAplRotation("../ui/warlock/apls/p4", "destruction").Rotation,
+			TalentsString: "05002-035004-5050205102005151",
+			Spec: &proto.Player_Warlock{
+				Warlock: &proto.Warlock{
+					Options: &proto.WarlockOptions{
+						Armor:  proto.WarlockOptions_FelArmor,
+						Summon: proto.WarlockOptions_Imp,
+					},
+				},
+			},
+			Consumes: &proto.Consumes{},
+			Buffs:    &proto.IndividualBuffs{},
+		},
+		{
+			Name:          "Marksmanship Hunter",
+			Race:          proto.Race_RaceOrc,
+			Class:         proto.Class_ClassHunter,
+			Level:         60,
+			Equipment:     core.GetGearSet("../ui/hunter/gear_sets", "p4_ranged").GearSet,
+			Rotation:      core.GetAplRotation("../ui/hunter/apls", "p4_ranged").Rotation,
+			TalentsString: "-05451002503051-33400023023",
+			Spec: &proto.Player_Hunter{
+				Hunter: &proto.Hunter{
+					Options: &proto.Hunter_Options{
+						Ammo:           proto.Hunter_Options_JaggedArrow,
+						PetType:        proto.Hunter_Options_Cat,
+						PetUptime:      1,
+						PetAttackSpeed: 2.0,
+					},
+				},
+			},
+			Consumes: &proto.Consumes{},
+			Buffs:    &proto.IndividualBuffs{},
+		},
+	}
+
+	raid := &proto.Raid{}
+	for partyIdx := 0; partyIdx < 8; partyIdx++ {
+		party := &proto.Party{
+			Buffs: &proto.PartyBuffs{},
+		}
+		for i := 0; i < 5; i++ {
+			raidIdx := partyIdx*5 + i
+			player := googleProto.Clone(players[raidIdx%len(players)]).(*proto.Player)
+			player.Name = fmt.Sprintf("%s (%d)", player.Name, raidIdx+1)
+			party.Players = append(party.Players, player)
+		}
+		raid.Parties = append(raid.Parties, party)
+	}
+	return raid
+}
+
+// Schedules pending actions for 40 players along with their pets, DoTs and totems.
+// The sim is built once, so the timed iterations are spent running the event loop.
+func BenchmarkSimulate40ManRaid(b *testing.B) {
+	rsr := &proto.RaidSimRequest{
+		Raid: fullRaid(),
+		Encounter: &proto.Encounter{
+			Duration: core.LongDuration,
+			Targets: []*proto.Target{
+				StandardTarget,
+			},
+		},
+		SimOptions: &proto.SimOptions{
+			Iterations: 1,
+			RandomSeed: 1,
+		},
+	}
+
+	sim := core.NewSim(rsr)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sim.Reseed(int64(i))
+		sim.Reset()
+		sim.PrePull()
+		for !sim.Step() {
+		}
+		sim.Cleanup()
+	}
+}
+
 // P3 gear for each class
 
 // Shadow Priest Equipment
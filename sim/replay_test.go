@@ -0,0 +1,67 @@
+package sim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+// Replaying the seed of an iteration should reproduce its damage and events exactly.
+func TestReplaySeedReproducesIteration(t *testing.T) {
+	const iterations = 20
+	const replayedIteration = 7
+
+	rsr := fireMageRaidSimRequest(nil, nil)
+	rsr.Encounter.DurationVariation = 10
+	rsr.SimOptions.Iterations = iterations
+	rsr.SimOptions.SaveAllValues = true
+	rsr.SimOptions.CombatEvents = &proto.CombatEventOptions{Iteration: replayedIteration}
+
+	run := func(request *proto.RaidSimRequest) *proto.RaidSimResult {
+		result := core.RunRaidSim(context.Background(), request)
+		if result.ErrorResult != "" {
+			t.Fatalf("Sim failed: %s", result.ErrorResult)
+		}
+		return result
+	}
+	replay := func(seed int64) *proto.RaidSimResult {
+		request := googleProto.Clone(rsr).(*proto.RaidSimRequest)
+		request.SimOptions.ReplaySeed = seed
+		result := run(request)
+		if result.Iterations != 1 {
+			t.Fatalf("Replay ran %d iterations, expected 1", result.Iterations)
+		}
+		return result
+	}
+
+	original := run(rsr)
+	dps := original.RaidMetrics.Dps
+	if len(original.CombatEvents) == 0 {
+		t.Fatalf("No events recorded for iteration %d", replayedIteration)
+	}
+
+	// Iteration i is seeded with random_seed+i.
+	replayed := replay(rsr.SimOptions.RandomSeed + replayedIteration)
+	if expected := dps.AllValues[replayedIteration]; replayed.RaidMetrics.Dps.Avg != expected {
+		t.Fatalf("Replayed iteration has %f DPS, expected %f", replayed.RaidMetrics.Dps.Avg, expected)
+	}
+	if len(replayed.CombatEvents) != len(original.CombatEvents) {
+		t.Fatalf("Replayed iteration has %d events, expected %d", len(replayed.CombatEvents), len(original.CombatEvents))
+	}
+	for i, event := range original.CombatEvents {
+		if !googleProto.Equal(event, replayed.CombatEvents[i]) {
+			t.Fatalf("Replayed event %d is %v, expected %v", i, replayed.CombatEvents[i], event)
+		}
+	}
+
+	// The min and max seeds of a result replay its worst and best iterations.
+	if got := replay(dps.MaxSeed).RaidMetrics.Dps.Avg; got != dps.Max {
+		t.Fatalf("Replaying the max seed gave %f DPS, expected %f", got, dps.Max)
+	}
+	if got := replay(dps.MinSeed).RaidMetrics.Dps.Avg; got != dps.Min {
+		t.Fatalf("Replaying the min seed gave %f DPS, expected %f", got, dps.Min)
+	}
+}
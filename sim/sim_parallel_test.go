@@ -0,0 +1,52 @@
+package sim
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+// Splitting the iterations across workers should give the same results as
+// running them on a single sim, including the presim of a health fight.
+func TestParallelWorkersMatchSingleWorker(t *testing.T) {
+	rsr := fireMageRaidSimRequest(nil, nil)
+	rsr.Raid.Parties[0].Players[0].HealingModel = &proto.HealingModel{
+		CadenceSeconds: 2,
+	}
+	rsr.Encounter.Duration = 300
+	rsr.Encounter.UseHealth = true
+	rsr.Encounter.Targets[0].Stats = stats.Stats{stats.Armor: 3731, stats.Health: 150000}.ToFloatArray()
+	rsr.SimOptions.Iterations = 2000
+
+	run := func(concurrency int32) *proto.RaidSimResult {
+		request := googleProto.Clone(rsr).(*proto.RaidSimRequest)
+		request.SimOptions.Concurrency = concurrency
+		result := core.RunRaidSim(context.Background(), request)
+		if result.ErrorResult != "" {
+			t.Fatalf("Sim with concurrency %d failed: %s", concurrency, result.ErrorResult)
+		}
+		return result
+	}
+	single := run(1)
+	parallel := run(4)
+
+	if parallel.Iterations != single.Iterations {
+		t.Fatalf("Ran %d iterations on 4 workers, expected %d", parallel.Iterations, single.Iterations)
+	}
+	// Metrics are summed in a different order across workers, so allow for rounding.
+	closeEnough := func(a, b float64) bool {
+		return math.Abs(a-b) <= 1e-6*math.Max(1, math.Abs(a))
+	}
+	expected, actual := single.RaidMetrics.Dps, parallel.RaidMetrics.Dps
+	if !closeEnough(expected.Avg, actual.Avg) || !closeEnough(expected.Stdev, actual.Stdev) || expected.Max != actual.Max || expected.Min != actual.Min {
+		t.Fatalf("Raid DPS on 4 workers %v, expected %v", actual, expected)
+	}
+	if !closeEnough(single.AvgIterationDuration, parallel.AvgIterationDuration) {
+		t.Fatalf("Average duration on 4 workers %f, expected %f", parallel.AvgIterationDuration, single.AvgIterationDuration)
+	}
+}
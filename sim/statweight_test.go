@@ -0,0 +1,69 @@
+package sim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+)
+
+// Cancelling stat weights keeps the baseline and the weights of stats whose sims completed.
+func TestStatWeightsCancelledKeepsCompletedSims(t *testing.T) {
+	rsr := fireMageRaidSimRequest(nil, nil)
+	rsr.SimOptions.Iterations = 200
+	swr := &proto.StatWeightsRequest{
+		Player:          rsr.Raid.Parties[0].Players[0],
+		RaidBuffs:       rsr.Raid.Buffs,
+		PartyBuffs:      rsr.Raid.Parties[0].Buffs,
+		Debuffs:         rsr.Raid.Debuffs,
+		Encounter:       rsr.Encounter,
+		SimOptions:      rsr.SimOptions,
+		StatsToWeigh:    []proto.Stat{proto.Stat_StatIntellect, proto.Stat_StatSpellPower},
+		EpReferenceStat: proto.Stat_StatSpellPower,
+	}
+
+	// Cancel once three of the four sims are done, so at least one stat has both its
+	// sims completed. The last sim can't finish until its final progress is read,
+	// which happens after cancelling.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progress := make(chan *proto.ProgressMetrics)
+	go func() {
+		for metrics := range progress {
+			if metrics.CompletedSims >= 3 {
+				cancel()
+			}
+		}
+	}()
+	result := core.CalcStatWeight(ctx, swr, stats.SpellPower, progress)
+	close(progress)
+
+	if result.ErrorResult != "" {
+		t.Fatalf("Stat weights failed: %s", result.ErrorResult)
+	}
+	if !result.Cancelled {
+		t.Fatalf("Stat weights weren't flagged as cancelled")
+	}
+	if result.Baseline == nil || result.Baseline.Dps.Avg == 0 {
+		t.Fatalf("No baseline DPS in %v", result.Baseline)
+	}
+	if result.Baseline.Dps.AllValues != nil {
+		t.Fatalf("Baseline kept %d DPS values", len(result.Baseline.Dps.AllValues))
+	}
+	if result.Dps.Weights.Stats[stats.Intellect] == 0 && result.Dps.Weights.Stats[stats.SpellPower] == 0 {
+		t.Fatalf("No DPS weights for the completed sims")
+	}
+
+	// Cancelling before any sim completes still returns the baseline, without weights.
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	result = core.CalcStatWeight(cancelled, swr, stats.SpellPower, nil)
+	if !result.Cancelled || result.Baseline == nil {
+		t.Fatalf("Cancelled stat weights returned %v", result)
+	}
+	if weight := result.Dps.Weights.Stats[stats.Intellect]; weight != 0 {
+		t.Fatalf("Intellect has a DPS weight of %f without completed sims", weight)
+	}
+}
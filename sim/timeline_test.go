@@ -0,0 +1,56 @@
+package sim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	"github.com/wowsims/sod/sim/core/stats"
+)
+
+// Prepull damage isn't part of the timeline, and each iteration only counts
+// towards the bins up to the time its targets died.
+func TestTimelinePrepullDamageAndHealthFight(t *testing.T) {
+	rotation := core.GetAplRotation("../ui/mage/apls", "p4_fire").Rotation
+	// Fire Blast before the other prepull casts, so it hits before the pull.
+	rotation.PrepullActions = append([]*proto.APLPrepullAction{{
+		Action: &proto.APLAction{
+			Action: &proto.APLAction_CastSpell{CastSpell: &proto.APLActionCastSpell{
+				SpellId: &proto.ActionID{RawId: &proto.ActionID_SpellId{SpellId: 10199}, Rank: 7},
+			}},
+		},
+		DoAtValue: &proto.APLValue{
+			Value: &proto.APLValue_Const{Const: &proto.APLValueConst{Val: "-5s"}},
+		},
+	}}, rotation.PrepullActions...)
+
+	const iterations = 50
+	const binSeconds = 0.5
+	rsr := fireMageRaidSimRequest(rotation, nil)
+	rsr.Encounter.Duration = 300
+	rsr.Encounter.UseHealth = true
+	rsr.Encounter.Targets[0].Stats = stats.Stats{stats.Armor: 3731, stats.Health: 100000}.ToFloatArray()
+	rsr.SimOptions.Iterations = iterations
+	rsr.SimOptions.TimelineBinSeconds = binSeconds
+
+	result := core.RunRaidSim(context.Background(), rsr)
+	if result.ErrorResult != "" {
+		t.Fatalf("Sim failed: %s", result.ErrorResult)
+	}
+
+	timeline := result.RaidMetrics.Parties[0].Players[0].Timeline
+	if timeline.Iterations[0] != iterations {
+		t.Fatalf("First bin has %d iterations, expected %d", timeline.Iterations[0], iterations)
+	}
+
+	// Each iteration lasts into ceil(duration / binSeconds) bins.
+	binnedSeconds := 0.0
+	for _, n := range timeline.Iterations {
+		binnedSeconds += float64(n) * binSeconds
+	}
+	fightSeconds := result.EncounterMetrics.FightDuration.Avg * iterations
+	if binnedSeconds < fightSeconds || binnedSeconds >= fightSeconds+binSeconds*iterations {
+		t.Fatalf("Timeline bins cover %f seconds, expected %f to %f", binnedSeconds, fightSeconds, fightSeconds+binSeconds*iterations)
+	}
+}
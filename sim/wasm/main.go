@@ -7,6 +7,7 @@ import (
 	"context"
 	"log"
 	"runtime/debug"
+	"sync"
 	"syscall/js"
 
 	"github.com/wowsims/sod/sim"
@@ -31,6 +32,7 @@ func main() {
 	js.Global().Set("statWeights", js.FuncOf(statWeights))
 	js.Global().Set("statWeightsAsync", js.FuncOf(statWeightsAsync))
 	js.Global().Set("bulkSimAsync", js.FuncOf(bulkSimAsync))
+	js.Global().Set("cancelAsync", js.FuncOf(cancelAsync))
 	js.Global().Call("wasmready")
 	<-c
 }
@@ -65,7 +67,7 @@ func computeStats(this js.Value, args []js.Value) (response interface{}) {
 		log.Printf("Failed to parse request: %s", err)
 		return nil
 	}
-	result := core.ComputeStats(csr)
+	result := core.ComputeStats(context.Background(), csr)
 
 	outbytes, err := googleProto.Marshal(result)
 	if err != nil {
@@ -109,7 +111,7 @@ func computeStatsJson(this js.Value, args []js.Value) (response interface{}) {
 		log.Printf("Failed to parse request: %s", err)
 		return nil
 	}
-	result := core.ComputeStats(csr)
+	result := core.ComputeStats(context.Background(), csr)
 
 	output, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(result)
 	if err != nil {
@@ -126,7 +128,7 @@ func raidSim(this js.Value, args []js.Value) interface{} {
 		log.Printf("Failed to parse request: %s", err)
 		return nil
 	}
-	result := core.RunRaidSim(rsr)
+	result := core.RunRaidSim(context.Background(), rsr)
 
 	outbytes, err := googleProto.Marshal(result)
 	if err != nil {
@@ -146,7 +148,7 @@ func raidSimJson(this js.Value, args []js.Value) interface{} {
 		log.Printf("Failed to parse request: %s", err)
 		return nil
 	}
-	result := core.RunRaidSim(rsr)
+	result := core.RunRaidSim(context.Background(), rsr)
 
 	output, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(result)
 	if err != nil {
@@ -163,10 +165,9 @@ func raidSimAsync(this js.Value, args []js.Value) interface{} {
 		log.Printf("Failed to parse request: %s", err)
 		return nil
 	}
-	reporter := make(chan *proto.ProgressMetrics, 100)
-
-	go core.RunRaidSimAsync(rsr, reporter)
-	return processAsyncProgress(args[1], reporter)
+	return runAsync(args, func(ctx context.Context, reporter chan *proto.ProgressMetrics) {
+		core.RunRaidSimAsync(ctx, rsr, reporter)
+	})
 }
 
 func statWeights(this js.Value, args []js.Value) interface{} {
@@ -175,7 +176,7 @@ func statWeights(this js.Value, args []js.Value) interface{} {
 		log.Printf("Failed to parse request: %s", err)
 		return nil
 	}
-	result := core.StatWeights(swr)
+	result := core.StatWeights(context.Background(), swr)
 
 	outbytes, err := googleProto.Marshal(result)
 	if err != nil {
@@ -195,11 +196,9 @@ func statWeightsAsync(this js.Value, args []js.Value) interface{} {
 		log.Printf("Failed to parse request: %s", err)
 		return nil
 	}
-	reporter := make(chan *proto.ProgressMetrics, 100)
-	core.StatWeightsAsync(rsr, reporter)
-
-	result := processAsyncProgress(args[1], reporter)
-	return result
+	return runAsync(args, func(ctx context.Context, reporter chan *proto.ProgressMetrics) {
+		core.StatWeightsAsync(ctx, rsr, reporter)
+	})
 }
 
 func bulkSimAsync(this js.Value, args []js.Value) interface{} {
@@ -208,13 +207,72 @@ func bulkSimAsync(this js.Value, args []js.Value) interface{} {
 		log.Printf("Failed to parse request: %s", err)
 		return nil
 	}
-	reporter := make(chan *proto.ProgressMetrics, 100)
-	// for now just use context.Background() until we can figure out the best way to handle
-	// allowing front end to cancel.
-	core.RunBulkSimAsync(context.Background(), rsr, reporter)
+	return runAsync(args, func(ctx context.Context, reporter chan *proto.ProgressMetrics) {
+		core.RunBulkSimAsync(ctx, rsr, reporter)
+	})
+}
 
-	result := processAsyncProgress(args[1], reporter)
-	return result
+// Starts an async sim with start, which must not block, and reports its progress to
+// the JS callback in args[1]. Returns a Promise for the final progress, so the JS event
+// loop keeps running while the sim does, which is what lets cancelAsync stop it.
+func runAsync(args []js.Value, start func(ctx context.Context, reporter chan *proto.ProgressMetrics)) interface{} {
+	ctx, done := newAsyncContext(args)
+
+	// Wasm only returns control to JS once every goroutine is blocked. The reporter is
+	// unbuffered so the sim waits on each progress update, while processAsyncProgress
+	// yields to the event loop between updates.
+	reporter := make(chan *proto.ProgressMetrics)
+
+	var executor js.Func
+	executor = js.FuncOf(func(this js.Value, promiseArgs []js.Value) interface{} {
+		resolve := promiseArgs[0]
+		go func() {
+			defer done()
+			start(ctx, reporter)
+			resolve.Invoke(processAsyncProgress(args[1], reporter))
+		}()
+		return nil
+	})
+	promise := js.Global().Get("Promise").New(executor)
+	executor.Release()
+	return promise
+}
+
+var asyncCancelsMut sync.Mutex
+var asyncCancels = map[string]context.CancelFunc{}
+
+// Creates the context for an async sim. If args[2] is a string, it is used as the ID
+// to cancel the sim with cancelAsync. The returned func must be called once the sim is done.
+func newAsyncContext(args []js.Value) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if len(args) < 3 || args[2].Type() != js.TypeString {
+		return ctx, cancel
+	}
+
+	id := args[2].String()
+	asyncCancelsMut.Lock()
+	asyncCancels[id] = cancel
+	asyncCancelsMut.Unlock()
+
+	return ctx, func() {
+		asyncCancelsMut.Lock()
+		delete(asyncCancels, id)
+		asyncCancelsMut.Unlock()
+		cancel()
+	}
+}
+
+// Cancels the async sim started with the ID in args[0]. The sim still reports a final
+// (partial) result through its progress callback.
+func cancelAsync(this js.Value, args []js.Value) interface{} {
+	id := args[0].String()
+	asyncCancelsMut.Lock()
+	cancel, ok := asyncCancels[id]
+	asyncCancelsMut.Unlock()
+	if ok {
+		cancel()
+	}
+	return js.ValueOf(ok)
 }
 
 // Assumes args[0] is a Uint8Array
@@ -251,8 +309,23 @@ reader:
 			if progMetric.FinalWeightResult != nil || progMetric.FinalRaidResult != nil || progMetric.FinalBulkResult != nil {
 				return outArray
 			}
+			yieldToEventLoop()
 		}
 	}
 
 	return js.Undefined()
 }
+
+// Blocks the calling goroutine until the JS event loop has had a chance to run, e.g.
+// to handle a cancelAsync message.
+func yieldToEventLoop() {
+	yielded := make(chan struct{})
+	var callback js.Func
+	callback = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		callback.Release()
+		close(yielded)
+		return nil
+	})
+	js.Global().Call("setTimeout", callback, 0)
+	<-yielded
+}
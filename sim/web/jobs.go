@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/google/uuid"
+	proto "github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+// Job types, and the async handler used to run them.
+var jobTypes = map[string]string{
+	"raidSim":     "/raidSimAsync",
+	"statWeights": "/statWeightsAsync",
+	"bulkSim":     "/bulkSimAsync",
+}
+
+type jobStatus string
+
+const (
+	jobQueued    jobStatus = "queued"
+	jobRunning   jobStatus = "running"
+	jobDone      jobStatus = "done"
+	jobFailed    jobStatus = "failed"
+	jobCancelled jobStatus = "cancelled"
+)
+
+// A sim submitted to the job queue. The exported fields are what gets persisted,
+// with the request and final progress (holding the result) stored as protojson.
+type job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Status     jobStatus       `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+
+	msg      googleProto.Message
+	progress *proto.ProgressMetrics // Latest progress while running.
+	cancel   context.CancelFunc
+}
+
+// Job without the request and result, as shown in job lists.
+func (j *job) summary() *job {
+	return &job{
+		ID:         j.ID,
+		Type:       j.Type,
+		Status:     j.Status,
+		Error:      j.Error,
+		CreatedAt:  j.CreatedAt,
+		StartedAt:  j.StartedAt,
+		FinishedAt: j.FinishedAt,
+	}
+}
+
+// jobQueue runs submitted jobs in order on a fixed number of workers. If dir is set,
+// every job is saved there, and unfinished jobs are queued again after a restart.
+type jobQueue struct {
+	dir  string
+	sims *server // Running jobs take a sim slot, like any other sim.
+
+	mut     sync.Mutex
+	cond    *sync.Cond
+	jobs    map[string]*job
+	pending []*job
+}
+
+func newJobQueue(dir string, numWorkers int, sims *server) (*jobQueue, error) {
+	q := &jobQueue{
+		dir:  dir,
+		sims: sims,
+		jobs: map[string]*job{},
+	}
+	q.cond = sync.NewCond(&q.mut)
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create jobs directory: %w", err)
+		}
+		if err := q.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < numWorkers; i++ {
+		go q.runWorker()
+	}
+	return q, nil
+}
+
+// Loads all saved jobs. Jobs which were queued or running when the server stopped are queued again.
+func (q *jobQueue) load() error {
+	files, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read job %s: %w", file, err)
+		}
+		j := &job{}
+		if err := json.Unmarshal(data, j); err != nil {
+			log.Printf("Skipping invalid job file %s: %s", file, err)
+			continue
+		}
+		route, ok := jobTypes[j.Type]
+		if !ok {
+			log.Printf("Skipping job %s with unknown type %s", j.ID, j.Type)
+			continue
+		}
+		j.msg = asyncAPIHandlers[route].msg()
+		if err := protojson.Unmarshal(j.Request, j.msg); err != nil {
+			log.Printf("Skipping job %s with invalid request: %s", j.ID, err)
+			continue
+		}
+		q.jobs[j.ID] = j
+	}
+
+	var unfinished []*job
+	for _, j := range q.jobs {
+		if j.Status == jobQueued || j.Status == jobRunning {
+			j.Status = jobQueued
+			j.StartedAt = nil
+			unfinished = append(unfinished, j)
+		}
+	}
+	slices.SortFunc(unfinished, func(a, b *job) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	q.pending = append(q.pending, unfinished...)
+	if len(unfinished) > 0 {
+		log.Printf("Queued %d unfinished jobs from %s", len(unfinished), q.dir)
+	}
+	return nil
+}
+
+// Writes a job to disk, through a temporary file so a crash never leaves a partial job.
+// Must be called with q.mut held.
+func (q *jobQueue) save(j *job) {
+	if q.dir == "" {
+		return
+	}
+
+	data, err := json.Marshal(j)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal job %s: %s", j.ID, err)
+		return
+	}
+	path := filepath.Join(q.dir, j.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		log.Printf("[ERROR] Failed to save job %s: %s", j.ID, err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Printf("[ERROR] Failed to save job %s: %s", j.ID, err)
+	}
+}
+
+func (q *jobQueue) submit(jobType string, msg googleProto.Message) (*job, error) {
+	request, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &job{
+		ID:        uuid.NewString(),
+		Type:      jobType,
+		Status:    jobQueued,
+		CreatedAt: time.Now(),
+		Request:   request,
+		msg:       msg,
+	}
+
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	q.jobs[j.ID] = j
+	q.pending = append(q.pending, j)
+	q.save(j)
+	q.cond.Signal()
+	return j.summary(), nil
+}
+
+// Cancels a queued or running job. Returns false if there is no such job.
+func (q *jobQueue) cancel(id string) bool {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return false
+	}
+
+	switch j.Status {
+	case jobQueued:
+		q.pending = slices.DeleteFunc(q.pending, func(pending *job) bool { return pending == j })
+		now := time.Now()
+		j.Status = jobCancelled
+		j.FinishedAt = &now
+		q.save(j)
+	case jobRunning:
+		// The worker marks the job as cancelled once the sim returns its partial result.
+		j.cancel()
+	}
+	return true
+}
+
+// Returns all jobs without their requests and results, oldest first.
+func (q *jobQueue) list() []*job {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	list := make([]*job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		list = append(list, j.summary())
+	}
+	slices.SortFunc(list, func(a, b *job) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return list
+}
+
+// Returns a copy of a job. For running jobs, the result holds the latest progress instead of the final one.
+func (q *jobQueue) get(id string) (*job, bool) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, false
+	}
+
+	result := j.summary()
+	result.Request = j.Request
+	result.Result = j.Result
+	if j.Status == jobRunning && j.progress != nil {
+		if progress, err := protojson.Marshal(j.progress); err == nil {
+			result.Result = progress
+		}
+	}
+	return result, true
+}
+
+func (q *jobQueue) queueDepth() int {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return len(q.pending)
+}
+
+func (q *jobQueue) runWorker() {
+	for {
+		q.mut.Lock()
+		for len(q.pending) == 0 {
+			q.cond.Wait()
+		}
+		q.mut.Unlock()
+
+		// Wait for a free sim slot before taking the job, so it can still be cancelled while queued.
+		q.sims.waitForSim()
+		q.mut.Lock()
+		if len(q.pending) == 0 {
+			// Another worker took the job, or it was cancelled.
+			q.mut.Unlock()
+			q.sims.endSim()
+			continue
+		}
+		j := q.pending[0]
+		q.pending = q.pending[1:]
+
+		ctx, cancel := context.WithCancel(context.Background())
+		now := time.Now()
+		j.Status = jobRunning
+		j.StartedAt = &now
+		j.cancel = cancel
+		q.save(j)
+		q.mut.Unlock()
+
+		final := q.run(ctx, j)
+		cancelled := ctx.Err() != nil
+		cancel()
+		q.sims.endSim()
+
+		q.mut.Lock()
+		now = time.Now()
+		j.FinishedAt = &now
+		j.progress = nil
+		j.cancel = nil
+		j.Status = jobDone
+		j.Error = finalError(final)
+		switch {
+		case cancelled:
+			// Sims can also fail while stopping, but the job still ended because it was cancelled.
+			j.Status = jobCancelled
+		case j.Error != "":
+			j.Status = jobFailed
+		}
+		if result, err := protojson.Marshal(final); err == nil {
+			j.Result = result
+		} else {
+			log.Printf("[ERROR] Failed to marshal result of job %s: %s", j.ID, err)
+		}
+		q.save(j)
+		q.mut.Unlock()
+	}
+}
+
+// Runs a job until its final progress report, which holds the result. Sims recover
+// from their own panics, and report them as an error in the final result.
+func (q *jobQueue) run(ctx context.Context, j *job) *proto.ProgressMetrics {
+	reporter := make(chan *proto.ProgressMetrics, 100)
+	asyncAPIHandlers[jobTypes[j.Type]].handle(ctx, j.msg, reporter)
+
+	for progMetric := range reporter {
+		if isFinalProgress(progMetric) {
+			return progMetric
+		}
+		q.mut.Lock()
+		j.progress = progMetric
+		q.mut.Unlock()
+	}
+	return &proto.ProgressMetrics{
+		FinalRaidResult: &proto.RaidSimResult{ErrorResult: "sim stopped without a result"},
+	}
+}
+
+// Returns the error of a final progress report, if the sim failed.
+func finalError(final *proto.ProgressMetrics) string {
+	switch {
+	case final.FinalRaidResult != nil:
+		return final.FinalRaidResult.ErrorResult
+	case final.FinalWeightResult != nil:
+		return final.FinalWeightResult.ErrorResult
+	case final.FinalBulkResult != nil:
+		return final.FinalBulkResult.ErrorResult
+	}
+	return ""
+}
+
+// setupJobServer serves the job API:
+//
+//	POST /jobs/{type}        submits a raidSim, statWeights or bulkSim request as a new job.
+//	GET  /jobs               lists all jobs.
+//	GET  /jobs/{id}          returns a job, including its request and result.
+//	POST /jobs/{id}/cancel   cancels a queued or running job.
+//
+// Requests can be binary protos or protojson, depending on their content type. Responses are always JSON.
+func (s *server) setupJobServer() {
+	http.Handle("/jobs", corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.jobs.list())
+	})))
+
+	http.Handle("/jobs/", corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/jobs/")
+
+		if route, ok := jobTypes[path]; ok && r.Method == http.MethodPost {
+			msg := asyncAPIHandlers[route].msg()
+			if !s.readRequest(w, r, msg) {
+				return
+			}
+			j, err := s.jobs.submit(path, msg)
+			if err != nil {
+				writeError(w, r, http.StatusInternalServerError, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusAccepted, j)
+			return
+		}
+
+		if id, ok := strings.CutSuffix(path, "/cancel"); ok && r.Method == http.MethodPost {
+			if !s.jobs.cancel(id) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		j, ok := s.jobs.get(path)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	})))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	outbytes, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal result: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(outbytes)
+}
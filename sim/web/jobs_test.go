@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+func TestJobQueueRestoresUnfinishedJobs(t *testing.T) {
+	dir := t.TempDir()
+
+	// Without workers, submitted jobs stay queued.
+	q, err := newJobQueue(dir, 0, nil)
+	if err != nil {
+		t.Fatalf("Failed to create job queue: %s", err)
+	}
+	request := &proto.RaidSimRequest{
+		SimOptions: &proto.SimOptions{Iterations: 10, RandomSeed: 5},
+	}
+	first, err := q.submit("raidSim", request)
+	if err != nil {
+		t.Fatalf("Failed to submit job: %s", err)
+	}
+	second, err := q.submit("raidSim", request)
+	if err != nil {
+		t.Fatalf("Failed to submit job: %s", err)
+	}
+	q.cancel(second.ID)
+
+	restored, err := newJobQueue(dir, 0, nil)
+	if err != nil {
+		t.Fatalf("Failed to restore job queue: %s", err)
+	}
+
+	jobs := restored.list()
+	if len(jobs) != 2 {
+		t.Fatalf("Restored %d jobs, expected 2", len(jobs))
+	}
+	if restored.queueDepth() != 1 || restored.pending[0].ID != first.ID {
+		t.Fatalf("Expected only job %s to be queued again, got %v", first.ID, restored.pending)
+	}
+	if j, _ := restored.get(second.ID); j.Status != jobCancelled {
+		t.Fatalf("Cancelled job was restored with status %s", j.Status)
+	}
+	if !googleProto.Equal(restored.pending[0].msg, request) {
+		t.Fatalf("Restored request %v, expected %v", restored.pending[0].msg, request)
+	}
+}
+
+func TestJobWorkersWaitForSimSlots(t *testing.T) {
+	s := &server{simSlots: make(chan struct{}, 1)}
+	if !s.startSim() {
+		t.Fatalf("Failed to take the only sim slot")
+	}
+	q, err := newJobQueue("", 1, s)
+	if err != nil {
+		t.Fatalf("Failed to create job queue: %s", err)
+	}
+	// Fails quickly, which still ends the job.
+	submitted, err := q.submit("raidSim", &proto.RaidSimRequest{SimOptions: &proto.SimOptions{Iterations: 10}})
+	if err != nil {
+		t.Fatalf("Failed to submit job: %s", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if j, _ := q.get(submitted.ID); j.Status != jobQueued {
+		t.Fatalf("Job is %s while all sim slots are taken", j.Status)
+	}
+
+	s.endSim()
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if j, _ := q.get(submitted.ID); j.Status != jobQueued && j.Status != jobRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job didn't finish once a sim slot was free")
+		}
+	}
+	if running := s.numRunningSims.Load(); running != 0 {
+		t.Fatalf("Finished job left %d sims running", running)
+	}
+}
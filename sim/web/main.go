@@ -3,7 +3,9 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -26,6 +28,7 @@ import (
 	"github.com/wowsims/sod/sim/core"
 	proto "github.com/wowsims/sod/sim/core/proto"
 
+	"google.golang.org/protobuf/encoding/protojson"
 	googleProto "google.golang.org/protobuf/proto"
 )
 
@@ -48,11 +51,16 @@ func main() {
 	var host = flag.String("host", "localhost:3333", "URL to host the interface on.")
 	var launch = flag.Bool("launch", true, "auto launch browser")
 	var skipVersionCheck = flag.Bool("nvc", false, "set true to skip version check")
+	var headless = flag.Bool("headless", false, "Only serve the sim APIs, without the UI, browser launch or version check.")
+	var maxRequestBytes = flag.Int64("maxbody", 8<<20, "Max size of API request bodies in bytes, 0 for no limit.")
+	var maxSims = flag.Int("maxsims", 0, "Max number of sims running at once, 0 for no limit.")
+	var jobsDir = flag.String("jobsdir", "", "Directory to save jobs in, so they survive restarts. Jobs are only kept in memory if not set.")
+	var jobWorkers = flag.Int("jobworkers", 1, "Number of jobs to run at once. 0 disables the job API.")
 
 	flag.Parse()
 
 	fmt.Printf("Version: %s\n", Version)
-	if !*skipVersionCheck && Version != "development" {
+	if !*skipVersionCheck && !*headless && Version != "development" {
 		go func() {
 			resp, err := http.Get("https://api.github.com/repos/wowsims/sod/releases/latest")
 			if err != nil {
@@ -85,60 +93,143 @@ func main() {
 	s := &server{
 		progMut:         sync.RWMutex{},
 		asyncProgresses: map[string]*asyncProgress{},
+		headless:        *headless,
+		maxRequestBytes: *maxRequestBytes,
 	}
-	s.runServer(*useFS, *host, *launch, *simName, *wasm, bufio.NewReader(os.Stdin))
+	if *maxSims > 0 {
+		s.simSlots = make(chan struct{}, *maxSims)
+	}
+	if *jobWorkers > 0 {
+		jobs, err := newJobQueue(*jobsDir, *jobWorkers, s)
+		if err != nil {
+			log.Fatalf("Failed to start job queue: %s", err)
+		}
+		s.jobs = jobs
+	}
+	s.runServer(*useFS, *host, *launch && !*headless, *simName, *wasm, bufio.NewReader(os.Stdin))
 }
 
-// Handlers to decode and handle each proto function
+// Handlers to decode and handle each proto function.
+// Request and response bodies are binary protos, or protojson if the request has a JSON content type.
 var handlers = map[string]apiHandler{
-	"/raidSim": {msg: func() googleProto.Message { return &proto.RaidSimRequest{} }, handle: func(msg googleProto.Message) googleProto.Message {
-		return core.RunRaidSim(msg.(*proto.RaidSimRequest))
+	"/raidSim": {msg: func() googleProto.Message { return &proto.RaidSimRequest{} }, handle: func(ctx context.Context, msg googleProto.Message) googleProto.Message {
+		return core.RunRaidSim(ctx, msg.(*proto.RaidSimRequest))
 	}},
-	"/statWeights": {msg: func() googleProto.Message { return &proto.StatWeightsRequest{} }, handle: func(msg googleProto.Message) googleProto.Message {
-		return core.StatWeights(msg.(*proto.StatWeightsRequest))
-	}},
-	"/computeStats": {msg: func() googleProto.Message { return &proto.ComputeStatsRequest{} }, handle: func(msg googleProto.Message) googleProto.Message {
-		return core.ComputeStats(msg.(*proto.ComputeStatsRequest))
+	"/statWeights": {msg: func() googleProto.Message { return &proto.StatWeightsRequest{} }, handle: func(ctx context.Context, msg googleProto.Message) googleProto.Message {
+		return core.StatWeights(ctx, msg.(*proto.StatWeightsRequest))
 	}},
+	"/computeStats": {msg: func() googleProto.Message { return &proto.ComputeStatsRequest{} }, handle: func(ctx context.Context, msg googleProto.Message) googleProto.Message {
+		return core.ComputeStats(ctx, msg.(*proto.ComputeStatsRequest))
+	}, unlimited: true},
 }
 
+// Async handlers are cancelled through the /cancelAsync endpoint, using the progress ID of the sim.
 var asyncAPIHandlers = map[string]asyncAPIHandler{
-	"/raidSimAsync": {msg: func() googleProto.Message { return &proto.RaidSimRequest{} }, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics) {
-		core.RunRaidSimAsync(msg.(*proto.RaidSimRequest), reporter)
+	"/raidSimAsync": {msg: func() googleProto.Message { return &proto.RaidSimRequest{} }, handle: func(ctx context.Context, msg googleProto.Message, reporter chan *proto.ProgressMetrics) {
+		core.RunRaidSimAsync(ctx, msg.(*proto.RaidSimRequest), reporter)
 	}},
-	"/statWeightsAsync": {msg: func() googleProto.Message { return &proto.StatWeightsRequest{} }, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics) {
-		core.StatWeightsAsync(msg.(*proto.StatWeightsRequest), reporter)
+	"/statWeightsAsync": {msg: func() googleProto.Message { return &proto.StatWeightsRequest{} }, handle: func(ctx context.Context, msg googleProto.Message, reporter chan *proto.ProgressMetrics) {
+		core.StatWeightsAsync(ctx, msg.(*proto.StatWeightsRequest), reporter)
 	}},
-	"/bulkSimAsync": {msg: func() googleProto.Message { return &proto.BulkSimRequest{} }, handle: func(msg googleProto.Message, reporter chan *proto.ProgressMetrics) {
-		// TODO: we can use context's to cancel stuff.
-		// We should have all the async APIs take in context and let it be cancelled via its async ID.
-		core.RunBulkSimAsync(context.Background(), msg.(*proto.BulkSimRequest), reporter)
+	"/bulkSimAsync": {msg: func() googleProto.Message { return &proto.BulkSimRequest{} }, handle: func(ctx context.Context, msg googleProto.Message, reporter chan *proto.ProgressMetrics) {
+		core.RunBulkSimAsync(ctx, msg.(*proto.BulkSimRequest), reporter)
 	}},
 }
 
 type server struct {
 	progMut         sync.RWMutex
 	asyncProgresses map[string]*asyncProgress
+
+	headless        bool          // Only serve APIs, not the UI.
+	maxRequestBytes int64         // 0 for no limit.
+	simSlots        chan struct{} // One entry per running sim, nil for no limit.
+	numRunningSims  atomic.Int32
+
+	jobs    *jobQueue // nil if jobs are disabled.
+	metrics *serverMetrics
 }
 
 type apiHandler struct {
 	msg    func() googleProto.Message
-	handle func(googleProto.Message) googleProto.Message
+	handle func(context.Context, googleProto.Message) googleProto.Message
+	// Cheap requests which don't count towards the max number of running sims.
+	unlimited bool
 }
 type asyncAPIHandler struct {
 	msg    func() googleProto.Message
-	handle func(googleProto.Message, chan *proto.ProgressMetrics)
+	handle func(context.Context, googleProto.Message, chan *proto.ProgressMetrics)
 }
 
 type asyncProgress struct {
 	id             string
 	latestProgress atomic.Value
+	cancel         context.CancelFunc
+
+	// Channels of streaming clients, notified when latestProgress changes.
+	subMut      sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	finished    bool
+}
+
+// Stores a progress update as the latest one, and notifies all streaming clients.
+func (ap *asyncProgress) publish(progMetric *proto.ProgressMetrics) {
+	ap.latestProgress.Store(progMetric)
+
+	ap.subMut.Lock()
+	defer ap.subMut.Unlock()
+	for sub := range ap.subscribers {
+		select {
+		case sub <- struct{}{}:
+		default:
+			// The client hasn't handled its previous notification yet, and will read
+			// this update from latestProgress when it does. This way slow clients skip
+			// to the latest update rather than blocking the sim.
+		}
+	}
+}
+
+// Closes all subscriber channels, once no more progress will be published.
+func (ap *asyncProgress) finish() {
+	ap.subMut.Lock()
+	defer ap.subMut.Unlock()
+	ap.finished = true
+	for sub := range ap.subscribers {
+		close(sub)
+	}
+	ap.subscribers = nil
 }
 
-func (s *server) addNewSim() *asyncProgress {
+// Returns a channel which is notified of future progress updates, and is closed when the sim
+// is done. Updates published before the client handles a notification share that notification.
+func (ap *asyncProgress) subscribe() chan struct{} {
+	sub := make(chan struct{}, 1)
+
+	ap.subMut.Lock()
+	defer ap.subMut.Unlock()
+	if ap.finished {
+		close(sub)
+		return sub
+	}
+	ap.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (ap *asyncProgress) unsubscribe(sub chan struct{}) {
+	ap.subMut.Lock()
+	defer ap.subMut.Unlock()
+	delete(ap.subscribers, sub)
+}
+
+func isFinalProgress(progMetric *proto.ProgressMetrics) bool {
+	return progMetric.FinalRaidResult != nil || progMetric.FinalWeightResult != nil || progMetric.FinalBulkResult != nil
+}
+
+func (s *server) addNewSim(cancel context.CancelFunc) *asyncProgress {
 	newID := uuid.NewString()
 	simProgress := &asyncProgress{
-		id: newID,
+		id:          newID,
+		cancel:      cancel,
+		subscribers: map[chan struct{}]struct{}{},
 	}
 	simProgress.latestProgress.Store(&proto.ProgressMetrics{})
 
@@ -150,22 +241,21 @@ func (s *server) addNewSim() *asyncProgress {
 }
 
 func (s *server) handleAsyncAPI(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return
-	}
 	endpoint := r.URL.Path
 	handler, ok := asyncAPIHandlers[endpoint]
 	if !ok {
 		log.Printf("Invalid Endpoint: %s", endpoint)
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, r, http.StatusNotFound, "unknown endpoint: "+endpoint)
 		return
 	}
 
 	msg := handler.msg()
-	if err := googleProto.Unmarshal(body, msg); err != nil {
-		log.Printf("Failed to parse request: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	if !s.readRequest(w, r, msg) {
+		return
+	}
+
+	if !s.startSim() {
+		writeError(w, r, http.StatusServiceUnavailable, "too many sims running, try again later")
 		return
 	}
 
@@ -173,47 +263,33 @@ func (s *server) handleAsyncAPI(w http.ResponseWriter, r *http.Request) {
 	//  as the simulation advances it will push changes to the channel
 	//  these changes will be consumed by the goroutine below so the asyncProgress endpoint can fetch the results.
 	reporter := make(chan *proto.ProgressMetrics, 100)
-	handler.handle(msg, reporter)
+	ctx, cancel := context.WithCancel(context.Background())
+	handler.handle(ctx, msg, reporter)
 
 	// Generate a new async simulation
-	simProgress := s.addNewSim()
+	simProgress := s.addNewSim(cancel)
 
 	// Now launch a background process that pulls progress reports off the reporter channel
-	// and pushes it into the async progress cache.
+	// and pushes it into the async progress cache. Sims always end with a final result, also
+	// when cancelled through cancelAsync, so the channel is drained until then.
 	go func() {
-		for {
-			select {
-			case <-time.After(time.Minute * 10):
-				// if we get no progress after 10 minutes, delete the pending sim and exit.
-				s.progMut.Lock()
-				delete(s.asyncProgresses, simProgress.id)
-				s.progMut.Unlock()
+		defer s.endSim()
+		defer cancel()
+		defer simProgress.finish()
+		for progMetric := range reporter {
+			if progMetric == nil {
+				return
+			}
+			simProgress.publish(progMetric)
+			if isFinalProgress(progMetric) {
 				return
-			case progMetric := <-reporter:
-				if progMetric == nil {
-					return
-				}
-				simProgress.latestProgress.Store(progMetric)
-				if progMetric.FinalRaidResult != nil || progMetric.FinalWeightResult != nil || progMetric.FinalBulkResult != nil {
-					return
-				}
 			}
 		}
 	}()
 
-	protoResult := &proto.AsyncAPIResult{
+	writeResponse(w, r, http.StatusOK, &proto.AsyncAPIResult{
 		ProgressId: simProgress.id,
-	}
-
-	outbytes, err := googleProto.Marshal(protoResult)
-	if err != nil {
-		log.Printf("[ERROR] Failed to marshal result: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Add("Content-Type", "application/x-protobuf")
-	w.Write(outbytes)
+	})
 }
 
 func (s *server) setupAsyncServer() {
@@ -224,14 +300,8 @@ func (s *server) setupAsyncServer() {
 
 	// asyncProgress will fetch the current progress of a simulation by its UUID.
 	http.Handle("/asyncProgress", corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			return
-		}
 		msg := &proto.AsyncAPIResult{}
-		if err := googleProto.Unmarshal(body, msg); err != nil {
-			log.Printf("Failed to parse request: %s", err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
+		if !s.readRequest(w, r, msg) {
 			return
 		}
 
@@ -244,23 +314,130 @@ func (s *server) setupAsyncServer() {
 			return
 		}
 		latest := progress.latestProgress.Load().(*proto.ProgressMetrics)
-		outbytes, err := googleProto.Marshal(latest)
-		if err != nil {
-			log.Printf("[ERROR] Failed to marshal result: %s", err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
 
 		// If this was the last result, delete the cache for this simulation.
-		if latest.FinalRaidResult != nil || latest.FinalWeightResult != nil || latest.FinalBulkResult != nil {
+		if isFinalProgress(latest) {
 			s.progMut.Lock()
 			delete(s.asyncProgresses, msg.ProgressId)
 			s.progMut.Unlock()
 		}
-		w.Header().Add("Content-Type", "application/x-protobuf")
-		w.Write(outbytes)
+		writeResponse(w, r, http.StatusOK, latest)
+	})))
+
+	// asyncProgressStream pushes the progress updates of a simulation as Server-Sent Events,
+	// ending with the final result. Clients which fall behind skip to the latest update. The UUID is passed as the 'id' query parameter, or as an
+	// AsyncAPIResult body. Events contain base64 encoded binary ProgressMetrics protos.
+	http.Handle("/asyncProgressStream", corsMiddleware(s.handleAsyncProgressStream(func(progMetric *proto.ProgressMetrics) ([]byte, error) {
+		outbytes, err := googleProto.Marshal(progMetric)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(base64.StdEncoding.EncodeToString(outbytes)), nil
+	})))
+
+	// Same as asyncProgressStream, for clients using JSON instead of binary protos.
+	http.Handle("/asyncProgressStreamJson", corsMiddleware(s.handleAsyncProgressStream(func(progMetric *proto.ProgressMetrics) ([]byte, error) {
+		return protojson.Marshal(progMetric)
+	})))
+
+	// cancelAsync stops a running simulation by its UUID. The sim still sends a final
+	// (partial) result, which can be fetched through asyncProgress as usual. Unknown and
+	// finished simulations respond with 404.
+	http.Handle("/cancelAsync", corsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := &proto.AsyncAPIResult{}
+		if !s.readRequest(w, r, msg) {
+			return
+		}
+
+		s.progMut.RLock()
+		progress, ok := s.asyncProgresses[msg.ProgressId]
+		s.progMut.RUnlock()
+		if !ok || isFinalProgress(progress.latestProgress.Load().(*proto.ProgressMetrics)) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		progress.cancel()
+		w.WriteHeader(http.StatusOK)
 	})))
 }
+
+func (s *server) handleAsyncProgressStream(marshal func(*proto.ProgressMetrics) ([]byte, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		progressID := r.URL.Query().Get("id")
+		if progressID == "" {
+			msg := &proto.AsyncAPIResult{}
+			if !s.readRequest(w, r, msg) {
+				return
+			}
+			progressID = msg.ProgressId
+		}
+
+		s.progMut.RLock()
+		progress, ok := s.asyncProgresses[progressID]
+		s.progMut.RUnlock()
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		sub := progress.subscribe()
+		defer progress.unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		var lastSent *proto.ProgressMetrics
+		send := func(progMetric *proto.ProgressMetrics) bool {
+			data, err := marshal(progMetric)
+			if err != nil {
+				log.Printf("[ERROR] Failed to marshal result: %s", err.Error())
+				return false
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+			lastSent = progMetric
+
+			// Like asyncProgress, delete the cache for this simulation once the final result is sent.
+			if isFinalProgress(progMetric) {
+				s.progMut.Lock()
+				delete(s.asyncProgresses, progressID)
+				s.progMut.Unlock()
+				return false
+			}
+			return true
+		}
+
+		// Start with the current state, so clients don't wait for the next update.
+		if !send(progress.latestProgress.Load().(*proto.ProgressMetrics)) {
+			return
+		}
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case _, ok := <-sub:
+				// Always send the latest update, which is the final result once the channel is closed.
+				if latest := progress.latestProgress.Load().(*proto.ProgressMetrics); latest != lastSent {
+					if !send(latest) {
+						return
+					}
+				}
+				if !ok {
+					return
+				}
+			}
+		}
+	}
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -273,9 +450,9 @@ func corsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName string, wasm bool, inputReader *bufio.Reader) {
-	s.setupAsyncServer()
 
+// setupUI serves the client files and version info used by the UI.
+func setupUI(useFS bool, wasm bool) {
 	var fs http.Handler
 	if useFS {
 		log.Printf("Using local file system for development.")
@@ -285,10 +462,6 @@ func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName
 		fs = http.FileServer(http.FS(dist.FS))
 	}
 
-	for route := range handlers {
-		http.Handle(route, corsMiddleware(http.HandlerFunc(handleAPI)))
-	}
-
 	http.HandleFunc("/version", func(resp http.ResponseWriter, req *http.Request) {
 		msg := fmt.Sprintf(`{"version": "%s", "outdated": %d}`, Version, outdated)
 		resp.Write([]byte(msg))
@@ -312,6 +485,30 @@ func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName
 		}
 		fs.ServeHTTP(resp, req)
 	})
+}
+
+func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName string, wasm bool, inputReader *bufio.Reader) {
+	s.metrics = newServerMetrics()
+	core.SetSimMonitor(s.metrics)
+
+	s.setupAsyncServer()
+
+	for route := range handlers {
+		http.Handle(route, corsMiddleware(http.HandlerFunc(s.handleAPI)))
+	}
+
+	http.Handle("/health", corsMiddleware(http.HandlerFunc(s.handleHealth)))
+	http.HandleFunc("/metrics", s.handleMetrics)
+
+	if s.jobs != nil {
+		s.setupJobServer()
+	}
+
+	if s.headless {
+		log.Printf("Running headless, only serving APIs.")
+	} else {
+		setupUI(useFS, wasm)
+	}
 
 	if launchBrowser {
 		if strings.HasPrefix(host, ":") {
@@ -330,7 +527,7 @@ func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName
 
 	go func() {
 		// Launch server!
-		if err := http.ListenAndServe(host, nil); err != nil {
+		if err := http.ListenAndServe(host, s.metrics.instrument(http.DefaultServeMux)); err != nil {
 			log.Printf("Failed to shutdown server: %s", err)
 			os.Exit(1)
 		}
@@ -398,42 +595,190 @@ func (s *server) runServer(useFS bool, host string, launchBrowser bool, simName
 }
 
 // handleAPI is generic handler for any api function using protos.
-func handleAPI(w http.ResponseWriter, r *http.Request) {
+func (s *server) handleAPI(w http.ResponseWriter, r *http.Request) {
 	endpoint := r.URL.Path
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		return
-	}
 	handler, ok := handlers[endpoint]
 	if !ok {
 		log.Printf("Invalid Endpoint: %s", endpoint)
-		w.WriteHeader(http.StatusNotFound)
+		writeError(w, r, http.StatusNotFound, "unknown endpoint: "+endpoint)
 		return
 	}
 
 	msg := handler.msg()
-	if err := googleProto.Unmarshal(body, msg); err != nil {
-		log.Printf("Failed to parse request: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	if !s.readRequest(w, r, msg) {
 		return
 	}
 
 	if googleProto.Equal(msg, msg.ProtoReflect().New().Interface()) {
 		log.Printf("Request is empty")
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "request is empty")
+		return
+	}
+
+	if !handler.unlimited {
+		if !s.startSim() {
+			writeError(w, r, http.StatusServiceUnavailable, "too many sims running, try again later")
+			return
+		}
+		defer s.endSim()
+	}
+
+	result := handler.handle(r.Context(), msg)
+
+	// Invalid input was rejected by readRequest, so errors here are failed sims. The UI
+	// expects binary results with an error to still have an OK status.
+	status := http.StatusOK
+	if isJSONRequest(r) && resultError(result) != "" {
+		status = http.StatusInternalServerError
+	}
+	writeResponse(w, r, status, result)
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.progMut.RLock()
+	numAsyncSims := len(s.asyncProgresses)
+	s.progMut.RUnlock()
+
+	outbytes, err := json.Marshal(struct {
+		Status       string `json:"status"`
+		Version      string `json:"version"`
+		RunningSims  int    `json:"runningSims"`
+		MaxSims      int    `json:"maxSims,omitempty"`
+		NumAsyncSims int    `json:"asyncSims"`
+	}{
+		Status:       "ok",
+		Version:      Version,
+		RunningSims:  int(s.numRunningSims.Load()),
+		MaxSims:      cap(s.simSlots),
+		NumAsyncSims: numAsyncSims,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(outbytes)
+}
+
+// Reserves a slot for a new sim. Returns false if the max number of sims are already running.
+func (s *server) startSim() bool {
+	if s.simSlots != nil {
+		select {
+		case s.simSlots <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	s.numRunningSims.Add(1)
+	return true
+}
+
+// Like startSim, but waits for a free slot instead of failing.
+func (s *server) waitForSim() {
+	if s.simSlots != nil {
+		s.simSlots <- struct{}{}
+	}
+	s.numRunningSims.Add(1)
+}
 
-	result := handler.handle(msg)
+func (s *server) endSim() {
+	s.numRunningSims.Add(-1)
+	if s.simSlots != nil {
+		<-s.simSlots
+	}
+}
+
+// Returns true if the request and response bodies are protojson instead of binary protos.
+func isJSONRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
 
-	outbytes, err := googleProto.Marshal(result)
+// Reads the request body into msg. If this fails, an error response is written and false is returned.
+// JSON requests are also validated, so invalid input is a bad request rather than a failed sim. Binary
+// requests come from the UI, which expects those errors in the result with an OK status.
+func (s *server) readRequest(w http.ResponseWriter, r *http.Request, msg googleProto.Message) bool {
+	if s.maxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBytes)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", maxBytesErr.Limit))
+		} else {
+			writeError(w, r, http.StatusBadRequest, "failed to read request: "+err.Error())
+		}
+		return false
+	}
+
+	if isJSONRequest(r) {
+		err = protojson.Unmarshal(body, msg)
+	} else {
+		err = googleProto.Unmarshal(body, msg)
+	}
+	if err != nil {
+		log.Printf("Failed to parse request: %s", err.Error())
+		writeError(w, r, http.StatusBadRequest, "failed to parse request: "+err.Error())
+		return false
+	}
+
+	if isJSONRequest(r) {
+		if err := validateRequest(msg); err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid request: "+err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+// Writes msg in the same encoding as the request.
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, msg googleProto.Message) {
+	contentType := "application/x-protobuf"
+	var outbytes []byte
+	var err error
+	if isJSONRequest(r) {
+		contentType = "application/json"
+		outbytes, err = protojson.Marshal(msg)
+	} else {
+		outbytes, err = googleProto.Marshal(msg)
+	}
 	if err != nil {
 		log.Printf("[ERROR] Failed to marshal result: %s", err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Add("Content-Type", "application/x-protobuf")
+	w.Header().Add("Content-Type", contentType)
+	w.WriteHeader(status)
 	w.Write(outbytes)
 }
+
+// Writes an error response. JSON clients get an object with the same errorResult field
+// as the result protos, binary clients only get the status code.
+func writeError(w http.ResponseWriter, r *http.Request, status int, errStr string) {
+	if !isJSONRequest(r) {
+		w.WriteHeader(status)
+		return
+	}
+
+	outbytes, err := json.Marshal(struct {
+		ErrorResult string `json:"errorResult"`
+	}{errStr})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(outbytes)
+}
+
+// Returns the error_result field of a result proto, if it has one.
+func resultError(msg googleProto.Message) string {
+	m := msg.ProtoReflect()
+	field := m.Descriptor().Fields().ByName("error_result")
+	if field == nil {
+		return ""
+	}
+	return m.Get(field).String()
+}
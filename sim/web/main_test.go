@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -13,6 +14,7 @@ import (
 	_ "github.com/wowsims/sod/sim/common"
 	"github.com/wowsims/sod/sim/core"
 	"github.com/wowsims/sod/sim/core/proto"
+	"google.golang.org/protobuf/encoding/protojson"
 	googleProto "google.golang.org/protobuf/proto"
 )
 
@@ -108,3 +110,168 @@ func TestIndividualSim(t *testing.T) {
 
 	log.Printf("RESULT: %#v", rsr)
 }
+
+func TestAsyncProgressStreamJson(t *testing.T) {
+	req := &proto.RaidSimRequest{
+		Raid: core.SinglePlayerRaidProto(
+			&proto.Player{
+				Race:          proto.Race_RaceTroll,
+				Class:         proto.Class_ClassMage,
+				Level:         60,
+				Equipment:     core.GetGearSet("../../ui/mage/gear_sets", "p4_fire").GearSet,
+				Rotation:      core.GetAplRotation("../../ui/mage/apls", "p4_fire").Rotation,
+				TalentsString: "21-5052300123033151-203500031",
+				Spec:          &proto.Player_Mage{Mage: &proto.Mage{Options: &proto.Mage_Options{}}},
+			},
+			&proto.PartyBuffs{},
+			&proto.RaidBuffs{},
+			&proto.Debuffs{}),
+		Encounter: &proto.Encounter{
+			Duration: 120,
+			Targets: []*proto.Target{
+				{},
+			},
+		},
+		SimOptions: &proto.SimOptions{
+			Iterations: 1000,
+			RandomSeed: 1,
+		},
+	}
+
+	msgBytes, err := googleProto.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %s", err.Error())
+	}
+
+	r, err := http.Post("http://localhost:3339/raidSimAsync", "application/x-protobuf", bytes.NewReader(msgBytes))
+	if err != nil {
+		t.Fatalf("Failed to POST request: %s", err.Error())
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("Failed to read result body: %s", err.Error())
+	}
+	asyncResult := &proto.AsyncAPIResult{}
+	if err := googleProto.Unmarshal(body, asyncResult); err != nil {
+		t.Fatalf("Failed to parse async result: %s", err.Error())
+	}
+
+	stream, err := http.Get("http://localhost:3339/asyncProgressStreamJson?id=" + asyncResult.ProgressId)
+	if err != nil {
+		t.Fatalf("Failed to GET progress stream: %s", err.Error())
+	}
+	defer stream.Body.Close()
+
+	var numEvents int
+	var final *proto.ProgressMetrics
+	scanner := bufio.NewScanner(stream.Body)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		numEvents++
+		progress := &proto.ProgressMetrics{}
+		if err := protojson.Unmarshal([]byte(data), progress); err != nil {
+			t.Fatalf("Failed to parse progress event: %s", err.Error())
+		}
+		if progress.FinalRaidResult != nil {
+			final = progress
+		}
+	}
+
+	if final == nil {
+		t.Fatalf("Stream ended after %d events without a final result", numEvents)
+	}
+	if final.FinalRaidResult.RaidMetrics.Dps.Avg <= 0 {
+		t.Fatalf("Final result has no DPS: %v", final.FinalRaidResult.RaidMetrics.Dps)
+	}
+}
+
+func TestAsyncProgressCoalescesUpdates(t *testing.T) {
+	progress := &asyncProgress{subscribers: map[chan struct{}]struct{}{}}
+	sub := progress.subscribe()
+
+	// A client which doesn't read while the sim runs gets a single notification.
+	for i := int32(1); i <= 10; i++ {
+		progress.publish(&proto.ProgressMetrics{CompletedIterations: i})
+	}
+	final := &proto.ProgressMetrics{CompletedIterations: 10, FinalRaidResult: &proto.RaidSimResult{}}
+	progress.publish(final)
+	progress.finish()
+
+	notifications := 0
+	for range sub {
+		notifications++
+	}
+	if notifications != 1 {
+		t.Fatalf("Expected updates to be coalesced into 1 notification, got %d", notifications)
+	}
+	if latest := progress.latestProgress.Load().(*proto.ProgressMetrics); latest != final {
+		t.Fatalf("Expected the final result to be the latest progress, got %v", latest)
+	}
+}
+
+func TestJsonErrorResponse(t *testing.T) {
+	r, err := http.Post("http://localhost:3339/raidSim", "application/json", strings.NewReader(`{"raid": 5}`))
+	if err != nil {
+		t.Fatalf("Failed to POST request: %s", err.Error())
+	}
+	if r.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for an invalid request, got %d", http.StatusBadRequest, r.StatusCode)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("Failed to read result body: %s", err.Error())
+	}
+	rsr := &proto.RaidSimResult{}
+	if err := protojson.Unmarshal(body, rsr); err != nil {
+		t.Fatalf("Failed to parse error response: %s", err.Error())
+	}
+	if rsr.ErrorResult == "" {
+		t.Fatalf("Error response has no error result: %s", body)
+	}
+}
+
+func TestJsonInvalidRequest(t *testing.T) {
+	req := &proto.RaidSimRequest{
+		Raid: core.SinglePlayerRaidProto(
+			&proto.Player{
+				Race:      proto.Race_RaceTroll,
+				Class:     proto.Class_ClassShaman,
+				Equipment: p1Equip, // Not in the database.
+				Spec:      basicSpec,
+			},
+			&proto.PartyBuffs{},
+			&proto.RaidBuffs{},
+			&proto.Debuffs{}),
+		Encounter:  &proto.Encounter{Duration: 120},
+		SimOptions: &proto.SimOptions{Iterations: 10},
+	}
+	msgBytes, err := protojson.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %s", err.Error())
+	}
+
+	r, err := http.Post("http://localhost:3339/raidSim", "application/json", bytes.NewReader(msgBytes))
+	if err != nil {
+		t.Fatalf("Failed to POST request: %s", err.Error())
+	}
+	if r.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for an unknown item, got %d", http.StatusBadRequest, r.StatusCode)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("Failed to read result body: %s", err.Error())
+	}
+	rsr := &proto.RaidSimResult{}
+	if err := protojson.Unmarshal(body, rsr); err != nil {
+		t.Fatalf("Failed to parse error response: %s", err.Error())
+	}
+	if !strings.Contains(rsr.ErrorResult, "unknown item: 40516") {
+		t.Fatalf("Expected an unknown item error, got %s", rsr.ErrorResult)
+	}
+}
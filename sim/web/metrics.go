@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Routes which get their own label in the request counter. Anything else, like
+// the UI's static files, is counted as "other".
+var instrumentedRoutes = []string{
+	"/asyncProgress",
+	"/asyncProgressStream",
+	"/asyncProgressStreamJson",
+	"/cancelAsync",
+	"/health",
+	"/jobs",
+	"/metrics",
+	"/version",
+}
+
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+type counterVec struct {
+	mut    sync.Mutex
+	values map[string]float64
+}
+
+func (c *counterVec) add(label string, v float64) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.values[label] += v
+}
+
+type histogram struct {
+	mut     sync.Mutex
+	buckets []float64 // Upper bounds.
+	counts  []uint64  // Cumulative count for each bucket.
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	for i, upperBound := range h.buckets {
+		if v <= upperBound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// serverMetrics collects the metrics served on /metrics, in the Prometheus text format.
+// It also implements core.SimMonitor, to get metrics of the sims themselves.
+type serverMetrics struct {
+	requests       counterVec // By endpoint.
+	iterations     counterVec
+	panics         counterVec
+	simDuration    *histogram
+	presimDuration *histogram
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		requests:       counterVec{values: map[string]float64{}},
+		iterations:     counterVec{values: map[string]float64{}},
+		panics:         counterVec{values: map[string]float64{}},
+		simDuration:    newHistogram(durationBuckets),
+		presimDuration: newHistogram(durationBuckets),
+	}
+}
+
+func (m *serverMetrics) PresimDone(duration time.Duration) {
+	m.presimDuration.observe(duration.Seconds())
+}
+
+func (m *serverMetrics) SimDone(iterations int32, duration time.Duration) {
+	m.iterations.add("", float64(iterations))
+	m.simDuration.observe(duration.Seconds())
+}
+
+func (m *serverMetrics) SimPanicked(_ interface{}) {
+	m.panics.add("", 1)
+}
+
+// Returns the label for counting requests to path, keeping the number of distinct labels small.
+func endpointLabel(path string) string {
+	if _, ok := handlers[path]; ok {
+		return path
+	}
+	if _, ok := asyncAPIHandlers[path]; ok {
+		return path
+	}
+	if slices.Contains(instrumentedRoutes, path) {
+		return path
+	}
+	if strings.HasPrefix(path, "/jobs/") {
+		return "/jobs/"
+	}
+	return "other"
+}
+
+// instrument counts all requests handled by next.
+func (m *serverMetrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.requests.add(endpointLabel(r.URL.Path), 1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.progMut.RLock()
+	numAsyncSims := len(s.asyncProgresses)
+	s.progMut.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	writeCounter(w, "wowsims_http_requests_total", "Number of HTTP requests by endpoint.", "endpoint", &s.metrics.requests)
+	writeCounter(w, "wowsims_sim_iterations_total", "Number of iterations simulated.", "", &s.metrics.iterations)
+	writeCounter(w, "wowsims_sim_panics_total", "Number of sims which panicked.", "", &s.metrics.panics)
+	writeHistogram(w, "wowsims_sim_duration_seconds", "Wall time of each sim, including presims.", s.metrics.simDuration)
+	writeHistogram(w, "wowsims_presim_duration_seconds", "Wall time of the presims of each sim.", s.metrics.presimDuration)
+	writeGauge(w, "wowsims_async_sims", "Number of async sims whose progress is tracked.", float64(numAsyncSims))
+	writeGauge(w, "wowsims_running_sims", "Number of sims currently running through the API.", float64(s.numRunningSims.Load()))
+	if s.jobs != nil {
+		writeGauge(w, "wowsims_job_queue_depth", "Number of jobs waiting for a worker.", float64(s.jobs.queueDepth()))
+	}
+	writeGauge(w, "wowsims_goroutines", "Number of goroutines.", float64(runtime.NumGoroutine()))
+}
+
+func writeHeader(w io.Writer, name string, help string, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeGauge(w io.Writer, name string, help string, value float64) {
+	writeHeader(w, name, help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(value))
+}
+
+// Writes a counter, with one series per label value. If label is empty, the counter has a single unlabeled series.
+func writeCounter(w io.Writer, name string, help string, label string, c *counterVec) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	writeHeader(w, name, help, "counter")
+	if label == "" {
+		fmt.Fprintf(w, "%s %s\n", name, formatValue(c.values[""]))
+		return
+	}
+
+	labelValues := make([]string, 0, len(c.values))
+	for labelValue := range c.values {
+		labelValues = append(labelValues, labelValue)
+	}
+	slices.Sort(labelValues)
+	for _, labelValue := range labelValues {
+		fmt.Fprintf(w, "%s{%s=%q} %s\n", name, label, labelValue, formatValue(c.values[labelValue]))
+	}
+}
+
+func writeHistogram(w io.Writer, name string, help string, h *histogram) {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	writeHeader(w, name, help, "histogram")
+	for i, upperBound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatValue(upperBound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatValue(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", v)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 5})
+	h.observe(0.5)
+	h.observe(2)
+	h.observe(10)
+
+	var sb strings.Builder
+	writeHistogram(&sb, "test_seconds", "Test histogram.", h)
+
+	expected := `# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="1"} 1
+test_seconds_bucket{le="5"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 12.5
+test_seconds_count 3
+`
+	if sb.String() != expected {
+		t.Fatalf("Histogram output:\n%s\nexpected:\n%s", sb.String(), expected)
+	}
+}
+
+func TestEndpointLabel(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/raidSim":          "/raidSim",
+		"/bulkSimAsync":     "/bulkSimAsync",
+		"/jobs/1234/cancel": "/jobs/",
+		"/sod/index.js":     "other",
+	} {
+		if label := endpointLabel(path); label != expected {
+			t.Errorf("Label for %s was %s, expected %s", path, label, expected)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/wowsims/sod/sim/core"
+	"github.com/wowsims/sod/sim/core/proto"
+	googleProto "google.golang.org/protobuf/proto"
+)
+
+// Checks a request for input the sim can't run, so it's rejected as a bad request
+// instead of failing inside the sim. Messages without sim input are always valid.
+func validateRequest(msg googleProto.Message) error {
+	switch request := msg.(type) {
+	case *proto.RaidSimRequest:
+		return validateRaidSimRequest(request)
+	case *proto.StatWeightsRequest:
+		if request.Player == nil {
+			return errors.New("missing player")
+		}
+		if err := validatePlayer(request.Player); err != nil {
+			return err
+		}
+		if request.SimOptions == nil {
+			return errors.New("missing sim options")
+		}
+		return validateEncounter(request.Encounter)
+	case *proto.ComputeStatsRequest:
+		return validateRaid(request.Raid)
+	case *proto.BulkSimRequest:
+		if request.BaseSettings == nil {
+			return errors.New("missing base settings")
+		}
+		if request.BulkSettings == nil {
+			return errors.New("missing bulk settings")
+		}
+		return validateRaidSimRequest(request.BaseSettings)
+	}
+	return nil
+}
+
+func validateRaidSimRequest(request *proto.RaidSimRequest) error {
+	if err := validateRaid(request.Raid); err != nil {
+		return err
+	}
+	if request.SimOptions == nil {
+		return errors.New("missing sim options")
+	}
+	return validateEncounter(request.Encounter)
+}
+
+func validateRaid(raid *proto.Raid) error {
+	if raid == nil {
+		return errors.New("missing raid")
+	}
+	numPlayers := 0
+	for _, party := range raid.Parties {
+		for _, player := range party.GetPlayers() {
+			// Players without a class are empty raid slots.
+			if player.Class == proto.Class_ClassUnknown {
+				continue
+			}
+			if err := validatePlayer(player); err != nil {
+				return err
+			}
+			numPlayers++
+		}
+	}
+	if numPlayers == 0 {
+		return errors.New("raid has no players")
+	}
+	return nil
+}
+
+func validatePlayer(player *proto.Player) error {
+	for _, item := range player.GetEquipment().GetItems() {
+		if item.Id == 0 {
+			continue
+		}
+		if _, ok := core.ItemsByID[item.Id]; !ok {
+			return fmt.Errorf("player '%s' has an unknown item: %d", player.Name, item.Id)
+		}
+	}
+	return nil
+}
+
+func validateEncounter(encounter *proto.Encounter) error {
+	if encounter == nil {
+		return errors.New("missing encounter")
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import {
 	APLActionActivateAuraWithStacks,
 	APLActionAddComboPoints,
 	APLActionAutocastOtherCooldowns,
+	APLActionCallActionList,
 	APLActionCancelAura,
 	APLActionCastPaladinPrimarySeal,
 	APLActionCastSpell,
@@ -19,8 +20,10 @@ import {
 	APLActionMultidot,
 	APLActionMultishield,
 	APLActionResetSequence,
+	APLActionRunActionList,
 	APLActionSchedule,
 	APLActionSequence,
+	APLActionSetVariable,
 	APLActionStrictSequence,
 	APLActionTriggerICD,
 	APLActionWait,
@@ -531,6 +534,25 @@ const actionKindFactories: { [f in NonNullable<APLActionKind>]: ActionKindConfig
 		newValue: APLActionStrictSequence.create,
 		fields: [actionListFieldConfig('actions')],
 	}),
+	['callActionList']: inputBuilder({
+		label: 'Call Action List',
+		submenu: ['Action Lists'],
+		shortDescription: 'Performs the first ready action of a named action list, or continues with the next action if none are ready.',
+		includeIf: (player: Player<any>, isPrepull: boolean) => !isPrepull,
+		newValue: APLActionCallActionList.create,
+		fields: [AplHelpers.stringFieldConfig('name')],
+	}),
+	['runActionList']: inputBuilder({
+		label: 'Run Action List',
+		submenu: ['Action Lists'],
+		shortDescription: 'Performs the first ready action of a named action list, and skips all actions after this one.',
+		fullDescription: `
+			<p>Unlike <b>Call Action List</b>, the actions after this one are skipped even if none of the list's actions are ready.</p>
+		`,
+		includeIf: (player: Player<any>, isPrepull: boolean) => !isPrepull,
+		newValue: APLActionRunActionList.create,
+		fields: [AplHelpers.stringFieldConfig('name')],
+	}),
 	['changeTarget']: inputBuilder({
 		label: 'Change Target',
 		submenu: ['Misc'],
@@ -613,6 +635,17 @@ const actionKindFactories: { [f in NonNullable<APLActionKind>]: ActionKindConfig
 			}),
 		],
 	}),
+	['setVariable']: inputBuilder({
+		label: 'Set Variable',
+		submenu: ['Misc'],
+		shortDescription: 'Sets a rotation variable to a value.',
+		fullDescription: `
+			<p>Use the <b>name</b> field to refer to a variable defined in the rotation's variables. The value is converted to the type of the variable.</p>
+			<p>This action is only ready when it would change the value of the variable, and does not use the GCD.</p>
+		`,
+		newValue: () => APLActionSetVariable.create(),
+		fields: [AplHelpers.stringFieldConfig('name'), AplValues.valueFieldConfig('value')],
+	}),
 	['customRotation']: inputBuilder({
 		label: 'Custom Rotation',
 		//submenu: ['Misc'],
@@ -1,6 +1,7 @@
 import tippy, { Instance as TippyInstance } from 'tippy.js';
 
 import { Player } from '../../player';
+import { APLDiagnostic_Section } from '../../proto/api';
 import { APLAction, APLListItem, APLPrepullAction, APLValue } from '../../proto/apl';
 import { ActionId } from '../../proto_utils/action_id';
 import { SimUI } from '../../sim_ui';
@@ -96,7 +97,9 @@ class APLPrepullActionPicker extends Input<Player<any>, APLPrepullAction> {
 		this.player = player;
 
 		const itemHeaderElem = ListPicker.getItemHeaderElem(this);
-		makeListItemWarnings(itemHeaderElem, player, player => player.getCurrentStats().rotationStats?.prepullActions[index]?.warnings || []);
+		makeListItemWarnings(itemHeaderElem, player, player =>
+			getListItemWarnings(player, APLDiagnostic_Section.SectionPrepull, index, player.getCurrentStats().rotationStats?.prepullActions[index]?.warnings),
+		);
 
 		this.hidePicker = new HidePicker(itemHeaderElem, player, {
 			changedEvent: () => this.player.rotationChangeEmitter,
@@ -196,7 +199,9 @@ class APLListItemPicker extends Input<Player<any>, APLListItem> {
 		this.player = player;
 
 		const itemHeaderElem = ListPicker.getItemHeaderElem(this);
-		makeListItemWarnings(itemHeaderElem, player, player => player.getCurrentStats().rotationStats?.priorityList[index]?.warnings || []);
+		makeListItemWarnings(itemHeaderElem, player, player =>
+			getListItemWarnings(player, APLDiagnostic_Section.SectionPriorityList, index, player.getCurrentStats().rotationStats?.priorityList[index]?.warnings),
+		);
 
 		this.hidePicker = new HidePicker(itemHeaderElem, player, {
 			changedEvent: () => this.player.rotationChangeEmitter,
@@ -239,6 +244,13 @@ class APLListItemPicker extends Input<Player<any>, APLListItem> {
 	}
 }
 
+// Returns the warnings from parsing a row, followed by the issues found by linting it.
+function getListItemWarnings(player: Player<any>, section: APLDiagnostic_Section, index: number, warnings: Array<string> | undefined): Array<string> {
+	const diagnostics = player.getCurrentStats().rotationStats?.diagnostics || [];
+	const rowDiagnostics = diagnostics.filter(diagnostic => diagnostic.section == section && diagnostic.row == index);
+	return (warnings || []).concat(rowDiagnostics.map(diagnostic => diagnostic.message));
+}
+
 function makeListItemWarnings(itemHeaderElem: HTMLElement, player: Player<any>, getWarnings: (player: Player<any>) => Array<string>) {
 	const warningsElem = ListPicker.makeActionElem('apl-warnings', 'fa-exclamation-triangle');
 	warningsElem.classList.add('warning', 'link-warning');
@@ -38,6 +38,7 @@ import {
 	APLValueGCDTimeToReady,
 	APLValueIsExecutePhase,
 	APLValueIsExecutePhase_ExecutePhaseThreshold as ExecutePhaseThreshold,
+	APLValueMacro,
 	APLValueMath,
 	APLValueMath_MathOperator as MathOperator,
 	APLValueMax,
@@ -63,6 +64,7 @@ import {
 	APLValueSpellTravelTime,
 	APLValueTimeToEnergyTick,
 	APLValueTotemRemainingTime,
+	APLValueVariable,
 	APLValueWarlockCurrentPetMana,
 	APLValueWarlockCurrentPetManaPercent,
 	APLValueWarlockPetIsActive,
@@ -922,6 +924,22 @@ const valueKindFactories: { [f in NonNullable<APLValueKind>]: ValueKindConfig<AP
 		fields: [AplHelpers.stringFieldConfig('sequenceName')],
 	}),
 
+	// Variables
+	variable: inputBuilder({
+		label: 'Variable',
+		submenu: ['Variables'],
+		shortDescription: 'Returns the current value of a rotation variable.',
+		newValue: APLValueVariable.create,
+		fields: [AplHelpers.stringFieldConfig('name')],
+	}),
+	macro: inputBuilder({
+		label: 'Macro',
+		submenu: ['Variables'],
+		shortDescription: 'Returns the value of a named macro defined in the rotation.',
+		newValue: APLValueMacro.create,
+		fields: [AplHelpers.stringFieldConfig('name')],
+	}),
+
 	// Class/spec specific values
 	totemRemainingTime: inputBuilder({
 		label: 'Totem Remaining Time',
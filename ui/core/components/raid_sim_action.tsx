@@ -11,11 +11,12 @@ import { EventID, TypedEvent } from '../typed_event';
 import { formatDeltaTextElem, formatToNumber, formatToPercent, sum } from '../utils';
 
 export function addRaidSimAction(simUI: SimUI): RaidSimResultsManager {
-	simUI.addAction('Simulate', 'dps-action', async () =>
-		simUI.runSim((progress: ProgressMetrics) => {
-			resultsManager.setSimProgress(progress);
-		}),
-	);
+	simUI.addAction('Simulate', 'dps-action', async () => {
+		const abortController = new AbortController();
+		await simUI.runSim((progress: ProgressMetrics) => {
+			resultsManager.setSimProgress(progress, abortController);
+		}, abortController.signal);
+	});
 
 	const resultsManager = new RaidSimResultsManager(simUI);
 	simUI.sim.simResultEmitter.on((eventID, simResult) => {
@@ -105,7 +106,8 @@ export class RaidSimResultsManager {
 		[this.currentChangeEmitter, this.referenceChangeEmitter].forEach(emitter => emitter.on(eventID => this.changeEmitter.emit(eventID)));
 	}
 
-	setSimProgress(progress: ProgressMetrics) {
+	// Aborting abortController stops the sim, which then shows the results of the completed iterations.
+	setSimProgress(progress: ProgressMetrics, abortController?: AbortController) {
 		this.simUI.resultsViewer.setContent(
 			<div className="results-sim">
 				<div className="results-sim-dps damage-metrics">
@@ -121,6 +123,11 @@ export class RaidSimResultsManager {
 					<br />
 					iterations complete
 				</div>
+				{abortController && (
+					<a href="javascript:void(0)" className="results-sim-cancel" attributes={{ role: 'button' }} onclick={() => abortController.abort()}>
+						<i className="fa fa-times fa-lg me-1"></i>Cancel
+					</a>
+				)}
 			</div>,
 		);
 	}
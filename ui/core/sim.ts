@@ -229,7 +229,7 @@ export class Sim {
 		return result;
 	}
 
-	async runRaidSim(eventID: EventID, onProgress: (_?: any) => void): Promise<SimResult> {
+	async runRaidSim(eventID: EventID, onProgress: (_?: any) => void, signal?: AbortSignal): Promise<SimResult> {
 		if (this.raid.isEmpty()) {
 			throw new Error('Raid is empty! Try adding some players first.');
 		} else if (this.encounter.targets.length < 1) {
@@ -240,7 +240,7 @@ export class Sim {
 
 		const request = this.makeRaidSimRequest(false);
 
-		const result = await this.workerPool.raidSimAsync(request, onProgress);
+		const result = await this.workerPool.raidSimAsync(request, onProgress, signal);
 		if (result.errorResult != '') {
 			throw new SimError(result.errorResult);
 		}
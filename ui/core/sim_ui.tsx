@@ -252,10 +252,10 @@ export abstract class SimUI extends Component {
 		return this.rootElem.classList.contains('individual-sim-ui');
 	}
 
-	async runSim(onProgress: (_?: any) => void) {
+	async runSim(onProgress: (_?: any) => void, signal?: AbortSignal) {
 		this.resultsViewer.setPending();
 		try {
-			await this.sim.runRaidSim(TypedEvent.nextEventID(), onProgress);
+			await this.sim.runRaidSim(TypedEvent.nextEventID(), onProgress, signal);
 		} catch (e) {
 			this.resultsViewer.hideAll();
 			this.handleCrash(e);
@@ -72,12 +72,14 @@ export class WorkerPool {
 		return result.finalBulkResult!;
 	}
 
-	async raidSimAsync(request: RaidSimRequest, onProgress: WorkerProgressCallback): Promise<RaidSimResult> {
+	// If signal is aborted, the sim stops early and returns the results of the iterations completed so far.
+	async raidSimAsync(request: RaidSimRequest, onProgress: WorkerProgressCallback, signal?: AbortSignal): Promise<RaidSimResult> {
 		console.log('Raid sim request: ' + RaidSimRequest.toJsonString(request));
 		const worker = this.getLeastBusyWorker();
 		const id = worker.makeTaskId();
 		// Add handler for the progress events
 		worker.addPromiseFunc(this.getProgressName(id), this.newProgressHandler(id, worker, onProgress), noop);
+		signal?.addEventListener('abort', () => worker.doApiCall(SimRequest.cancelAsync, new TextEncoder().encode(id), ''), { once: true });
 
 		// Now start the async sim
 		const resultData = await worker.doApiCall(SimRequest.raidSimAsync, RaidSimRequest.toBinary(request), id);
@@ -1,12 +1,13 @@
 import { WorkerInterface } from './worker_interface';
 
-type SimRequestAsync = (data: Uint8Array, progress: (result: Uint8Array) => void) => Uint8Array;
+type SimRequestAsync = (data: Uint8Array, progress: (result: Uint8Array) => void, id: string) => Promise<Uint8Array>;
 type SimRequestSync = (data: Uint8Array) => Uint8Array;
 
 // Functions provided or used by the wasm lib.
 declare global {
 	function wasmready(): void;
 	const bulkSimAsync: SimRequestAsync;
+	const cancelAsync: (id: string) => boolean;
 	const computeStats: SimRequestSync;
 	const computeStatsJson: SimRequestSync;
 	const raidSim: SimRequestSync;
@@ -20,14 +21,19 @@ declare global {
 // eslint-disable-next-line @typescript-eslint/no-unused-vars
 globalThis.wasmready = function () {
 	new WorkerInterface({
-		bulkSimAsync: (data, progress) => bulkSimAsync(data, progress),
+		bulkSimAsync: (data, progress, _, id) => bulkSimAsync(data, progress, id),
 		computeStats: computeStats,
 		computeStatsJson: computeStatsJson,
 		raidSim: raidSim,
 		raidSimJson: raidSimJson,
-		raidSimAsync: (data, progress) => raidSimAsync(data, progress),
+		raidSimAsync: (data, progress, _, id) => raidSimAsync(data, progress, id),
 		statWeights: statWeights,
-		statWeightsAsync: (data, progress) => statWeightsAsync(data, progress),
+		statWeightsAsync: (data, progress, _, id) => statWeightsAsync(data, progress, id),
+		// The data is the ID of the async request to cancel.
+		cancelAsync: data => {
+			cancelAsync(new TextDecoder().decode(data));
+			return new Uint8Array();
+		},
 	}).ready();
 };
 
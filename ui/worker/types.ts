@@ -10,6 +10,7 @@ export enum SimRequest {
 	raidSimAsync = 'raidSimAsync',
 	statWeights = 'statWeights',
 	statWeightsAsync = 'statWeightsAsync',
+	cancelAsync = 'cancelAsync',
 }
 
 /**
@@ -21,8 +21,12 @@ export const setupHttpWorker = (baseURL: string) => {
 		return new Uint8Array(ab);
 	};
 
-	const asyncHandler: HandlerFunction = async (inputData, progress, msg) => {
-		const asyncApiResult = await syncHandler(inputData, noop, msg);
+	// AsyncAPIResults of the running async requests, by request ID.
+	const asyncApiResults: Record<string, Uint8Array> = {};
+
+	const asyncHandler: HandlerFunction = async (inputData, progress, msg, id) => {
+		const asyncApiResult = await syncHandler(inputData, noop, msg, id);
+		asyncApiResults[id] = asyncApiResult;
 		let outputData = new Uint8Array();
 		while (true) {
 			const progressResponse = await makeHttpApiRequest('asyncProgress', asyncApiResult);
@@ -37,9 +41,19 @@ export const setupHttpWorker = (baseURL: string) => {
 			progress?.(outputData);
 			await sleep(500);
 		}
+		delete asyncApiResults[id];
 		return outputData;
 	};
 
+	// The data is the ID of the async request to cancel.
+	const cancelHandler: HandlerFunction = async (inputData, _, msg) => {
+		const asyncApiResult = asyncApiResults[new TextDecoder().decode(inputData)];
+		if (asyncApiResult) {
+			await makeHttpApiRequest(msg, asyncApiResult);
+		}
+		return new Uint8Array();
+	};
+
 	new WorkerInterface({
 		bulkSimAsync: asyncHandler,
 		computeStats: syncHandler,
@@ -49,5 +63,6 @@ export const setupHttpWorker = (baseURL: string) => {
 		raidSimAsync: asyncHandler,
 		statWeights: syncHandler,
 		statWeightsAsync: asyncHandler,
+		cancelAsync: cancelHandler,
 	}).ready();
 };
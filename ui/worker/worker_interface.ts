@@ -1,7 +1,7 @@
 import type { SimRequest, WorkerReceiveMessage, WorkerSendMessage } from './types';
 
 export type HandlerProgressCallback = (outputData: Uint8Array) => void;
-export type HandlerFunction = (data: Uint8Array, progress: HandlerProgressCallback, msg: SimRequest) => Uint8Array | Promise<Uint8Array>;
+export type HandlerFunction = (data: Uint8Array, progress: HandlerProgressCallback, msg: SimRequest, id: string) => Uint8Array | Promise<Uint8Array>;
 export type Handlers = Record<SimRequest, HandlerFunction>;
 
 /**
@@ -38,7 +38,7 @@ export class WorkerInterface {
 				});
 			};
 
-			const outputData = await handlerFunc(inputData, progressCallback, msg);
+			const outputData = await handlerFunc(inputData, progressCallback, msg, id);
 			this.postMessage({ msg, id, outputData });
 		});
 	}